@@ -325,7 +325,7 @@ func main() {
 	}
 
 	verbosePrintf("create GOPATH at %v\n", gopath)
-	if err = updateGopath(gopath, filepath.Join(root, "src"), "grobi"); err != nil {
+	if err = updateGopath(gopath, filepath.Join(root, "src"), ""); err != nil {
 		die("copying files from %v to %v failed: %v\n", root, gopath, err)
 	}
 
@@ -358,7 +358,7 @@ func main() {
 	args := []string{
 		"-tags", strings.Join(buildTags, " "),
 		"-ldflags", ldflags,
-		"-o", output, "grobi/cmd/grobi",
+		"-o", output, "cmd/grobi",
 	}
 
 	err = build(gopath, args...)
@@ -369,7 +369,7 @@ func main() {
 	if runTests {
 		verbosePrintf("running tests\n")
 
-		err = test(gopath, "github.com/restic/restic/...")
+		err = test(gopath, "cmd/...", "pkg/...")
 		if err != nil {
 			die("running tests failed: %v\n", err)
 		}
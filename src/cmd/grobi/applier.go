@@ -0,0 +1,101 @@
+package main
+
+import (
+	"time"
+
+	"pkg/randr"
+)
+
+// Applier runs a single apply cycle on outputs which have been queried once.
+// The same outputs are used to select the rule, to build the xrandr commands
+// and to compute the layout the rule is expected to produce. The outputs after
+// the rule has been applied, which verification and the connect hooks need,
+// are queried at most once.
+type Applier struct {
+	// Outputs are the outputs before the rule is applied.
+	Outputs randr.Outputs
+
+	// query returns the current outputs and applyRule applies a rule, they
+	// are replaced in tests.
+	query     func() (randr.Outputs, error)
+	applyRule func(randr.Outputs, randr.Rule) (ApplyResult, error)
+
+	applied bool
+	after   randr.Outputs
+}
+
+// NewApplier returns an Applier for the outputs, which should have been
+// returned by DetectOutputs or GetOutputs.
+func NewApplier(outputs randr.Outputs) *Applier {
+	return &Applier{
+		Outputs:   outputs,
+		query:     GetOutputs,
+		applyRule: ApplyRule,
+	}
+}
+
+// SelectRule returns the rule to apply to the outputs, see SelectRule.
+func (a *Applier) SelectRule(rules []randr.Rule) (randr.Rule, bool) {
+	return SelectRule(rules, a.Outputs)
+}
+
+// Apply applies the rule to the outputs. If a history file is configured, the
+// rule and whether it was applied successfully are appended to it. If the
+// rule was applied successfully and a state file is configured, the resulting
+// layout is written to it.
+func (a *Applier) Apply(rule randr.Rule) (ApplyResult, error) {
+	a.applied = true
+	a.after = nil
+
+	result, err := a.applyRule(a.Outputs, rule)
+
+	cfg := globalOpts.config()
+	if cfg.HistoryFile != "" && !globalOpts.DryRun {
+		line := historyLine(time.Now(), rule.Name, a.Outputs, err == nil && result.Success())
+		if herr := appendHistory(cfg.HistoryFile, cfg.HistoryMaxSize, line); herr != nil {
+			warnf("unable to write history file: %v\n", herr)
+		}
+	}
+
+	if err != nil || !result.Success() {
+		return result, err
+	}
+
+	if filename := cfg.StateFile; filename != "" && !globalOpts.DryRun {
+		if err = a.writeState(filename, rule); err != nil {
+			warnf("unable to write state file: %v\n", err)
+		}
+	}
+
+	return result, nil
+}
+
+// writeState writes the outputs after rule has been applied to the state
+// file.
+func (a *Applier) writeState(filename string, rule randr.Rule) error {
+	after, err := a.After()
+	if err != nil {
+		return err
+	}
+
+	return writeState(filename, NewState(rule.Name, after))
+}
+
+// After returns the outputs after the rule has been applied. They are queried
+// on the first call only. Before a rule has been applied or on a dry run, the
+// outputs did not change and are returned as they are.
+func (a *Applier) After() (randr.Outputs, error) {
+	if !a.applied || globalOpts.DryRun {
+		return a.Outputs, nil
+	}
+
+	if a.after == nil {
+		after, err := a.query()
+		if err != nil {
+			return nil, err
+		}
+		a.after = after
+	}
+
+	return a.after, nil
+}
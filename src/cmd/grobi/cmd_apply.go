@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
+
+	"pkg/randr"
 )
 
-type CmdApply struct{}
+type CmdApply struct {
+	Confirm time.Duration `long:"confirm" description:"Restore the previous layout unless Enter is pressed within this duration"`
+}
 
 func init() {
 	_, err := parser.AddCommand("apply",
@@ -24,39 +31,202 @@ func (cmd CmdApply) Usage() string {
 	return "apply RULE"
 }
 
-func ApplyRule(outputs Outputs, rule Rule) error {
-	var cmds []*exec.Cmd
-	var err error
+// CommandResult records the execution of a single command.
+type CommandResult struct {
+	Args     []string
+	Duration time.Duration
+	Err      error
+}
+
+// Success returns true iff the command ran without an error.
+func (r CommandResult) Success() bool {
+	return r.Err == nil
+}
 
+// ApplyResult lists the commands executed to apply a rule.
+type ApplyResult struct {
+	Rule     string
+	Commands []CommandResult
+}
+
+// Success returns true iff all commands ran without an error.
+func (r ApplyResult) Success() bool {
+	for _, cmd := range r.Commands {
+		if !cmd.Success() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// record calls run for the command described by args and records the
+// duration and the error returned by run.
+func (r *ApplyResult) record(args []string, run func() error) error {
+	start := time.Now()
+	err := run()
+	r.Commands = append(r.Commands, CommandResult{
+		Args:     args,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+
+	return err
+}
+
+// ruleCommands returns the commands which configure the outputs as described
+// by rule.
+func ruleCommands(outputs randr.Outputs, rule randr.Rule) ([]*exec.Cmd, error) {
 	switch {
-	case rule.ConfigureSingle != "" || len(rule.ConfigureRow) > 0:
-		cmds, err = BuildCommandOutputRow(rule, outputs)
+	case rule.ConfigureSingle != "" || len(rule.ConfigureRow) > 0 || rule.SingleExternal:
+		return randr.BuildCommandOutputRow(rule, outputs, globalOpts.config().Options())
 	case rule.ConfigureCommand != "":
-		cmds = []*exec.Cmd{exec.Command("sh", "-c", rule.ConfigureCommand)}
+		return []*exec.Cmd{exec.Command("sh", "-c", rule.ConfigureCommand)}, nil
 	default:
-		return fmt.Errorf("no output configuration for rule %v", rule.Name)
+		return nil, fmt.Errorf("no output configuration for rule %v", rule.Name)
+	}
+}
+
+// ruleHooks returns the hooks which are run after rule has been applied, the
+// global ones first.
+func ruleHooks(rule randr.Rule) []string {
+	var after []string
+	after = append(after, globalOpts.config().ExecuteAfter...)
+	after = append(after, rule.ExecuteAfter...)
+	return after
+}
+
+// ApplyRule runs the commands to configure the outputs as described by rule
+// and the hooks afterwards. The returned result lists all commands executed.
+func ApplyRule(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+	result := ApplyResult{Rule: rule.Name}
+
+	cmds, err := ruleCommands(outputs, rule)
+	if err != nil {
+		return result, err
 	}
 
-	after := append(globalOpts.cfg.ExecuteAfter, rule.ExecuteAfter...)
-	if len(after) > 0 {
-		for _, cmd := range after {
-			cmds = append(cmds, exec.Command("sh", "-c", cmd))
+	settler, err := newSettler(globalOpts.SettleStrategy, globalOpts.Settle)
+	if err != nil {
+		return result, err
+	}
+
+	for i, cmd := range cmds {
+		cmd := cmd
+		if i > 0 && settler != nil && !globalOpts.DryRun {
+			if err = settler.Settle(); err != nil {
+				return result, err
+			}
+		}
+
+		err = result.record(cmd.Args, func() error { return RunCommand(cmd) })
+		if err != nil {
+			fmt.Fprintf(stderrOutput, "executing command for rule %v failed: %v\n", rule.Name, err)
 		}
 	}
 
+	cfg := globalOpts.config()
+	env := []string{"GROBI_RULE=" + rule.Name}
+	stdin, err := hookStdin(cfg.HookStdin, outputs)
+	if err != nil {
+		return result, err
+	}
+
+	for _, hook := range ruleHooks(rule) {
+		hook := hook
+		if rule.Async && !globalOpts.DryRun {
+			runHookAsync(rule.Name, cfg.HookShell, hook, env, stdin)
+			continue
+		}
+
+		args := HookCommand(cfg.HookShell, hook).Args
+		err = result.record(args, func() error { return RunHook(cfg.HookShell, hook, env, stdin) })
+		if err != nil {
+			fmt.Fprintf(stderrOutput, "executing hook for rule %v failed: %v\n", rule.Name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// writePlan writes to w what applying rule to outputs would do, in order:
+// whether the rule matches the outputs and why, the commands which configure
+// the outputs and the hooks which are run afterwards.
+func writePlan(w io.Writer, outputs randr.Outputs, rule randr.Rule) error {
+	cmds, err := ruleCommands(outputs, rule)
 	if err != nil {
 		return err
 	}
+
+	explainRule(w, rule, outputs)
+
+	fmt.Fprintf(w, "commands:\n")
 	for _, cmd := range cmds {
-		err = RunCommand(cmd)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "executing command for rule %v failed: %v\n", rule.Name, err)
+		fmt.Fprintf(w, "  %s\n", strings.Join(cmd.Args, " "))
+	}
+
+	fmt.Fprintf(w, "hooks:\n")
+	hooks := ruleHooks(rule)
+	if len(hooks) == 0 {
+		fmt.Fprintf(w, "  none\n")
+	}
+
+	for _, hook := range hooks {
+		cmd := HookCommand(globalOpts.config().HookShell, hook)
+		fmt.Fprintf(w, "  %s %s %q", cmd.Args[0], cmd.Args[1], hook)
+		if rule.Async {
+			fmt.Fprintf(w, " (async)")
 		}
+		fmt.Fprintf(w, "\n")
 	}
 
 	return nil
 }
 
+// confirmLayout asks on w to confirm the layout by pressing Enter on rd. If
+// no line is read within timeout, e.g. because the screen went black, the
+// layout of the outputs before the rule was applied is restored by applying
+// a rule built from a snapshot of them to the current outputs. If rd is
+// closed (e.g. stdin is not a terminal), the timeout is awaited as well. It
+// returns true if the layout was confirmed.
+func confirmLayout(rd io.Reader, w io.Writer, timeout time.Duration, before, current randr.Outputs) (bool, error) {
+	fmt.Fprintf(w, "press Enter within %v to keep the new layout\n", timeout)
+
+	ch := make(chan error, 1)
+	go func() {
+		_, err := bufio.NewReader(rd).ReadString('\n')
+		ch <- err
+	}()
+
+	timer := time.After(timeout)
+wait:
+	for {
+		select {
+		case err := <-ch:
+			if err == nil {
+				return true, nil
+			}
+
+			// Enter cannot be pressed, the layout is kept for the
+			// whole timeout nevertheless
+			verbosePrintf("unable to read confirmation: %v\n", err)
+			ch = nil
+		case <-timer:
+			break wait
+		}
+	}
+
+	fmt.Fprintf(w, "layout not confirmed, restoring the previous layout\n")
+
+	snap := NewSnapshot(before)
+	if len(snap.Outputs) == 0 {
+		return false, errors.New("no active outputs to restore")
+	}
+
+	_, err := ApplyRule(current, snap.Rule("previous layout"))
+	return false, err
+}
+
 func (cmd CmdApply) Execute(args []string) error {
 	globalOpts.ReadConfigfile()
 
@@ -69,13 +239,27 @@ func (cmd CmdApply) Execute(args []string) error {
 		return err
 	}
 
-	ruleName := strings.ToLower(args[0])
-	for _, rule := range globalOpts.cfg.Rules {
-		if strings.ToLower(rule.Name) == ruleName {
-			verbosePrintf("found matching rule (name %v)\n", rule.Name)
-			return ApplyRule(outputs, rule)
-		}
+	rule, ok := findRule(globalOpts.cfg.Rules, args[0])
+	if !ok {
+		return fmt.Errorf("rule %q not found", strings.ToLower(args[0]))
+	}
+
+	verbosePrintf("found matching rule (name %v)\n", rule.Name)
+	if globalOpts.DryRun {
+		return writePlan(dryRunOutput, outputs, rule)
+	}
+
+	a := NewApplier(outputs)
+	_, err = a.Apply(rule)
+	if err != nil || cmd.Confirm <= 0 {
+		return err
+	}
+
+	current, err := a.After()
+	if err != nil {
+		return err
 	}
 
-	return fmt.Errorf("rule %q not found", ruleName)
+	_, err = confirmLayout(os.Stdin, os.Stdout, cmd.Confirm, outputs, current)
+	return err
 }
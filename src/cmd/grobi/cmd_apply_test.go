@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+func TestApplyRuleResult(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, hook func(*exec.Cmd) ([]byte, error), cfg *Config) {
+		runCommand = run
+		hookExecutor = hook
+		globalOpts.cfg = cfg
+	}(runCommand, hookExecutor, globalOpts.cfg)
+
+	runCommand = func(cmd *exec.Cmd) error { return nil }
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) { return nil, nil }
+	globalOpts.cfg = &Config{ExecuteAfter: []string{"notify-send foo"}}
+
+	current := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	rule := randr.Rule{
+		Name:         "Docked",
+		ConfigureRow: []string{"LVDS1", "HDMI1"},
+		ExecuteAfter: []string{"pkill xautolock"},
+	}
+
+	result, err := ApplyRule(current, rule)
+	if err != nil {
+		t.Fatalf("ApplyRule returned error: %v", err)
+	}
+
+	if result.Rule != "Docked" {
+		t.Errorf("wrong rule name in result: %q", result.Rule)
+	}
+
+	if len(result.Commands) != 4 {
+		t.Fatalf("wrong number of commands recorded: want 4, got %d", len(result.Commands))
+	}
+
+	if !result.Success() {
+		t.Errorf("result reports failure: %v", result.Commands)
+	}
+
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) { return nil, errors.New("exit status 1") }
+	result, err = ApplyRule(current, rule)
+	if err != nil {
+		t.Fatalf("ApplyRule returned error: %v", err)
+	}
+
+	if result.Success() {
+		t.Errorf("failed hooks not recorded in result")
+	}
+
+	if !result.Commands[0].Success() || result.Commands[3].Success() {
+		t.Errorf("wrong command status recorded: %v", result.Commands)
+	}
+}
+
+func TestRunCommandDisplay(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, display string) {
+		runCommand = run
+		globalOpts.Display = display
+	}(runCommand, globalOpts.Display)
+
+	var env []string
+	runCommand = func(cmd *exec.Cmd) error {
+		env = cmd.Env
+		return nil
+	}
+	globalOpts.Display = ":1"
+
+	if err := RunCommand(exec.Command("xrandr", "--output", "LVDS1", "--auto")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(env) == 0 || env[len(env)-1] != "DISPLAY=:1" {
+		t.Errorf("DISPLAY not set for command, env: %v", env)
+	}
+
+	cmd := runXrandr("--current")
+	if len(cmd.Env) == 0 || cmd.Env[len(cmd.Env)-1] != "DISPLAY=:1" {
+		t.Errorf("DISPLAY not set for xrandr query, env: %v", cmd.Env)
+	}
+}
+
+func TestRunCommandStderr(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, output io.Writer) {
+		runCommand = run
+		stderrOutput = output
+	}(runCommand, stderrOutput)
+
+	tee := bytes.NewBuffer(nil)
+	stderrOutput = tee
+	runCommand = func(cmd *exec.Cmd) error {
+		fmt.Fprintf(cmd.Stderr, "xrandr: cannot find crtc for output HDMI1\n")
+		return errors.New("exit status 1")
+	}
+
+	err := RunCommand(exec.Command("xrandr", "--output", "HDMI1", "--auto"))
+	cmdErr, ok := err.(*CommandError)
+	if !ok {
+		t.Fatalf("wrong error returned: %#v", err)
+	}
+
+	if !strings.Contains(cmdErr.Stderr, "cannot find crtc") {
+		t.Errorf("stderr not captured: %q", cmdErr.Stderr)
+	}
+
+	if tee.String() != cmdErr.Stderr {
+		t.Errorf("stderr not copied: %q", tee.String())
+	}
+
+	runCommand = func(cmd *exec.Cmd) error {
+		fmt.Fprintf(cmd.Stderr, "warning\n")
+		return nil
+	}
+
+	if err = RunCommand(exec.Command("xrandr")); err != nil {
+		t.Errorf("successful command returned error: %v", err)
+	}
+}
+
+func TestConfirmLayoutRevert(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, cfg *Config) {
+		runCommand = run
+		globalOpts.cfg = cfg
+	}(runCommand, globalOpts.cfg)
+
+	var cmds [][]string
+	runCommand = func(cmd *exec.Cmd) error {
+		cmds = append(cmds, cmd.Args)
+		return nil
+	}
+	globalOpts.cfg = &Config{}
+
+	before := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal", Primary: true},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+	current := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Rotation: "normal"},
+	}
+
+	// nothing is ever written to the pipe
+	rd, wr := io.Pipe()
+	defer wr.Close()
+
+	buf := bytes.NewBuffer(nil)
+	confirmed, err := confirmLayout(rd, buf, 10*time.Millisecond, before, current)
+	if err != nil {
+		t.Fatalf("confirmLayout returned error: %v", err)
+	}
+
+	if confirmed {
+		t.Fatalf("layout confirmed without input")
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "HDMI1", "--off"},
+		{"xrandr", "--output", "LVDS1", "--mode", "1366x768", "--primary", "--rotate", "normal", "--pos", "0x0"},
+	}
+
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("wrong revert commands:\n  want %v\n  got  %v", want, cmds)
+	}
+
+	cmds = nil
+	confirmed, err = confirmLayout(strings.NewReader("\n"), buf, time.Second, before, current)
+	if err != nil || !confirmed {
+		t.Errorf("layout not confirmed: %v", err)
+	}
+
+	if len(cmds) > 0 {
+		t.Errorf("commands run although the layout was confirmed: %v", cmds)
+	}
+
+	// stdin is closed, the layout is only restored after the timeout
+	timeout := 50 * time.Millisecond
+	start := time.Now()
+	confirmed, err = confirmLayout(strings.NewReader(""), buf, timeout, before, current)
+	if err != nil || confirmed {
+		t.Errorf("layout confirmed from closed input: %v, %v", confirmed, err)
+	}
+
+	if d := time.Since(start); d < timeout {
+		t.Errorf("layout restored after %v, before the timeout of %v", d, timeout)
+	}
+
+	if len(cmds) == 0 {
+		t.Errorf("layout not restored after the timeout")
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	defer func(cfg *Config) {
+		globalOpts.cfg = cfg
+	}(globalOpts.cfg)
+
+	globalOpts.cfg = &Config{ExecuteAfter: []string{"notify-send foo"}}
+
+	current := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	rule := randr.Rule{
+		Name:             "Docked",
+		OutputsConnected: []string{"HDMI1"},
+		ConfigureSingle:  "HDMI1",
+		ExecuteAfter:     []string{"pkill xautolock"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := writePlan(buf, current, rule); err != nil {
+		t.Fatalf("writePlan returned error: %v", err)
+	}
+
+	want := `rule Docked: matches
+  outputs_connected HDMI1: passed
+commands:
+  xrandr --output LVDS1 --off
+  xrandr --output HDMI1 --auto
+hooks:
+  sh -c "notify-send foo"
+  sh -c "pkill xautolock"
+`
+	if buf.String() != want {
+		t.Errorf("wrong plan, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	rule.ExecuteAfter = nil
+	rule.ConfigureSingle = ""
+	if err := writePlan(bytes.NewBuffer(nil), current, rule); err == nil {
+		t.Errorf("no error for a rule without output configuration")
+	}
+}
+
+func TestApplyRuleFailureOutput(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, hook func(*exec.Cmd) ([]byte, error), cfg *Config, output io.Writer) {
+		runCommand = run
+		hookExecutor = hook
+		globalOpts.cfg = cfg
+		stderrOutput = output
+	}(runCommand, hookExecutor, globalOpts.cfg, stderrOutput)
+
+	buf := bytes.NewBuffer(nil)
+	stderrOutput = buf
+	runCommand = func(cmd *exec.Cmd) error { return errors.New("exit status 1") }
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) { return nil, errors.New("exit status 2") }
+	globalOpts.cfg = &Config{}
+
+	rule := randr.Rule{
+		Name:            "Docked",
+		ConfigureSingle: "HDMI1",
+		ExecuteAfter:    []string{"pkill xautolock"},
+	}
+
+	if _, err := ApplyRule(randr.Outputs{{Name: "HDMI1", Connected: true}}, rule); err != nil {
+		t.Fatalf("ApplyRule returned error: %v", err)
+	}
+
+	for _, msg := range []string{"executing command for rule Docked failed", "executing hook for rule Docked failed"} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("%q not written to the error output, got %q", msg, buf.String())
+		}
+	}
+}
+
+func TestApplyRuleWithoutConfig(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, cfg *Config) {
+		runCommand = run
+		globalOpts.cfg = cfg
+	}(runCommand, globalOpts.cfg)
+
+	runCommand = func(cmd *exec.Cmd) error { return nil }
+	globalOpts.cfg = nil
+
+	result, err := ApplyRule(randr.Outputs{{Name: "HDMI1", Connected: true}}, randr.Rule{Name: "Docked", ConfigureSingle: "HDMI1"})
+	if err != nil {
+		t.Fatalf("ApplyRule returned error: %v", err)
+	}
+
+	if len(result.Commands) == 0 {
+		t.Errorf("no commands run")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"pkg/randr"
+)
+
+type CmdCheck struct{}
+
+func init() {
+	_, err := parser.AddCommand("check",
+		"check the config",
+		"The check command validates all rules and reports whether they match the current outputs and whether the outputs and modes they reference exist",
+		&CmdCheck{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ruleReport is the result of checking a rule against the current outputs.
+type ruleReport struct {
+	Name  string
+	Match bool
+
+	// Errors lists the problems which make the rule invalid, Warnings the
+	// problems with the current outputs.
+	Errors   []string
+	Warnings []string
+}
+
+// referencedOutputs returns the names of the outputs the rule configures or
+// requires to be present, patterns are left out.
+func referencedOutputs(rule randr.Rule) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name == "" || seen[name] || strings.ContainsAny(name, "*?[") {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	for _, name := range rule.OutputsConnected {
+		add(name)
+	}
+	for _, name := range rule.OutputsPresent {
+		add(name)
+	}
+	for _, entry := range rowEntries(rule) {
+		name, _, _ := randr.SplitEntry(entry)
+		add(name)
+	}
+	// fallbacks for the primary output need not be present
+	if candidates := rule.PrimaryCandidates(); len(candidates) == 1 {
+		add(candidates[0])
+	}
+
+	return names
+}
+
+// rowEntries returns the entries of ConfigureSingle or ConfigureRow.
+func rowEntries(rule randr.Rule) []string {
+	if rule.ConfigureSingle != "" {
+		return []string{rule.ConfigureSingle}
+	}
+	return rule.ConfigureRow
+}
+
+// checkRule validates rule and checks it against the outputs.
+func checkRule(rule randr.Rule, outputs randr.Outputs, opts randr.Options) ruleReport {
+	r := ruleReport{Name: rule.Name}
+
+	if err := validRule(rule, opts.GammaPresets); err != nil {
+		r.Errors = append(r.Errors, err.Error())
+	}
+
+	row := rowEntries(rule)
+	if len(row) == 0 && rule.ConfigureCommand == "" && !rule.SingleExternal {
+		r.Errors = append(r.Errors, "no output configuration")
+	}
+
+	for _, name := range referencedOutputs(rule) {
+		if !outputs.Present(name) {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("output %v is not present", name))
+		}
+	}
+
+	for _, entry := range row {
+		name, spec, _ := randr.SplitEntry(entry)
+		mode := strings.SplitN(spec, "@", 2)[0]
+		// modes with a tolerance or only a width are checked when the
+		// commands are built
+		if mode == "" || strings.Contains(mode, "~") || !outputs.Connected(name) {
+			continue
+		}
+
+		if strings.HasSuffix(mode, "x") || strings.HasPrefix(mode, "w") {
+			continue
+		}
+
+		if !outputs.SupportsMode(name, mode) {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("output %v does not support mode %v", name, mode))
+		}
+	}
+
+	if len(r.Errors) > 0 {
+		return r
+	}
+
+	r.Match = rule.Match(outputs)
+
+	if len(row) > 0 || rule.SingleExternal {
+		if _, err := randr.BuildCommandOutputRow(rule, outputs, opts); err != nil {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("cannot be applied to the current outputs: %v", err))
+		}
+	}
+
+	return r
+}
+
+// printReports writes the reports to w.
+func printReports(w io.Writer, reports []ruleReport) {
+	for _, r := range reports {
+		state := "does not match"
+		switch {
+		case len(r.Errors) > 0:
+			state = "invalid"
+		case r.Match:
+			state = "matches"
+		}
+
+		fmt.Fprintf(w, "rule %v: %v\n", r.Name, state)
+		for _, e := range r.Errors {
+			fmt.Fprintf(w, "  error: %v\n", e)
+		}
+		for _, warn := range r.Warnings {
+			fmt.Fprintf(w, "  warning: %v\n", warn)
+		}
+	}
+}
+
+// checkConfig checks all rules of cfg against the outputs and prints the
+// results to w. It returns an error if the config or a rule is invalid.
+func checkConfig(w io.Writer, cfg Config, outputs randr.Outputs) error {
+	if err := cfg.validOptions(); err != nil {
+		return err
+	}
+
+	var reports []ruleReport
+	var invalid int
+	for _, rule := range cfg.Rules {
+		r := checkRule(rule, outputs, cfg.Options())
+		if len(r.Errors) > 0 {
+			invalid++
+		}
+		reports = append(reports, r)
+	}
+
+	printReports(w, reports)
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d rules are invalid", invalid, len(reports))
+	}
+
+	return nil
+}
+
+func (cmd CmdCheck) Execute(args []string) error {
+	if len(args) != 0 {
+		return errors.New("the check command takes no parameters")
+	}
+
+	buf, err := readConfigFile(globalOpts.Config)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := decodeConfig(buf)
+	if err != nil {
+		return err
+	}
+
+	// the outputs are queried as configured, even if the config is invalid
+	globalOpts.cfg = &cfg
+
+	outputs, err := GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	return checkConfig(os.Stdout, cfg, outputs)
+}
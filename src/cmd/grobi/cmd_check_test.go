@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testConfigCheck = `
+rules:
+  - name: Docked
+    outputs_connected: [HDMI]
+    configure_row: [LVDS, HDMI@1920x1080]
+  - name: Projector
+    outputs_connected: [DP9]
+    configure_row: [LVDS, VGA@800x600]
+  - name: Broken
+    configure_single: HDMI
+    rotate:
+      HDMI: upside-down
+  - name: Empty
+    outputs_connected: [LVDS]
+  - name: Dim
+    configure_single: LVDS
+    gamma:
+      LVDS: dusk
+`
+
+func TestCheckConfig(t *testing.T) {
+	cfg, err := decodeConfig([]byte(testConfigCheck))
+	if err != nil {
+		t.Fatalf("decodeConfig returned error: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = checkConfig(buf, cfg, testOutputs)
+	if err == nil {
+		t.Errorf("invalid rules did not return an error")
+	}
+
+	want := `rule Docked: matches
+rule Projector: does not match
+  warning: output DP9 is not present
+  warning: output VGA does not support mode 800x600
+rule Broken: invalid
+  error: invalid rotation "upside-down" for output HDMI
+rule Empty: invalid
+  error: no output configuration
+rule Dim: invalid
+  error: rule Dim, output LVDS: unknown gamma preset "dusk"
+`
+
+	if buf.String() != want {
+		t.Errorf("wrong report:\nwant:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// CmdCommands is "plan RULE" by another name, the rule is required.
+type CmdCommands struct {
+	CmdPlan
+}
+
+func init() {
+	_, err := parser.AddCommand("commands",
+		"print the commands of a rule",
+		"The commands command prints the commands which would configure the outputs for the given rule, whether it matches or not, without running anything. "+
+			"It is the same as 'grobi plan RULE'. With --randr-input, the outputs are read from a file captured with 'grobi dump', e.g. from someone else's machine",
+		&CmdCommands{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (cmd CmdCommands) Usage() string {
+	return "commands RULE"
+}
+
+func (cmd CmdCommands) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("the commands command takes exactly one rule name as the parameter")
+	}
+
+	globalOpts.ReadConfigfile()
+	return cmd.run(os.Stdout, args[0])
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v2"
+	"pkg/randr"
+)
+
+type CmdCurrent struct {
+	AsRule bool `long:"as-rule" description:"Print the layout as a rule for the config file"`
+}
+
+func init() {
+	_, err := parser.AddCommand("current",
+		"print the current layout",
+		"The current command prints the layout of the active outputs, with --as-rule as a rule which can be pasted into the config file and edited",
+		&CmdCurrent{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// currentRule is the part of a rule printed by the current command, unset
+// settings are left out.
+type currentRule struct {
+	Name             string            `yaml:"name"`
+	OutputsConnected []string          `yaml:"outputs_connected"`
+	ConfigureRow     []string          `yaml:"configure_row"`
+	Primary          string            `yaml:"primary,omitempty"`
+	Rotate           map[string]string `yaml:"rotate,omitempty"`
+}
+
+// formatCurrentRule writes the layout of the active outputs to w as a list
+// containing a single rule in YAML. The outputs are configured in a row from
+// left to right, rotations other than normal are kept.
+func formatCurrentRule(w io.Writer, outputs randr.Outputs) error {
+	snap := NewSnapshot(outputs)
+	if len(snap.Outputs) == 0 {
+		return errors.New("no active outputs found")
+	}
+
+	rule := currentRule{Name: "current"}
+	for _, o := range snap.Outputs {
+		rule.OutputsConnected = append(rule.OutputsConnected, o.Name)
+		rule.ConfigureRow = append(rule.ConfigureRow, o.Name+"@"+o.Mode)
+
+		if o.Rotation != "" && o.Rotation != "normal" {
+			if rule.Rotate == nil {
+				rule.Rotate = make(map[string]string)
+			}
+			rule.Rotate[o.Name] = o.Rotation
+		}
+
+		if o.Primary {
+			rule.Primary = o.Name
+		}
+	}
+
+	buf, err := yaml.Marshal([]currentRule{rule})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+func (cmd CmdCurrent) Execute(args []string) error {
+	if len(args) != 0 {
+		return errors.New("the current command takes no parameters")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	outputs, err := GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	if cmd.AsRule {
+		return formatCurrentRule(os.Stdout, outputs)
+	}
+
+	fmt.Println(outputs.Layout())
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"pkg/randr"
+)
+
+func TestFormatCurrentRule(t *testing.T) {
+	outputs := randr.Outputs{
+		{
+			Name:      "HDMI1",
+			Connected: true,
+			Modes:     []randr.Mode{{Name: "1920x1200", Active: true}},
+			Offset:    randr.Offset{X: 1920},
+			Rotation:  "left",
+		},
+		{
+			Name:      "eDP1",
+			Connected: true,
+			Primary:   true,
+			Modes:     []randr.Mode{{Name: "1920x1080", Default: true, Active: true}},
+			Rotation:  "normal",
+		},
+		{
+			Name:      "DP1",
+			Connected: true,
+			Modes:     []randr.Mode{{Name: "2560x1440", Default: true}},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := formatCurrentRule(buf, outputs); err != nil {
+		t.Fatalf("formatCurrentRule returned error: %v", err)
+	}
+
+	want := `- name: current
+  outputs_connected:
+  - eDP1
+  - HDMI1
+  configure_row:
+  - eDP1@1920x1080
+  - HDMI1@1920x1200
+  primary: eDP1
+  rotate:
+    HDMI1: left
+`
+	if buf.String() != want {
+		t.Errorf("wrong output:\nwant:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if err := formatCurrentRule(buf, outputs[2:]); err == nil {
+		t.Errorf("no error without active outputs")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+type CmdDump struct {
+	Detect bool `short:"d" long:"detect" description:"Query the outputs like after a change was detected (rescanning them by default) instead of like when polling"`
+}
+
+func init() {
+	_, err := parser.AddCommand("dump",
+		"print raw xrandr output",
+		"The dump command runs xrandr exactly like grobi does and prints its output without parsing it, "+
+			"which is useful for bug reports",
+		&CmdDump{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// dumpXrandr writes the raw output of xrandr to w, queried like
+// DetectOutputs if detect is true and like GetOutputs otherwise. The output
+// is not restricted to the outputs of the configured seat, so that bug
+// reports show all of them.
+func dumpXrandr(w io.Writer, detect bool) error {
+	cfg := globalOpts.config()
+	variant, def := cfg.GetQuery, queryCurrent
+	if detect {
+		variant, def = cfg.DetectQuery, queryRescan
+	}
+
+	args, err := queryArgs(variant, def)
+	if err != nil {
+		return err
+	}
+
+	buf, err := rawXrandr(args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+func (cmd CmdDump) Execute(args []string) error {
+	if len(args) != 0 {
+		return errors.New("the dump command takes no parameters")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	return dumpXrandr(os.Stdout, cmd.Detect)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+const testXrandrDump = `Screen 0: minimum 8 x 8, current 1366 x 768, maximum 32767 x 32767
+LVDS1 connected primary 1366x768+0+0 (normal left inverted right x axis y axis) 277mm x 156mm
+   1366x768      60.02*+
+VGA1 disconnected (normal left inverted right x axis y axis)
+`
+
+func TestDumpXrandr(t *testing.T) {
+	defer func(old func(*exec.Cmd) ([]byte, error), cfg *Config) {
+		xrandrOutput = old
+		globalOpts.cfg = cfg
+	}(xrandrOutput, globalOpts.cfg)
+
+	var args []string
+	xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		return []byte(testXrandrDump), nil
+	}
+
+	var tests = []struct {
+		cfg    *Config
+		detect bool
+		args   []string
+	}{
+		{nil, false, []string{"xrandr", "--query", "--current"}},
+		{nil, true, []string{"xrandr", "--query"}},
+		{&Config{GetQuery: "rescan", DetectQuery: "current"}, false, []string{"xrandr", "--query"}},
+		{&Config{GetQuery: "rescan", DetectQuery: "current"}, true, []string{"xrandr", "--query", "--current"}},
+	}
+
+	for _, test := range tests {
+		globalOpts.cfg = test.cfg
+		buf := bytes.NewBuffer(nil)
+		if err := dumpXrandr(buf, test.detect); err != nil {
+			t.Errorf("detect %v: dumpXrandr returned error: %v", test.detect, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("detect %v: wrong xrandr command: want %v, got %v", test.detect, test.args, args)
+		}
+
+		if buf.String() != testXrandrDump {
+			t.Errorf("detect %v: output was modified:\n%v", test.detect, buf.String())
+		}
+	}
+}
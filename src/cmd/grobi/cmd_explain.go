@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"pkg/randr"
+)
+
+type CmdExplain struct{}
+
+func init() {
+	_, err := parser.AddCommand("explain",
+		"explain which rule matches",
+		"The explain command prints every condition of every rule and whether the current outputs satisfy it, followed by the rule which would be applied",
+		&CmdExplain{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// explainRule writes whether rule matches outputs to w, followed by all of
+// its conditions.
+func explainRule(w io.Writer, rule randr.Rule, outputs randr.Outputs) {
+	conds := rule.Conditions(outputs)
+
+	state := "matches"
+	if !rule.Match(outputs) {
+		state = "does not match"
+	}
+	fmt.Fprintf(w, "rule %v: %v\n", rule.Name, state)
+
+	if len(conds) == 0 {
+		fmt.Fprintf(w, "  no conditions\n")
+	}
+
+	for _, c := range conds {
+		result := "failed"
+		if c.Passed {
+			result = "passed"
+		}
+		fmt.Fprintf(w, "  %v: %v\n", c.Name, result)
+	}
+
+	if rule.Priority != 0 {
+		fmt.Fprintf(w, "  priority %d\n", rule.Priority)
+	}
+}
+
+// explainRules writes the conditions of all rules evaluated against outputs
+// to w, followed by the rule SelectRule selects.
+func explainRules(w io.Writer, rules []randr.Rule, outputs randr.Outputs) {
+	for _, rule := range rules {
+		explainRule(w, rule, outputs)
+	}
+
+	if !outputs.AnyConnected() {
+		fmt.Fprintf(w, "no outputs connected, only rule %q is considered\n", defaultRuleName)
+	}
+
+	rule, ok := SelectRule(rules, outputs)
+	if !ok {
+		fmt.Fprintf(w, "no rule selected\n")
+		return
+	}
+
+	fmt.Fprintf(w, "selected rule: %v\n", rule.Name)
+}
+
+func (cmd CmdExplain) Execute(args []string) error {
+	if len(args) != 0 {
+		return errors.New("the explain command takes no parameters")
+	}
+
+	globalOpts.ReadConfigfile()
+	if len(globalOpts.cfg.Rules) == 0 {
+		return errNoRules
+	}
+
+	outputs, err := GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	explainRules(os.Stdout, globalOpts.cfg.Rules, outputs)
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"pkg/randr"
+)
+
+func TestExplainRules(t *testing.T) {
+	rules := []randr.Rule{
+		{
+			Name:             "Docked",
+			OutputsConnected: []string{"HDMI", "DP2-1"},
+			OutputsPresent:   []string{"DP2-1"},
+		},
+		{
+			Name:             "Projector",
+			OutputsConnected: []string{"VGA"},
+		},
+		{
+			Name: "Fallback",
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	explainRules(buf, rules, testOutputs)
+
+	want := "rule Docked: does not match\n" +
+		"  outputs_present DP2-1: passed\n" +
+		"  outputs_connected HDMI: passed\n" +
+		"  outputs_connected DP2-1: failed\n" +
+		"rule Projector: matches\n" +
+		"  outputs_connected VGA: passed\n" +
+		"rule Fallback: matches\n" +
+		"  no conditions\n" +
+		"selected rule: Projector\n"
+	if buf.String() != want {
+		t.Errorf("wrong explanation:\nwant:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
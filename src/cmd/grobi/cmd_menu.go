@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"pkg/randr"
+)
+
+type CmdMenu struct{}
+
+func init() {
+	_, err := parser.AddCommand("menu",
+		"select a rule to apply",
+		"The menu command lists the configured rules and applies the rule selected with the arrow keys (or j and k) and enter, q aborts",
+		&CmdMenu{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// menuEntry is a rule listed in the menu.
+type menuEntry struct {
+	Rule  randr.Rule
+	Match bool
+}
+
+// menuEntries returns the entries of the menu for rules, given the current
+// outputs.
+func menuEntries(rules []randr.Rule, outputs randr.Outputs) []menuEntry {
+	var entries []menuEntry
+	for _, rule := range rules {
+		entries = append(entries, menuEntry{Rule: rule, Match: rule.Match(outputs)})
+	}
+	return entries
+}
+
+// formatMenu writes the menu to w, the entry at cursor is highlighted. It
+// returns the number of lines written.
+func formatMenu(w io.Writer, entries []menuEntry, cursor int) int {
+	width := 0
+	for _, e := range entries {
+		if len(e.Rule.Name) > width {
+			width = len(e.Rule.Name)
+		}
+	}
+
+	for i, e := range entries {
+		prefix := "  "
+		if i == cursor {
+			prefix = "> "
+		}
+
+		var match string
+		if e.Match {
+			match = "  (matches)"
+		}
+
+		line := fmt.Sprintf("%s%-*s%s", prefix, width, e.Rule.Name, match)
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	return len(entries)
+}
+
+// key is a key pressed in the menu.
+type key int
+
+const (
+	keyOther key = iota
+	keyUp
+	keyDown
+	keyEnter
+	keyQuit
+)
+
+// readKey reads the next key from rd.
+func readKey(rd *bufio.Reader) (key, error) {
+	b, err := rd.ReadByte()
+	if err != nil {
+		return keyOther, err
+	}
+
+	switch b {
+	case '\r', '\n':
+		return keyEnter, nil
+	case 'q', 3, 4: // q, ctrl-c and ctrl-d
+		return keyQuit, nil
+	case 'k':
+		return keyUp, nil
+	case 'j':
+		return keyDown, nil
+	case 27:
+		// arrow keys are sent as ESC [ A (up) and ESC [ B (down)
+		if b, err = rd.ReadByte(); err != nil || b != '[' {
+			return keyOther, err
+		}
+
+		b, err = rd.ReadByte()
+		switch {
+		case err != nil:
+			return keyOther, err
+		case b == 'A':
+			return keyUp, nil
+		case b == 'B':
+			return keyDown, nil
+		}
+	}
+
+	return keyOther, nil
+}
+
+// runMenu displays the menu on w and reads keys from rd until an entry is
+// selected. It returns the index of the selected entry, or false if the menu
+// was aborted.
+func runMenu(rd io.Reader, w io.Writer, entries []menuEntry) (int, bool, error) {
+	if len(entries) == 0 {
+		return 0, false, errNoRules
+	}
+
+	// start at the first matching rule
+	cursor := 0
+	for i, e := range entries {
+		if e.Match {
+			cursor = i
+			break
+		}
+	}
+
+	keys := bufio.NewReader(rd)
+	lines := formatMenu(w, entries, cursor)
+	for {
+		k, err := readKey(keys)
+		if err == io.EOF {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+
+		switch k {
+		case keyEnter:
+			return cursor, true, nil
+		case keyQuit:
+			return 0, false, nil
+		case keyUp:
+			if cursor > 0 {
+				cursor--
+			}
+		case keyDown:
+			if cursor < len(entries)-1 {
+				cursor++
+			}
+		default:
+			continue
+		}
+
+		// move up and clear the previous menu before drawing it again
+		fmt.Fprintf(w, "\033[%dA\033[J", lines)
+		lines = formatMenu(w, entries, cursor)
+	}
+}
+
+// stty runs stty with args on the terminal and returns the output.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (cmd CmdMenu) Execute(args []string) error {
+	globalOpts.ReadConfigfile()
+
+	outputs, err := GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	entries := menuEntries(globalOpts.cfg.Rules, outputs)
+
+	// read single key presses without echo
+	state, err := stty("-g")
+	if err != nil {
+		return fmt.Errorf("stdin is not a terminal: %v", err)
+	}
+
+	if _, err = stty("-icanon", "-echo", "-isig"); err != nil {
+		return err
+	}
+
+	i, ok, err := runMenu(os.Stdin, os.Stdout, entries)
+	if _, e := stty(state); e != nil && err == nil {
+		err = e
+	}
+
+	if err != nil || !ok {
+		return err
+	}
+
+	outputs, err = DetectOutputs()
+	if err != nil {
+		return err
+	}
+
+	verbosePrintf("applying rule %v\n", entries[i].Rule.Name)
+	_, err = ApplyRule(outputs, entries[i].Rule)
+	return err
+}
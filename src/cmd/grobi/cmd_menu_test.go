@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"pkg/randr"
+)
+
+var testMenuRules = []randr.Rule{
+	{Name: "Docked", OutputsConnected: []string{"DP9"}},
+	{Name: "Projector", OutputsConnected: []string{"VGA"}},
+	{Name: "Mobile"},
+}
+
+func TestFormatMenu(t *testing.T) {
+	entries := menuEntries(testMenuRules, testOutputs)
+
+	buf := bytes.NewBuffer(nil)
+	n := formatMenu(buf, entries, 1)
+
+	want := strings.Join([]string{
+		"  Docked",
+		"> Projector  (matches)",
+		"  Mobile     (matches)",
+	}, "\n") + "\n"
+
+	if buf.String() != want {
+		t.Errorf("wrong menu:\nwant:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if n != 3 {
+		t.Errorf("wrong number of lines: want 3, got %d", n)
+	}
+}
+
+func TestRunMenu(t *testing.T) {
+	entries := menuEntries(testMenuRules, testOutputs)
+
+	var tests = []struct {
+		input string
+		index int
+		ok    bool
+	}{
+		{"\r", 1, true},
+		{"\x1b[B\n", 2, true},
+		{"\x1b[A\x1b[A\x1b[A\r", 0, true},
+		{"jjjjk\r", 1, true},
+		{"jq", 0, false},
+		{"x", 0, false},
+	}
+
+	for _, test := range tests {
+		i, ok, err := runMenu(strings.NewReader(test.input), &bytes.Buffer{}, entries)
+		if err != nil {
+			t.Errorf("input %q: returned error: %v", test.input, err)
+			continue
+		}
+
+		if ok != test.ok || i != test.index {
+			t.Errorf("input %q: want (%v, %v), got (%v, %v)", test.input, test.index, test.ok, i, ok)
+		}
+	}
+}
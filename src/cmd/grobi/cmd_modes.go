@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"pkg/randr"
+)
+
+type CmdModes struct{}
+
+func init() {
+	_, err := parser.AddCommand("modes",
+		"list modes of an output",
+		"The modes command lists the modes and refresh rates xrandr reports for an output, "+
+			"the active mode is marked with *, the default mode with +",
+		&CmdModes{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (cmd CmdModes) Usage() string {
+	return "modes OUTPUT"
+}
+
+// formatModes writes the modes of the named output to w, one per line with
+// the refresh rates followed by the markers for the active and default mode.
+func formatModes(w io.Writer, outputs randr.Outputs, name string) error {
+	o, ok := outputs.Get(name)
+	if !ok {
+		return fmt.Errorf("output %v not found", name)
+	}
+
+	if !o.Connected {
+		return fmt.Errorf("output %v is not connected", name)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, mode := range o.Modes {
+		var rates []string
+		for _, r := range mode.Refresh {
+			rates = append(rates, strconv.FormatFloat(r, 'f', 2, 64))
+		}
+
+		var markers string
+		if mode.Active {
+			markers += "*"
+		}
+		if mode.Default {
+			markers += "+"
+		}
+		if markers != "" {
+			rates = append(rates, markers)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\n", mode.Name, strings.Join(rates, " "))
+	}
+
+	return tw.Flush()
+}
+
+func (cmd CmdModes) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("need exactly one output name as the parameter")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	outputs, err := GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	return formatModes(os.Stdout, outputs, args[0])
+}
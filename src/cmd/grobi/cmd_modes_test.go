@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"pkg/randr"
+)
+
+func TestFormatModes(t *testing.T) {
+	outputs := randr.Outputs{
+		{
+			Name:      "DP1",
+			Connected: true,
+			Modes: []randr.Mode{
+				{Name: "2560x1440", Default: true, Refresh: []float64{59.95}},
+				{Name: "1920x1080", Active: true, Refresh: []float64{60, 59.94, 50}},
+				{Name: "1280x720", Refresh: []float64{60}},
+			},
+		},
+		{Name: "HDMI1"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := formatModes(buf, outputs, "DP1"); err != nil {
+		t.Fatalf("formatModes returned error: %v", err)
+	}
+
+	want := "2560x1440  59.95 +\n" +
+		"1920x1080  60.00 59.94 50.00 *\n" +
+		"1280x720   60.00\n"
+	if buf.String() != want {
+		t.Errorf("wrong output:\nwant:\n%q\ngot:\n%q", want, buf.String())
+	}
+
+	for _, name := range []string{"HDMI1", "VGA1"} {
+		if err := formatModes(buf, outputs, name); err == nil {
+			t.Errorf("no error for output %v", name)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"pkg/randr"
+)
+
+type CmdMonitor struct{}
+
+func init() {
+	_, err := parser.AddCommand("monitor",
+		"print output changes",
+		"The monitor command watches the outputs and prints a line of JSON describing the changes whenever an output changed, without applying any rules",
+		&CmdMonitor{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// monitorEvent is printed by the monitor command when the outputs changed.
+type monitorEvent struct {
+	Time time.Time `json:"time"`
+	randr.Change
+}
+
+// monitor holds the state of the monitor loop.
+type monitor struct {
+	lastOutputs randr.Outputs
+	enc         *json.Encoder
+
+	// getOutputs and detectOutputs return the current outputs and now the
+	// current time, they are replaced in tests.
+	getOutputs    func() (randr.Outputs, error)
+	detectOutputs func() (randr.Outputs, error)
+	now           func() time.Time
+}
+
+func newMonitor(wr io.Writer) *monitor {
+	return &monitor{
+		enc:           json.NewEncoder(wr),
+		getOutputs:    GetOutputs,
+		detectOutputs: DetectOutputs,
+		now:           time.Now,
+	}
+}
+
+// poll queries the outputs, rescanning them if detect is true, and prints an
+// event if they changed since the last call. The first call only records the
+// outputs. It returns whether an event was printed.
+func (m *monitor) poll(detect bool) (bool, error) {
+	query := m.getOutputs
+	if detect {
+		query = m.detectOutputs
+	}
+
+	outputs, err := query()
+	if err != nil {
+		return false, err
+	}
+
+	last := m.lastOutputs
+	m.lastOutputs = outputs
+	if last == nil {
+		return false, nil
+	}
+
+	change := randr.Diff(last, outputs)
+	if change.Empty() {
+		return false, nil
+	}
+
+	err = m.enc.Encode(monitorEvent{Time: m.now(), Change: change})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (cmd CmdMonitor) Execute(args []string) error {
+	if len(args) != 0 {
+		return errors.New("the monitor command takes no parameters")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan Event)
+	go subscribeXEvents(ch, done)
+
+	var tickerCh <-chan time.Time
+	if globalOpts.PollInterval > 0 {
+		tickerCh = time.NewTicker(time.Duration(globalOpts.PollInterval) * time.Second).C
+	}
+
+	// the outputs are only rescanned after the X server reported a change
+	m := newMonitor(os.Stdout)
+	var detect bool
+	for {
+		if _, err := m.poll(detect); err != nil {
+			return err
+		}
+
+		select {
+		case ev := <-ch:
+			if ev.Error != nil {
+				return ev.Error
+			}
+			detect = true
+		case <-tickerCh:
+			detect = false
+		}
+	}
+}
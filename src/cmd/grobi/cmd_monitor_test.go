@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+func TestMonitorPoll(t *testing.T) {
+	mobile := randr.Outputs{
+		{Name: "LVDS1", Connected: true},
+		{Name: "HDMI1"},
+	}
+	docked := randr.Outputs{
+		{Name: "LVDS1", Connected: true},
+		{Name: "HDMI1", Connected: true},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	current := mobile
+	var detected int
+
+	m := newMonitor(buf)
+	m.now = func() time.Time { return time.Unix(1000, 0).UTC() }
+	m.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	m.detectOutputs = func() (randr.Outputs, error) {
+		detected++
+		return current, nil
+	}
+
+	for i, outputs := range []randr.Outputs{mobile, mobile, docked, docked} {
+		current = outputs
+		if _, err := m.poll(i == 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if detected != 1 {
+		t.Errorf("outputs rescanned %d times, want once", detected)
+	}
+
+	want := `{"time":"1970-01-01T00:16:40Z","connected":["HDMI1"]}` + "\n"
+	if buf.String() != want {
+		t.Errorf("wrong events:\n  want %q\n  got  %q", want, buf.String())
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+
+	"pkg/randr"
+)
+
+type CmdOff struct{}
+
+func init() {
+	_, err := parser.AddCommand("off",
+		"turn off outputs",
+		"The off command turns off all connected outputs matching the pattern, without applying any rules",
+		&CmdOff{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (cmd CmdOff) Usage() string {
+	return "off PATTERN"
+}
+
+func (cmd CmdOff) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("need exactly one output pattern as the parameter")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	outputs, err := GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	cmds, err := randr.BuildCommandOff(args[0], outputs, randr.Options{})
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range cmds {
+		if err = RunCommand(cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"pkg/randr"
+)
+
+type CmdPlan struct {
+	RandrInput string `long:"randr-input" description:"Read the outputs from a file with the output of xrandr, e.g. from 'grobi dump', instead of running xrandr"`
+	JSON       bool   `long:"json" description:"Print the plan as JSON"`
+}
+
+func init() {
+	_, err := parser.AddCommand("plan",
+		"print the plan for the outputs",
+		"The plan command selects the rule for the outputs, or uses the given rule, and prints the commands which would configure them without running anything. "+
+			"With --randr-input, the outputs are read from a file captured with 'grobi dump', e.g. for tests",
+		&CmdPlan{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (cmd CmdPlan) Usage() string {
+	return "plan [RULE]"
+}
+
+// Plan describes how a rule would be applied to the outputs.
+type Plan struct {
+	Rule     string     `json:"rule"`
+	Commands [][]string `json:"commands"`
+	Hooks    [][]string `json:"hooks"`
+}
+
+// readOutputsFile returns the outputs parsed from the file name, which
+// contains the output of xrandr.
+func readOutputsFile(name string) (randr.Outputs, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := randr.RandrParse(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return outputs, f.Close()
+}
+
+// findRule returns the rule with the name, ignoring case.
+func findRule(rules []randr.Rule, name string) (randr.Rule, bool) {
+	for _, rule := range rules {
+		if strings.EqualFold(rule.Name, name) {
+			return rule, true
+		}
+	}
+
+	return randr.Rule{}, false
+}
+
+// selectPlan returns the rule and the plan to apply it to outputs. The rule
+// is the one with the name if name is not empty, whether it matches or not,
+// and the one selected for outputs otherwise.
+func selectPlan(rules []randr.Rule, outputs randr.Outputs, name string) (randr.Rule, Plan, error) {
+	if len(rules) == 0 {
+		return randr.Rule{}, Plan{}, errNoRules
+	}
+
+	var (
+		rule randr.Rule
+		ok   bool
+	)
+	if name != "" {
+		rule, ok = findRule(rules, name)
+		if !ok {
+			return randr.Rule{}, Plan{}, fmt.Errorf("rule %q not found", name)
+		}
+	} else {
+		rule, ok = SelectRule(rules, outputs)
+		if !ok {
+			return randr.Rule{}, Plan{}, errors.New("no rule matches the outputs")
+		}
+	}
+
+	cmds, err := ruleCommands(outputs, rule)
+	if err != nil {
+		return randr.Rule{}, Plan{}, err
+	}
+
+	plan := Plan{
+		Rule:     rule.Name,
+		Commands: [][]string{},
+		Hooks:    [][]string{},
+	}
+
+	for _, cmd := range cmds {
+		plan.Commands = append(plan.Commands, cmd.Args)
+	}
+
+	for _, hook := range ruleHooks(rule) {
+		plan.Hooks = append(plan.Hooks, HookCommand(globalOpts.config().HookShell, hook).Args)
+	}
+
+	return rule, plan, nil
+}
+
+// writePlanJSON writes plan to w as indented JSON.
+func writePlanJSON(w io.Writer, plan Plan) error {
+	buf, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", buf)
+	return err
+}
+
+// run writes the plan for the rule with the name (or the selected rule if
+// name is empty) to w. The outputs are only queried if no file is given.
+func (cmd CmdPlan) run(w io.Writer, name string) error {
+	var (
+		outputs randr.Outputs
+		err     error
+	)
+	if cmd.RandrInput != "" {
+		outputs, err = readOutputsFile(cmd.RandrInput)
+	} else {
+		outputs, err = GetOutputs()
+	}
+	if err != nil {
+		return err
+	}
+
+	rule, plan, err := selectPlan(globalOpts.cfg.Rules, outputs, name)
+	if err != nil {
+		return err
+	}
+
+	if cmd.JSON {
+		return writePlanJSON(w, plan)
+	}
+
+	return writePlan(w, outputs, rule)
+}
+
+func (cmd CmdPlan) Execute(args []string) error {
+	if len(args) > 1 {
+		return errors.New("the plan command takes at most one rule name as the parameter")
+	}
+
+	globalOpts.ReadConfigfile()
+
+	var name string
+	if len(args) == 1 {
+		name = args[0]
+	}
+
+	return cmd.run(os.Stdout, name)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const planTestDump = `Screen 0: minimum 320 x 200, current 1600 x 1200, maximum 8192 x 8192
+LVDS1 connected (normal left inverted right x axis y axis)
+   1366x768      60.10 +
+   1024x768      60.00
+VGA1 disconnected (normal left inverted right x axis y axis)
+HDMI2 connected 1600x1200+0+0 (normal left inverted right x axis y axis) 408mm x 306mm
+   1600x1200     60.00*+
+   1280x1024     75.02    60.02
+DP2 disconnected (normal left inverted right x axis y axis)
+`
+
+const planTestConfig = `
+execute_after:
+  - notify-send grobi
+rules:
+  - name: Docked
+    outputs_connected: [HDMI2]
+    configure_row: [LVDS1, HDMI2]
+    primary: HDMI2
+  - name: Mobile
+    configure_single: LVDS1
+`
+
+func TestPlanJSON(t *testing.T) {
+	defer func(cfg *Config) {
+		globalOpts.cfg = cfg
+	}(globalOpts.cfg)
+
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "dump.txt")
+	if err = ioutil.WriteFile(filename, []byte(planTestDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := parseConfig([]byte(planTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	globalOpts.cfg = &cfg
+
+	outputs, err := readOutputsFile(filename)
+	if err != nil {
+		t.Fatalf("readOutputsFile returned error: %v", err)
+	}
+
+	_, plan, err := selectPlan(cfg.Rules, outputs, "")
+	if err != nil {
+		t.Fatalf("selectPlan returned error: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err = writePlanJSON(buf, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "rule": "Docked",
+  "commands": [
+    [
+      "xrandr",
+      "--output",
+      "LVDS1",
+      "--auto"
+    ],
+    [
+      "xrandr",
+      "--output",
+      "HDMI2",
+      "--auto",
+      "--primary",
+      "--right-of",
+      "LVDS1"
+    ]
+  ],
+  "hooks": [
+    [
+      "sh",
+      "-c",
+      "notify-send grobi"
+    ]
+  ]
+}
+`
+	if buf.String() != want {
+		t.Errorf("wrong plan, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if _, _, err = selectPlan(nil, outputs, ""); err != errNoRules {
+		t.Errorf("wrong error for an empty config: %v", err)
+	}
+
+	if _, err = readOutputsFile(filepath.Join(tempdir, "missing")); err == nil {
+		t.Errorf("no error for a missing file")
+	}
+}
+
+func TestPlanRuleRandrInput(t *testing.T) {
+	defer func(cfg *Config, output func(*exec.Cmd) ([]byte, error)) {
+		globalOpts.cfg = cfg
+		xrandrOutput = output
+	}(globalOpts.cfg, xrandrOutput)
+
+	xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) {
+		t.Errorf("xrandr was run: %v", cmd.Args)
+		return nil, errors.New("no xrandr in tests")
+	}
+
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "dump.txt")
+	if err = ioutil.WriteFile(filename, []byte(planTestDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := parseConfig([]byte(planTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	globalOpts.cfg = &cfg
+
+	cmd := CmdPlan{RandrInput: filename, JSON: true}
+
+	// Docked would be selected for the outputs, but Mobile is requested
+	buf := bytes.NewBuffer(nil)
+	if err = cmd.run(buf, "mobile"); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+
+	want := `{
+  "rule": "Mobile",
+  "commands": [
+    [
+      "xrandr",
+      "--output",
+      "HDMI2",
+      "--off"
+    ],
+    [
+      "xrandr",
+      "--output",
+      "LVDS1",
+      "--auto"
+    ]
+  ],
+  "hooks": [
+    [
+      "sh",
+      "-c",
+      "notify-send grobi"
+    ]
+  ]
+}
+`
+	if buf.String() != want {
+		t.Errorf("wrong plan, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if err = cmd.run(bytes.NewBuffer(nil), "Projector"); err == nil {
+		t.Errorf("no error for an unknown rule")
+	}
+}
+
+func TestCommandsRandrInput(t *testing.T) {
+	defer func(cfg *Config, output func(*exec.Cmd) ([]byte, error)) {
+		globalOpts.cfg = cfg
+		xrandrOutput = output
+	}(globalOpts.cfg, xrandrOutput)
+
+	xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) {
+		t.Errorf("xrandr was run: %v", cmd.Args)
+		return nil, errors.New("no xrandr in tests")
+	}
+
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "dump.txt")
+	if err = ioutil.WriteFile(filename, []byte(planTestDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := parseConfig([]byte(planTestConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	globalOpts.cfg = &cfg
+
+	cmd := CmdCommands{CmdPlan{RandrInput: filename}}
+
+	// Docked would be selected for the outputs, but Mobile is requested
+	buf := bytes.NewBuffer(nil)
+	if err = cmd.run(buf, "mobile"); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "  xrandr --output HDMI2 --off\n  xrandr --output LVDS1 --auto\n") {
+		t.Errorf("commands missing in output:\n%s", buf.String())
+	}
+
+	if err = cmd.Execute(nil); err == nil {
+		t.Errorf("no error without a rule name")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "errors"
+
+type CmdRestore struct{}
+
+func init() {
+	_, err := parser.AddCommand("restore",
+		"restore a saved layout",
+		"The restore command applies a layout saved by the save command",
+		&CmdRestore{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (cmd CmdRestore) Usage() string {
+	return "restore NAME"
+}
+
+func (cmd CmdRestore) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("need exactly one snapshot name as the parameter")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	filename, err := snapshotFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	snap, err := readSnapshot(filename)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := DetectOutputs()
+	if err != nil {
+		return err
+	}
+
+	// like any other rule, the snapshot is recorded in the history and the
+	// state file
+	_, err = NewApplier(outputs).Apply(snap.Rule(args[0]))
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type CmdSave struct{}
+
+func init() {
+	_, err := parser.AddCommand("save",
+		"save the current layout",
+		"The save command saves the layout of the active outputs as a named snapshot, which can be applied again with the restore command",
+		&CmdSave{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (cmd CmdSave) Usage() string {
+	return "save NAME"
+}
+
+func (cmd CmdSave) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("need exactly one snapshot name as the parameter")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	filename, err := snapshotFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	outputs, err := GetOutputs()
+	if err != nil {
+		return err
+	}
+
+	snap := NewSnapshot(outputs)
+	if len(snap.Outputs) == 0 {
+		return errors.New("no active outputs found")
+	}
+
+	err = writeSnapshot(filename, snap)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("saved layout to %v\n", filename)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"pkg/randr"
+)
+
+type CmdTest struct{}
+
+func init() {
+	_, err := parser.AddCommand("test",
+		"run the tests in the config",
+		"The test command selects a rule for each scenario in the tests section of the config and reports "+
+			"whether it is the expected one, the outputs of the scenarios are made up and the real ones are not queried",
+		&CmdTest{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ConfigTest is a scenario from the tests section of the config.
+type ConfigTest struct {
+	Name string `yaml:"name"`
+
+	// Connected and Disconnected list the outputs of the scenario, Expect is
+	// the name of the rule which must be selected for them.
+	Connected    []string `yaml:"connected"`
+	Disconnected []string `yaml:"disconnected"`
+	Expect       string   `yaml:"expect"`
+
+	// Hostname, Power ("ac" or "battery"), Time ("HH:MM") and Processes pin
+	// the state of the machine for the conditions of the rules. Conditions
+	// for a state which is not pinned are ignored, so that the result does
+	// not depend on the machine running the tests.
+	Hostname  string   `yaml:"hostname"`
+	Power     string   `yaml:"power"`
+	Time      string   `yaml:"time"`
+	Processes []string `yaml:"processes"`
+}
+
+// Valid returns an error if the expected rule is missing or a pinned state is
+// invalid.
+func (test ConfigTest) Valid() error {
+	if test.Expect == "" {
+		return fmt.Errorf("test %v: no expected rule", test.Name)
+	}
+
+	if _, err := test.Environment(); err != nil {
+		return fmt.Errorf("test %v: %v", test.Name, err)
+	}
+
+	return nil
+}
+
+// Environment returns the state of the machine pinned by the scenario.
+func (test ConfigTest) Environment() (randr.Environment, error) {
+	env := randr.Environment{
+		Hostname:  test.Hostname,
+		Power:     test.Power,
+		Processes: test.Processes,
+	}
+
+	switch test.Power {
+	case "", "ac", "battery":
+	default:
+		return randr.Environment{}, fmt.Errorf("invalid power %q, must be ac or battery", test.Power)
+	}
+
+	if test.Time != "" {
+		t, err := time.ParseInLocation("15:04", test.Time, time.Local)
+		if err != nil {
+			return randr.Environment{}, fmt.Errorf("invalid time %q, must be HH:MM", test.Time)
+		}
+		env.Time = t
+	}
+
+	return env, nil
+}
+
+// Rules returns copies of rules without the conditions on a state of the
+// machine which the scenario does not pin.
+func (test ConfigTest) Rules(rules []randr.Rule) []randr.Rule {
+	res := make([]randr.Rule, 0, len(rules))
+	for _, rule := range rules {
+		if test.Hostname == "" {
+			rule.Hostname = ""
+		}
+		if test.Power == "" {
+			rule.Power = ""
+		}
+		if test.Time == "" {
+			rule.ActiveBetween = ""
+		}
+		if test.Processes == nil {
+			rule.UnlessProcess = ""
+		}
+		res = append(res, rule)
+	}
+
+	return res
+}
+
+// Outputs returns the made up outputs of the scenario. Connected outputs
+// have a single 1920x1080 mode and are not active.
+func (test ConfigTest) Outputs() randr.Outputs {
+	var outputs randr.Outputs
+	for _, name := range test.Connected {
+		outputs = append(outputs, randr.Output{
+			Name:      name,
+			Connected: true,
+			Modes:     []randr.Mode{{Name: "1920x1080", Default: true, Refresh: []float64{60}}},
+		})
+	}
+
+	for _, name := range test.Disconnected {
+		outputs = append(outputs, randr.Output{Name: name})
+	}
+
+	return outputs
+}
+
+// renameOutputs renames the outputs of the scenario according to aliases.
+func (test *ConfigTest) renameOutputs(aliases map[string]string) {
+	for _, list := range [][]string{test.Connected, test.Disconnected} {
+		for i, name := range list {
+			if alias, ok := aliases[name]; ok {
+				list[i] = alias
+			}
+		}
+	}
+}
+
+// runConfigTests selects a rule for the outputs of each test and prints
+// whether it is the expected one to w. It returns an error if a test failed.
+func runConfigTests(w io.Writer, rules []randr.Rule, tests []ConfigTest) error {
+	if len(tests) == 0 {
+		return errors.New("no tests configured")
+	}
+
+	var failed int
+	for _, test := range tests {
+		env, err := test.Environment()
+		if err != nil {
+			return fmt.Errorf("test %v: %v", test.Name, err)
+		}
+
+		restore := randr.SetEnvironment(env)
+		rule, ok := SelectRule(test.Rules(rules), test.Outputs())
+		restore()
+
+		if ok && strings.EqualFold(rule.Name, test.Expect) {
+			fmt.Fprintf(w, "ok    %v: rule %v\n", test.Name, rule.Name)
+			continue
+		}
+
+		failed++
+		if !ok {
+			fmt.Fprintf(w, "FAIL  %v: expected rule %v, no rule matches\n", test.Name, test.Expect)
+			continue
+		}
+		fmt.Fprintf(w, "FAIL  %v: expected rule %v, got rule %v\n", test.Name, test.Expect, rule.Name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, len(tests))
+	}
+
+	return nil
+}
+
+func (cmd CmdTest) Execute(args []string) error {
+	if len(args) != 0 {
+		return errors.New("the test command takes no parameters")
+	}
+
+	globalOpts.ReadConfigfile()
+	return runConfigTests(os.Stdout, globalOpts.cfg.Rules, globalOpts.cfg.Tests)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+const testConfigTests = `
+rules:
+  - name: Docked
+    outputs_connected: [DP1]
+    configure_row: [LVDS1, DP1]
+  - name: Projector
+    outputs_connected: [VGA1]
+    configure_single: VGA1
+  - name: Mobile
+    configure_single: LVDS1
+tests:
+  - name: docking station
+    connected: [LVDS1, DP1]
+    expect: Docked
+  - connected: [LVDS1, VGA1]
+    disconnected: [DP1]
+    expect: docked
+  - name: laptop
+    connected: [LVDS1]
+    expect: Mobile
+`
+
+func TestRunConfigTests(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfigTests))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = runConfigTests(buf, cfg.Rules, cfg.Tests)
+	if err == nil {
+		t.Fatalf("failing test was not reported as an error")
+	}
+
+	if want := "1 of 3 tests failed"; err.Error() != want {
+		t.Errorf("wrong error, want %q, got %q", want, err)
+	}
+
+	want := "ok    docking station: rule Docked\n" +
+		"FAIL  tests[1]: expected rule docked, got rule Projector\n" +
+		"ok    laptop: rule Mobile\n"
+	if buf.String() != want {
+		t.Errorf("wrong report, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if err = runConfigTests(bytes.NewBuffer(nil), cfg.Rules, cfg.Tests[:1]); err != nil {
+		t.Errorf("passing test returned error: %v", err)
+	}
+
+	if _, err = parseConfig([]byte("tests:\n  - connected: [LVDS1]\n")); err == nil {
+		t.Errorf("test without expected rule did not return an error")
+	}
+}
+
+const testConfigTestsEnvironment = `
+rules:
+  - name: Office
+    outputs_connected: [DP1]
+    hostname: work-*
+    active_between: "08:00-18:00"
+    configure_row: [LVDS1, DP1]
+  - name: Presentation
+    outputs_connected: [DP1]
+    power: battery
+    unless_process: vlc
+    configure_single: DP1
+  - name: Mobile
+    configure_single: LVDS1
+tests:
+  - name: unpinned
+    connected: [LVDS1, DP1]
+    expect: Office
+  - name: at home
+    connected: [LVDS1, DP1]
+    hostname: laptop
+    power: battery
+    processes: [bash]
+    expect: Presentation
+  - name: watching a movie
+    connected: [LVDS1, DP1]
+    hostname: laptop
+    power: battery
+    processes: [vlc]
+    expect: Mobile
+  - name: evening
+    connected: [LVDS1, DP1]
+    hostname: work-1
+    time: "19:30"
+    power: ac
+    expect: Mobile
+  - name: morning
+    connected: [LVDS1, DP1]
+    hostname: work-1
+    time: "08:15"
+    expect: Office
+`
+
+func TestRunConfigTestsEnvironment(t *testing.T) {
+	// the conditions must not depend on the machine running the tests
+	restore := randr.SetEnvironment(randr.Environment{
+		Hostname:  "work-2",
+		Power:     "ac",
+		Time:      time.Date(2016, 1, 2, 3, 0, 0, 0, time.Local),
+		Processes: []string{"vlc"},
+	})
+	defer restore()
+
+	cfg, err := parseConfig([]byte(testConfigTestsEnvironment))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err = runConfigTests(buf, cfg.Rules, cfg.Tests); err != nil {
+		t.Errorf("runConfigTests returned error: %v\n%s", err, buf.String())
+	}
+
+	for _, test := range []string{
+		"tests:\n  - connected: [LVDS1]\n    expect: Mobile\n    power: mains\n",
+		"tests:\n  - connected: [LVDS1]\n    expect: Mobile\n    time: 25:00\n",
+	} {
+		if _, err = parseConfig([]byte(test)); err == nil {
+			t.Errorf("invalid test %q did not return an error", test)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"pkg/randr"
+)
+
+type CmdTop struct{}
+
+func init() {
+	_, err := parser.AddCommand("top",
+		"show outputs live",
+		"The top command shows the outputs and a drawing of the layout on the full terminal and redraws them whenever they change, outputs which were connected or disconnected are highlighted. It does not apply any rules",
+		&CmdTop{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Escape sequences the top command uses to redraw the terminal and to
+// highlight outputs.
+const (
+	ansiClear = "\x1b[H\x1b[2J"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// topLayoutWidth is the width of the drawing of the layout in characters.
+const topLayoutWidth = 60
+
+// topHighlight returns the marker and the escape sequence for the output
+// name according to change.
+func topHighlight(name string, change randr.Change) (string, string) {
+	contains := func(list []string) bool {
+		for _, s := range list {
+			if s == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case contains(change.Added), contains(change.Connected):
+		return "+", ansiGreen
+	case contains(change.Disconnected):
+		return "-", ansiRed
+	case contains(change.Changed):
+		return "*", ansiBold
+	}
+
+	return " ", ""
+}
+
+// renderTopFrame writes a frame showing the outputs at time now to w, with
+// a drawing of the layout above them. The outputs listed in change are
+// highlighted, outputs which have been removed are listed below the others.
+func renderTopFrame(w io.Writer, now time.Time, outputs randr.Outputs, change randr.Change) {
+	fmt.Fprintf(w, "%sgrobi top, %v, press Ctrl-C to quit\n", ansiClear, now.Format("15:04:05"))
+	fmt.Fprintf(w, "layout: %v\n\n", outputs.Layout())
+
+	if lines := randr.RenderLayout(outputs, topLayoutWidth); len(lines) > 0 {
+		fmt.Fprintf(w, "%s\n\n", strings.Join(lines, "\n"))
+	}
+
+	width := len("OUTPUT")
+	for _, o := range outputs {
+		if len(o.Name) > width {
+			width = len(o.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "  %-*s  %-12s  %-16s  %-12s  %s\n", width, "OUTPUT", "STATE", "MODE", "POSITION", "VENDOR")
+	for _, o := range outputs {
+		state := "disconnected"
+		if o.Connected {
+			state = "connected"
+		}
+		if o.Primary {
+			state += " *"
+		}
+
+		mode, position := "off", ""
+		if m, ok := o.ActiveMode(); ok {
+			mode = m.Name
+			position = fmt.Sprintf("+%d+%d", o.Offset.X, o.Offset.Y)
+		}
+
+		marker, color := topHighlight(o.Name, change)
+		line := fmt.Sprintf("%s %-*s  %-12s  %-16s  %-12s  %s", marker, width, o.Name, state, mode, position, o.Vendor)
+		line = strings.TrimRight(line, " ")
+		if color != "" {
+			line = color + line + ansiReset
+		}
+		fmt.Fprintf(w, "%s\n", line)
+	}
+
+	for _, name := range change.Removed {
+		fmt.Fprintf(w, "%s- %s (removed)%s\n", ansiRed, name, ansiReset)
+	}
+}
+
+// top holds the state of the top loop.
+type top struct {
+	wr          io.Writer
+	lastOutputs randr.Outputs
+
+	// getOutputs and detectOutputs return the current outputs and now the
+	// current time, they are replaced in tests.
+	getOutputs    func() (randr.Outputs, error)
+	detectOutputs func() (randr.Outputs, error)
+	now           func() time.Time
+}
+
+func newTop(wr io.Writer) *top {
+	return &top{
+		wr:            wr,
+		getOutputs:    GetOutputs,
+		detectOutputs: DetectOutputs,
+		now:           time.Now,
+	}
+}
+
+// poll queries the outputs, rescanning them if detect is true, and draws a
+// frame if they changed since the last call, the first call always draws one.
+// It returns whether a frame was drawn.
+func (t *top) poll(detect bool) (bool, error) {
+	query := t.getOutputs
+	if detect {
+		query = t.detectOutputs
+	}
+
+	outputs, err := query()
+	if err != nil {
+		return false, err
+	}
+
+	last := t.lastOutputs
+	t.lastOutputs = outputs
+
+	var change randr.Change
+	if last != nil {
+		change = randr.Diff(last, outputs)
+		if change.Empty() {
+			return false, nil
+		}
+	}
+
+	renderTopFrame(t.wr, t.now(), outputs, change)
+	return true, nil
+}
+
+func (cmd CmdTop) Execute(args []string) error {
+	if len(args) != 0 {
+		return errors.New("the top command takes no parameters")
+	}
+
+	globalOpts.ReadConfigfileIfPresent()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan Event)
+	go subscribeXEvents(ch, done)
+
+	var tickerCh <-chan time.Time
+	if globalOpts.PollInterval > 0 {
+		tickerCh = time.NewTicker(time.Duration(globalOpts.PollInterval) * time.Second).C
+	}
+
+	// the outputs are only rescanned after the X server reported a change
+	t := newTop(os.Stdout)
+	var detect bool
+	for {
+		if _, err := t.poll(detect); err != nil {
+			return err
+		}
+
+		select {
+		case ev := <-ch:
+			if ev.Error != nil {
+				return ev.Error
+			}
+			detect = true
+		case <-tickerCh:
+			detect = false
+		}
+	}
+}
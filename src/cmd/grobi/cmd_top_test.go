@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+func TestTopPoll(t *testing.T) {
+	mobile := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Primary: true, Modes: []randr.Mode{{Name: "1366x768", Active: true}}},
+		{Name: "HDMI1"},
+	}
+	docked := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Active: true}}},
+		{Name: "HDMI1", Connected: true, Vendor: "DEL", Offset: randr.Offset{X: 1366},
+			Modes: []randr.Mode{{Name: "1920x1080", Active: true}}},
+	}
+	undocked := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Active: true}}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	current := mobile
+
+	tp := newTop(buf)
+	tp.now = func() time.Time { return time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC) }
+	var detected int
+	tp.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	tp.detectOutputs = func() (randr.Outputs, error) {
+		detected++
+		return current, nil
+	}
+
+	var frames []string
+	for i, outputs := range []randr.Outputs{mobile, mobile, docked, docked, undocked} {
+		current = outputs
+		buf.Reset()
+
+		// every other poll follows an event
+		drawn, err := tp.poll(i%2 == 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if drawn != (buf.Len() > 0) {
+			t.Fatalf("poll returned %v, but wrote %q", drawn, buf.String())
+		}
+
+		if drawn {
+			frames = append(frames, buf.String())
+		}
+	}
+
+	if detected != 2 {
+		t.Errorf("outputs were rescanned %d times, want 2", detected)
+	}
+
+	drawing := func(outputs randr.Outputs) string {
+		return strings.Join(randr.RenderLayout(outputs, topLayoutWidth), "\n") + "\n\n"
+	}
+
+	want := []string{
+		ansiClear + "grobi top, 15:04:05, press Ctrl-C to quit\n" +
+			"layout: LVDS1@1366x768+0+0\n\n" +
+			drawing(mobile) +
+			"  OUTPUT  STATE         MODE              POSITION      VENDOR\n" +
+			"  LVDS1   connected *   1366x768          +0+0\n" +
+			"  HDMI1   disconnected  off\n",
+		ansiClear + "grobi top, 15:04:05, press Ctrl-C to quit\n" +
+			"layout: LVDS1@1366x768+0+0 HDMI1@1920x1080+1366+0\n\n" +
+			drawing(docked) +
+			"  OUTPUT  STATE         MODE              POSITION      VENDOR\n" +
+			ansiBold + "* LVDS1   connected     1366x768          +0+0" + ansiReset + "\n" +
+			ansiGreen + "+ HDMI1   connected     1920x1080         +1366+0       DEL" + ansiReset + "\n",
+		ansiClear + "grobi top, 15:04:05, press Ctrl-C to quit\n" +
+			"layout: LVDS1@1366x768+0+0\n\n" +
+			drawing(undocked) +
+			"  OUTPUT  STATE         MODE              POSITION      VENDOR\n" +
+			"  LVDS1   connected     1366x768          +0+0\n" +
+			ansiRed + "- HDMI1 (removed)" + ansiReset + "\n",
+	}
+
+	if len(frames) != len(want) {
+		t.Fatalf("wrong number of frames: want %d, got %d:\n%v", len(want), len(frames), strings.Join(frames, "\n"))
+	}
+
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Errorf("frame %d is wrong:\n  want %q\n  got  %q", i, want[i], frames[i])
+		}
+	}
+}
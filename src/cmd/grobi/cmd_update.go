@@ -1,5 +1,12 @@
 package main
 
+import (
+	"errors"
+	"strings"
+
+	"pkg/randr"
+)
+
 type CmdUpdate struct{}
 
 func init() {
@@ -12,19 +19,68 @@ func init() {
 	}
 }
 
-func MatchRules(rules []Rule, outputs Outputs) error {
+// defaultRuleName is the name of the only rule which is considered when no
+// output is connected.
+const defaultRuleName = "default"
+
+// errNoRules is returned when a rule should be selected but the config does
+// not contain any rules.
+var errNoRules = errors.New("no rules configured")
+
+// noneConnectedLogged records whether the absence of connected outputs has
+// been logged already, so that the watch loop does not repeat the message.
+var noneConnectedLogged bool
+
+// SelectRule returns the matching rule with the highest priority, of several
+// rules with the same priority the first one wins. When no output is
+// connected, only a rule named "default" is considered.
+func SelectRule(rules []randr.Rule, outputs randr.Outputs) (randr.Rule, bool) {
+	noneConnected := !outputs.AnyConnected()
+	if !noneConnected {
+		noneConnectedLogged = false
+	} else if !noneConnectedLogged {
+		verbosePrintf("no outputs connected, only considering rule %q\n", defaultRuleName)
+		noneConnectedLogged = true
+	}
+
+	var selected randr.Rule
+	var found bool
 	for _, rule := range rules {
-		if rule.Match(outputs) {
-			verbosePrintf("found matching rule (name %v)\n", rule.Name)
-			if err := ApplyRule(outputs, rule); err != nil {
-				return err
-			}
+		if noneConnected && strings.ToLower(rule.Name) != defaultRuleName {
+			continue
+		}
+
+		if found && rule.Priority <= selected.Priority {
+			continue
+		}
 
-			return nil
+		if rule.Match(outputs) {
+			selected, found = rule, true
 		}
 	}
 
-	return nil
+	if found {
+		verbosePrintf("found matching rule (name %v)\n", selected.Name)
+	}
+
+	return selected, found
+}
+
+// MatchRules applies the rule selected for the outputs. It returns errNoRules
+// if rules is empty.
+func MatchRules(rules []randr.Rule, outputs randr.Outputs) error {
+	if len(rules) == 0 {
+		return errNoRules
+	}
+
+	a := NewApplier(outputs)
+	rule, ok := a.SelectRule(rules)
+	if !ok {
+		return nil
+	}
+
+	_, err := a.Apply(rule)
+	return err
 }
 
 func (cmd CmdUpdate) Execute(args []string) error {
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"pkg/randr"
+)
+
+var testOutputs = randr.Outputs{
+	{
+		Name:      "LVDS",
+		Connected: true,
+		Modes: []randr.Mode{
+			{Name: "1377x768", Default: true, Active: true},
+			{Name: "1024x768"},
+		},
+	},
+	{
+		Name:      "VGA",
+		Connected: true,
+		Modes: []randr.Mode{
+			{Name: "1280x1024", Default: true},
+			{Name: "1024x768", Active: true},
+		},
+	},
+	{
+		Name:      "HDMI",
+		Connected: true,
+		Modes: []randr.Mode{
+			{Name: "1920x1080", Default: true, Active: true},
+			{Name: "1024x768"},
+		},
+	},
+	{
+		Name: "DP2-1",
+	},
+}
+
+func testCommandArgs(cmds []*exec.Cmd) [][]string {
+	var args [][]string
+	for _, cmd := range cmds {
+		args = append(args, cmd.Args)
+	}
+	return args
+}
+
+func TestMatchRulesNoneConnected(t *testing.T) {
+	defer func(old func(*exec.Cmd) error, cfg *Config) {
+		runCommand = old
+		globalOpts.cfg = cfg
+	}(runCommand, globalOpts.cfg)
+
+	var cmds []*exec.Cmd
+	runCommand = func(cmd *exec.Cmd) error {
+		cmds = append(cmds, cmd)
+		return nil
+	}
+	globalOpts.cfg = &Config{}
+
+	outputs := randr.Outputs{
+		{Name: "LVDS1"},
+		{Name: "HDMI1"},
+	}
+
+	rules := []randr.Rule{
+		{Name: "Mobile", OutputsDisconnected: []string{"HDMI1"}, ConfigureSingle: "LVDS1"},
+	}
+
+	if err := MatchRules(rules, outputs); err != nil {
+		t.Fatalf("MatchRules returned error: %v", err)
+	}
+
+	if len(cmds) != 0 {
+		t.Errorf("commands were run although no output is connected: %v", cmds)
+	}
+
+	rules = append(rules, randr.Rule{Name: "Default", ConfigureCommand: "true"})
+	if err := MatchRules(rules, outputs); err != nil {
+		t.Fatalf("MatchRules returned error: %v", err)
+	}
+
+	if len(cmds) != 1 {
+		t.Errorf("default rule was not applied, commands: %v", cmds)
+	}
+}
+
+func TestMatchRulesEmpty(t *testing.T) {
+	defer func(old func(*exec.Cmd) error) { runCommand = old }(runCommand)
+	runCommand = func(cmd *exec.Cmd) error {
+		t.Errorf("command run without rules: %v", cmd.Args)
+		return nil
+	}
+
+	cfg, err := parseConfig([]byte("rules: []\n"))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	err = MatchRules(cfg.Rules, testOutputs)
+	if err == nil || err.Error() != "no rules configured" {
+		t.Errorf("wrong error for empty config: %v", err)
+	}
+
+	cfg, err = parseConfig(nil)
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	if err = MatchRules(cfg.Rules, testOutputs); err != errNoRules {
+		t.Errorf("wrong error for empty config file: %v", err)
+	}
+}
+
+func TestSelectRuleLogsName(t *testing.T) {
+	defer func(old io.Writer, verbose bool) {
+		verboseOutput = old
+		globalOpts.Verbose = verbose
+	}(verboseOutput, globalOpts.Verbose)
+
+	buf := bytes.NewBuffer(nil)
+	verboseOutput = buf
+	globalOpts.Verbose = true
+
+	rules := []randr.Rule{
+		{Name: "Docked", OutputsConnected: []string{"DP9"}},
+		{Name: "Projector", OutputsConnected: []string{"VGA"}},
+	}
+
+	rule, ok := SelectRule(rules, testOutputs)
+	if !ok || rule.Name != "Projector" {
+		t.Fatalf("wrong rule selected: %v", rule.Name)
+	}
+
+	if !strings.Contains(buf.String(), "found matching rule (name Projector)") {
+		t.Errorf("rule name not logged, output: %q", buf.String())
+	}
+}
+
+func TestSelectRulePriority(t *testing.T) {
+	var tests = []struct {
+		rules []randr.Rule
+		name  string
+	}{
+		{
+			[]randr.Rule{
+				{Name: "Laptop", OutputsConnected: []string{"LVDS"}},
+				{Name: "Projector", OutputsConnected: []string{"VGA"}, Priority: 10},
+			},
+			"Projector",
+		},
+		{
+			[]randr.Rule{
+				{Name: "Laptop", OutputsConnected: []string{"LVDS"}, Priority: 5},
+				{Name: "Projector", OutputsConnected: []string{"VGA"}, Priority: 5},
+			},
+			"Laptop",
+		},
+		{
+			[]randr.Rule{
+				{Name: "Laptop", OutputsConnected: []string{"LVDS"}, Priority: -1},
+				{Name: "Docked", OutputsConnected: []string{"DP9"}, Priority: 10},
+				{Name: "Projector", OutputsConnected: []string{"VGA"}},
+			},
+			"Projector",
+		},
+	}
+
+	for i, test := range tests {
+		rule, ok := SelectRule(test.rules, testOutputs)
+		if !ok || rule.Name != test.name {
+			t.Errorf("test %d: wrong rule selected: want %v, got %v", i, test.name, rule.Name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"pkg/randr"
+)
+
+type CmdWait struct {
+	Timeout time.Duration `short:"t" long:"timeout" default:"5s" description:"Give up after this duration"`
+}
+
+func init() {
+	_, err := parser.AddCommand("wait",
+		"wait for an output",
+		"The wait command polls the outputs until an output matching the pattern is connected or the timeout elapses",
+		&CmdWait{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (cmd CmdWait) Usage() string {
+	return "wait PATTERN"
+}
+
+// waitPollInterval is the time between two polls of the outputs.
+var waitPollInterval = 250 * time.Millisecond
+
+// waitForOutput calls detect until an output matching pattern is connected.
+// It returns an error if the output is not connected before the timeout
+// elapsed.
+func waitForOutput(pattern string, timeout time.Duration, detect func() (randr.Outputs, error)) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		outputs, err := detect()
+		if err != nil {
+			return err
+		}
+
+		if outputs.Connected(pattern) {
+			verbosePrintf("output %v is connected\n", pattern)
+			return nil
+		}
+
+		if time.Now().Add(waitPollInterval).After(deadline) {
+			return fmt.Errorf("no output matching %v connected after %v", pattern, timeout)
+		}
+
+		time.Sleep(waitPollInterval)
+	}
+}
+
+func (cmd CmdWait) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("need exactly one output pattern as the parameter")
+	}
+
+	if _, err := path.Match(args[0], ""); err != nil {
+		return fmt.Errorf("pattern %q malformed: %v", args[0], err)
+	}
+
+	return waitForOutput(args[0], cmd.Timeout, DetectOutputs)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+func TestWaitForOutput(t *testing.T) {
+	defer func(old time.Duration) { waitPollInterval = old }(waitPollInterval)
+	waitPollInterval = time.Millisecond
+
+	polls := 0
+	detect := func() (randr.Outputs, error) {
+		polls++
+		outputs := randr.Outputs{
+			{Name: "LVDS1", Connected: true},
+			{Name: "DP2-1"},
+		}
+		if polls >= 3 {
+			outputs[1].Connected = true
+		}
+		return outputs, nil
+	}
+
+	if err := waitForOutput("DP2-?", time.Second, detect); err != nil {
+		t.Fatalf("waitForOutput returned error: %v", err)
+	}
+
+	if polls != 3 {
+		t.Errorf("wrong number of polls: want 3, got %d", polls)
+	}
+}
+
+func TestWaitForOutputTimeout(t *testing.T) {
+	defer func(old time.Duration) { waitPollInterval = old }(waitPollInterval)
+	waitPollInterval = time.Millisecond
+
+	detect := func() (randr.Outputs, error) {
+		return randr.Outputs{{Name: "LVDS1", Connected: true}}, nil
+	}
+
+	if err := waitForOutput("HDMI*", 10*time.Millisecond, detect); err == nil {
+		t.Errorf("expected timeout error, got nil")
+	}
+}
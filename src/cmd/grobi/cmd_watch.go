@@ -1,20 +1,32 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
 	"time"
 
 	"github.com/BurntSushi/xgb"
-	"github.com/BurntSushi/xgb/randr"
+	xgbrandr "github.com/BurntSushi/xgb/randr"
 	"github.com/BurntSushi/xgb/xproto"
+	"pkg/randr"
 )
 
-type CmdWatch struct{}
+type CmdWatch struct {
+	Verify        bool   `long:"verify" description:"Check that the layout was changed as expected after applying a rule"`
+	VerifyRetries int    `long:"verify-retries" default:"1" description:"Number of times a rule is applied again when the verification fails"`
+	Log           string `long:"log" default:"stderr" description:"Write messages to stderr or syslog"`
+	MetricsAddr   string `long:"metrics-addr" description:"Serve Prometheus metrics at /metrics on this address, e.g. localhost:9180"`
+}
 
 func init() {
 	_, err := parser.AddCommand("watch",
 		"watch for changes",
-		"The watch command listens for changes and configures the outputs accordingly",
+		"The watch command listens for changes and configures the outputs accordingly. "+
+			"Sending SIGUSR1 forces the rules to be evaluated and applied again.",
 		&CmdWatch{})
 	if err != nil {
 		panic(err)
@@ -29,26 +41,26 @@ type Event struct {
 const eventSendTimeout = 500 * time.Millisecond
 
 func subscribeXEvents(ch chan<- Event, done <-chan struct{}) {
-	X, err := xgb.NewConn()
+	X, err := xgb.NewConnDisplay(globalOpts.Display)
 	if err != nil {
 		ch <- Event{Error: err}
 		return
 	}
 
 	defer X.Close()
-	if err = randr.Init(X); err != nil {
+	if err = xgbrandr.Init(X); err != nil {
 		ch <- Event{Error: err}
 		return
 	}
 
 	root := xproto.Setup(X).DefaultScreen(X).Root
 
-	eventMask := randr.NotifyMaskScreenChange |
-		randr.NotifyMaskCrtcChange |
-		randr.NotifyMaskOutputChange |
-		randr.NotifyMaskOutputProperty
+	eventMask := xgbrandr.NotifyMaskScreenChange |
+		xgbrandr.NotifyMaskCrtcChange |
+		xgbrandr.NotifyMaskOutputChange |
+		xgbrandr.NotifyMaskOutputProperty
 
-	err = randr.SelectInputChecked(X, root, uint16(eventMask)).Check()
+	err = xgbrandr.SelectInputChecked(X, root, uint16(eventMask)).Check()
 	if err != nil {
 		ch <- Event{Error: err}
 		return
@@ -69,61 +81,440 @@ func subscribeXEvents(ch chan<- Event, done <-chan struct{}) {
 	}
 }
 
-func (cmd CmdWatch) Execute(args []string) error {
-	globalOpts.ReadConfigfile()
+// watcher holds the state of the watch loop.
+type watcher struct {
+	rules       []randr.Rule
+	lastOutputs randr.Outputs
 
-	done := make(chan struct{})
-	defer close(done)
+	// lastApplied records when a rule was last applied, by rule name.
+	lastApplied map[string]time.Time
 
-	ch := make(chan Event)
-	go subscribeXEvents(ch, done)
+	// verify enables checking the outputs after a rule has been applied,
+	// verifyRetries is the number of times the rule is applied again if the
+	// outputs differ from the layout described by the rule.
+	verify        bool
+	verifyRetries int
 
-	verbosePrintf("successfully subscribed to X RANDR change events\n")
+	// getOutputs and detectOutputs return the current outputs, applyRule
+	// applies a rule and now returns the current time. They are replaced in
+	// tests.
+	getOutputs    func() (randr.Outputs, error)
+	detectOutputs func() (randr.Outputs, error)
+	applyRule     func(randr.Outputs, randr.Rule) (ApplyResult, error)
+	now           func() time.Time
 
-	var tickerCh <-chan time.Time
-	if globalOpts.PollInterval > 0 {
-		tickerCh = time.NewTicker(time.Duration(globalOpts.PollInterval) * time.Second).C
+	// runHook runs a hook with env added to the environment, it is replaced
+	// in tests.
+	runHook func(hook string, env []string) error
+
+	// metrics records the rules applied and the connected outputs.
+	metrics Metrics
+
+	// gamma lists the outputs whose gamma was set by the rule applied last,
+	// it is reset when the next rule does not set it.
+	gamma []string
+
+	// lastRule is the rule applied last, it is applied again by reconcile
+	// when the outputs no longer match it.
+	lastRule *randr.Rule
+
+	// cooldownUntil is the time the cooldown of a rule which was not applied
+	// because of it ends, the outputs are checked again then.
+	cooldownUntil time.Time
+}
+
+func newWatcher(rules []randr.Rule) *watcher {
+	return &watcher{
+		rules:         rules,
+		lastApplied:   make(map[string]time.Time),
+		getOutputs:    GetOutputs,
+		detectOutputs: DetectOutputs,
+		applyRule:     ApplyRule,
+		now:           time.Now,
+		runHook: func(hook string, env []string) error {
+			return RunHook(globalOpts.config().HookShell, hook, env, nil)
+		},
+		metrics: noMetrics{},
+	}
+}
+
+// runConnectHooks runs the commands of rule.ExecuteOnConnect for all outputs
+// which have been connected since the outputs last were applied. The name of
+// the output and its active mode are passed in GROBI_OUTPUT and GROBI_MODE.
+func (w *watcher) runConnectHooks(rule randr.Rule, a *Applier) error {
+	if len(rule.ExecuteOnConnect) == 0 || w.lastOutputs == nil {
+		return nil
+	}
+
+	connected := randr.Diff(w.lastOutputs, a.Outputs).Connected
+	if len(connected) == 0 {
+		return nil
+	}
+
+	// the new outputs only become active when the rule has been applied
+	outputs, err := a.After()
+	if err != nil {
+		return err
+	}
+
+	// run the hooks in a stable order if several patterns match
+	var patterns []string
+	for pat := range rule.ExecuteOnConnect {
+		patterns = append(patterns, pat)
+	}
+	sort.Strings(patterns)
+
+	for _, name := range connected {
+		o, ok := outputs.Get(name)
+		if !ok {
+			o = randr.Output{Name: name}
+		}
+
+		var mode string
+		if m, ok := o.ActiveMode(); ok {
+			mode = m.Name
+		}
+
+		env := []string{
+			"GROBI_RULE=" + rule.Name,
+			"GROBI_OUTPUT=" + o.Name,
+			"GROBI_MODE=" + mode,
+		}
+
+		for _, pat := range patterns {
+			if m, _ := o.MatchName(pat); !m {
+				continue
+			}
+
+			verbosePrintf("output %v connected, running hooks for %v\n", o.Name, pat)
+			for _, hook := range rule.ExecuteOnConnect[pat] {
+				if err := w.runHook(hook, env); err != nil {
+					fmt.Fprintf(stderrOutput, "executing hook for output %v failed: %v\n", o.Name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// update queries the outputs, rescanning them if detect is true, and applies
+// the matching rule if the connected outputs or their active modes changed
+// since the last call or force is true. A rule is not applied again within
+// its cooldown. The outputs are queried once before and at most once after
+// the rule is applied. It returns whether a rule was applied.
+func (w *watcher) update(detect, force bool) (bool, error) {
+	var newOutputs randr.Outputs
+	var err error
+
+	if detect {
+		newOutputs, err = w.detectOutputs()
+	} else {
+		newOutputs, err = w.getOutputs()
+	}
+
+	if err != nil {
+		return false, err
 	}
 
+	// changes of properties or positions alone do not select another rule,
+	// but conditions which do not depend on the outputs may have changed
+	if !force && w.lastOutputs.ConnectionEquals(newOutputs) && !w.ruleChanged(newOutputs) {
+		return false, nil
+	}
+
+	var connected int
+	for _, o := range newOutputs {
+		if o.Connected {
+			connected++
+		}
+	}
+	w.metrics.Outputs(connected)
+
+	a := NewApplier(newOutputs)
+	a.query = w.getOutputs
+	a.applyRule = w.applyRule
+
+	rule, ok := w.selectRule(newOutputs, true)
+	if !ok {
+		w.lastOutputs = newOutputs
+		return false, nil
+	}
+
+	now := w.now()
+	if last, ok := w.lastApplied[rule.Name]; ok && rule.Cooldown > 0 && now.Sub(last) < rule.Cooldown {
+		// keep lastOutputs so that the rule is evaluated again after the
+		// cooldown has passed
+		verbosePrintf("rule %v was applied %v ago, waiting for cooldown of %v\n",
+			rule.Name, now.Sub(last), rule.Cooldown)
+		w.cooldownUntil = last.Add(rule.Cooldown)
+		return false, nil
+	}
+
+	gamma := rule.Gamma
+	rule = w.resetGamma(rule)
+
+	result, err := a.Apply(rule)
+	w.metrics.Applied(now, err == nil && result.Success())
+	if err != nil {
+		return false, err
+	}
+
+	w.gamma = w.gamma[:0]
+	for name := range gamma {
+		w.gamma = append(w.gamma, name)
+	}
+
+	if w.verify && !globalOpts.DryRun {
+		err = w.verifyRule(rule, a)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = w.runConnectHooks(rule, a)
+	if err != nil {
+		return false, err
+	}
+
+	w.lastApplied[rule.Name] = now
+	w.lastOutputs = newOutputs
+	w.lastRule = &rule
+	return true, nil
+}
+
+// selectRule returns the rule for outputs like SelectRule, but skips rules
+// whose layout cannot be built for the outputs, e.g. a rule with
+// single_external while several external outputs are connected. The skipped
+// rules are reported if warn is true.
+func (w *watcher) selectRule(outputs randr.Outputs, warn bool) (randr.Rule, bool) {
+	rules := w.rules
+	for {
+		rule, ok := SelectRule(rules, outputs)
+		if !ok {
+			return randr.Rule{}, false
+		}
+
+		var err error
+		if rule.ConfigureSingle != "" || len(rule.ConfigureRow) > 0 || rule.SingleExternal {
+			_, err = ruleCommands(outputs, rule)
+		}
+
+		if err == nil {
+			return rule, true
+		}
+
+		if warn {
+			warnf("rule %v cannot be applied to the outputs, skipping it: %v\n", rule.Name, err)
+		}
+
+		var others []randr.Rule
+		for _, r := range rules {
+			if r.Name != rule.Name {
+				others = append(others, r)
+			}
+		}
+		rules = others
+	}
+}
+
+// ruleChanged returns true if a rule with conditions which do not depend on
+// the outputs exists and another rule than the one applied last is selected
+// for outputs now, e.g. because a time window has opened.
+func (w *watcher) ruleChanged(outputs randr.Outputs) bool {
+	var dynamic bool
+	for _, rule := range w.rules {
+		if rule.Dynamic() {
+			dynamic = true
+			break
+		}
+	}
+
+	if !dynamic {
+		return false
+	}
+
+	rule, ok := w.selectRule(outputs, false)
+	if !ok || (w.lastRule != nil && w.lastRule.Name == rule.Name) {
+		return false
+	}
+
+	verbosePrintf("outputs unchanged, but rule %v matches now\n", rule.Name)
+	return true
+}
+
+// reconcile queries the outputs and applies the rule applied last again if
+// the outputs differ from the layout it describes, e.g. because another tool
+// changed them. If another rule matches the outputs now, nothing is done and
+// the next update applies it. It returns whether the rule was applied again.
+// Failures are only reported, so that they do not end the watch command.
+func (w *watcher) reconcile() (bool, error) {
+	if w.lastRule == nil || globalOpts.DryRun {
+		return false, nil
+	}
+
+	rule := *w.lastRule
+	if rule.ConfigureSingle == "" && len(rule.ConfigureRow) == 0 && !rule.SingleExternal {
+		return false, nil
+	}
+
+	outputs, err := w.getOutputs()
+	if err != nil {
+		warnf("unable to reconcile the layout: %v\n", err)
+		return false, nil
+	}
+
+	if selected, ok := SelectRule(w.rules, outputs); !ok || selected.Name != rule.Name {
+		return false, nil
+	}
+
+	opts := globalOpts.config().Options()
+	targets, err := randr.TargetLayout(rule, outputs, opts)
+	if err != nil {
+		warnf("unable to reconcile the layout of rule %v: %v\n", rule.Name, err)
+		return false, nil
+	}
+
+	diffs := randr.VerifyLayout(rule, targets, outputs, opts)
+	if len(diffs) == 0 {
+		return false, nil
+	}
+
+	for _, diff := range diffs {
+		verbosePrintf("layout differs from rule %v: %v\n", rule.Name, diff)
+	}
+
+	a := NewApplier(outputs)
+	a.query = w.getOutputs
+	a.applyRule = w.applyRule
+
+	result, err := a.Apply(rule)
+	w.metrics.Applied(w.now(), err == nil && result.Success())
+	if err != nil {
+		warnf("unable to reconcile the layout of rule %v: %v\n", rule.Name, err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// resetGamma returns rule with the gamma of the outputs set by the rule
+// applied last reset to 1:1:1, unless rule sets their gamma as well. The
+// gamma of the rule is copied before it is modified.
+func (w *watcher) resetGamma(rule randr.Rule) randr.Rule {
+	var reset []string
+	for _, name := range w.gamma {
+		if _, ok := rule.Gamma[name]; !ok {
+			reset = append(reset, name)
+		}
+	}
+
+	if len(reset) == 0 {
+		return rule
+	}
+
+	gamma := make(map[string]string, len(rule.Gamma)+len(reset))
+	for name, value := range rule.Gamma {
+		gamma[name] = value
+	}
+
+	for _, name := range reset {
+		verbosePrintf("resetting gamma of output %v\n", name)
+		gamma[name] = "1:1:1"
+	}
+
+	rule.Gamma = gamma
+	return rule
+}
+
+// verifyRule checks the outputs after rule has been applied by a against the
+// layout described by the rule. If they differ, the rule is applied again up
+// to w.verifyRetries times before a warning is printed. Rules using
+// configure_command cannot be verified.
+func (w *watcher) verifyRule(rule randr.Rule, a *Applier) error {
+	if rule.ConfigureSingle == "" && len(rule.ConfigureRow) == 0 && !rule.SingleExternal {
+		return nil
+	}
+
+	opts := globalOpts.config().Options()
+	targets, err := randr.TargetLayout(rule, a.Outputs, opts)
+	if err != nil {
+		return err
+	}
+
+	for retry := 0; ; retry++ {
+		after, err := a.After()
+		if err != nil {
+			return err
+		}
+
+		diffs := randr.VerifyLayout(rule, targets, after, opts)
+		if len(diffs) == 0 {
+			return nil
+		}
+
+		if retry < w.verifyRetries {
+			verbosePrintf("rule %v was not applied as expected, applying it again\n", rule.Name)
+			if _, err = a.Apply(rule); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, diff := range diffs {
+			warnf("rule %v was not applied as expected: %v\n", rule.Name, diff)
+		}
+
+		return nil
+	}
+}
+
+// run updates the outputs whenever an event is received on events, a signal
+// is received on force (which also forces the rules to be applied again),
+// ticker fires or the cooldown of a rule has passed, and reconciles them
+// whenever reconcile fires, until stop is closed. A rule which is being
+// applied when stop is closed is applied completely, afterwards run returns
+// nil.
+func (w *watcher) run(stop <-chan struct{}, events <-chan Event, force <-chan os.Signal, ticker, reconcile <-chan time.Time) error {
 	var backoffCh <-chan time.Time
+	var cooldownCh <-chan time.Time
 	var disablePoll bool
 	var eventReceived bool
+	var forced bool
 
-	var lastOutputs Outputs
 	for {
-		if !disablePoll {
-			var newOutputs Outputs
-			var err error
-
-			if eventReceived {
-				newOutputs, err = DetectOutputs()
-				eventReceived = false
-			} else {
-				newOutputs, err = GetOutputs()
-			}
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
 
+		if !disablePoll || forced {
+			applied, err := w.update(eventReceived, forced)
 			if err != nil {
 				return err
 			}
 
-			if !lastOutputs.Equals(newOutputs) {
-				err = MatchRules(globalOpts.cfg.Rules, newOutputs)
-				if err != nil {
-					return err
-				}
+			eventReceived = false
+			forced = false
 
-				lastOutputs = newOutputs
+			// without polling, nothing else checks the outputs again
+			if !w.cooldownUntil.IsZero() {
+				cooldownCh = time.After(w.cooldownUntil.Sub(w.now()))
+				w.cooldownUntil = time.Time{}
+			}
 
-				if globalOpts.Pause > 0 {
-					verbosePrintf("disable polling for %d seconds\n", globalOpts.Pause)
-					disablePoll = true
-					backoffCh = time.After(time.Duration(globalOpts.Pause) * time.Second)
-				}
+			if applied && globalOpts.Pause > 0 {
+				verbosePrintf("disable polling for %d seconds\n", globalOpts.Pause)
+				disablePoll = true
+				backoffCh = time.After(time.Duration(globalOpts.Pause) * time.Second)
 			}
 		}
 
 		select {
-		case ev := <-ch:
+		case <-stop:
+			return nil
+		case ev := <-events:
 			verbosePrintf("new RANDR change event received:\n")
 			verbosePrintf("  %v\n", ev)
 			if ev.Error != nil {
@@ -131,8 +522,19 @@ func (cmd CmdWatch) Execute(args []string) error {
 			}
 
 			eventReceived = true
-		case <-tickerCh:
+		case <-force:
+			verbosePrintf("received SIGUSR1, reapplying rules\n")
+			forced = true
+		case <-ticker:
 			verbosePrintf("regularly checking xrandr\n")
+		case <-reconcile:
+			verbosePrintf("reconciling the layout\n")
+			if _, err := w.reconcile(); err != nil {
+				return err
+			}
+		case <-cooldownCh:
+			verbosePrintf("cooldown has passed, checking xrandr again\n")
+			cooldownCh = nil
 		case <-backoffCh:
 			verbosePrintf("reenable polling\n")
 			backoffCh = nil
@@ -140,3 +542,72 @@ func (cmd CmdWatch) Execute(args []string) error {
 		}
 	}
 }
+
+func (cmd CmdWatch) Execute(args []string) error {
+	if err := setupLogging(cmd.Log); err != nil {
+		return err
+	}
+
+	globalOpts.ReadConfigfile()
+	if len(globalOpts.cfg.Rules) == 0 {
+		return errNoRules
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan Event)
+	go subscribeXEvents(ch, done)
+
+	verbosePrintf("successfully subscribed to X RANDR change events\n")
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1)
+	defer signal.Stop(sigCh)
+
+	// on SIGTERM and SIGINT, the loop stops after the current iteration so
+	// that xrandr is not killed while it configures the outputs
+	termCh := make(chan os.Signal, 1)
+	signal.Notify(termCh, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(termCh)
+
+	stop := make(chan struct{})
+	go func() {
+		sig := <-termCh
+		verbosePrintf("received %v, shutting down\n", sig)
+		close(stop)
+	}()
+
+	var tickerCh <-chan time.Time
+	if globalOpts.PollInterval > 0 {
+		tickerCh = time.NewTicker(time.Duration(globalOpts.PollInterval) * time.Second).C
+	}
+
+	var reconcileCh <-chan time.Time
+	if d := globalOpts.cfg.ReconcileInterval; d > 0 {
+		reconcileCh = time.NewTicker(d).C
+	}
+
+	w := newWatcher(globalOpts.cfg.Rules)
+	w.verify = cmd.Verify
+	w.verifyRetries = cmd.VerifyRetries
+
+	if cmd.MetricsAddr != "" {
+		m := &watchMetrics{}
+		if err := serveMetrics(cmd.MetricsAddr, m); err != nil {
+			return err
+		}
+		w.metrics = m
+	}
+
+	err := w.run(stop, ch, sigCh, tickerCh, reconcileCh)
+
+	if hook := globalOpts.cfg.ShutdownHook; hook != "" {
+		verbosePrintf("running shutdown hook\n")
+		if herr := w.runHook(hook, nil); herr != nil {
+			fmt.Fprintf(stderrOutput, "executing shutdown hook failed: %v\n", herr)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,780 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+var testWatchRules = []randr.Rule{
+	{Name: "Docked", OutputsConnected: []string{"HDMI"}},
+}
+
+func TestWatcherForceUpdate(t *testing.T) {
+	var applied int
+	w := newWatcher(testWatchRules)
+	w.getOutputs = func() (randr.Outputs, error) { return testOutputs, nil }
+	w.applyRule = func(randr.Outputs, randr.Rule) (ApplyResult, error) {
+		applied++
+		return ApplyResult{}, nil
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if applied != 1 {
+		t.Fatalf("unchanged outputs applied rules: want 1 apply, got %d", applied)
+	}
+
+	ok, err := w.update(false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || applied != 2 {
+		t.Errorf("forced update did not apply rules exactly once: got %d applies", applied)
+	}
+}
+
+func TestWatcherIgnoresPropertyChanges(t *testing.T) {
+	docked := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}},
+	}
+
+	// a property changed, xrandr reports another primary output, position
+	// and an additional mode
+	changed := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Primary: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Offset: randr.Offset{X: 1366}, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}, {Name: "1280x720"}}},
+	}
+
+	mobile := randr.Outputs{
+		docked[0],
+		{Name: "HDMI1"},
+	}
+
+	var applied int
+	current := docked
+	w := newWatcher([]randr.Rule{{Name: "Any"}})
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(randr.Outputs, randr.Rule) (ApplyResult, error) {
+		applied++
+		return ApplyResult{}, nil
+	}
+
+	for _, outputs := range []randr.Outputs{docked, changed, docked} {
+		current = outputs
+		if _, err := w.update(false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if applied != 1 {
+		t.Fatalf("property changes applied rules: want 1 apply, got %d", applied)
+	}
+
+	current = mobile
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if applied != 2 {
+		t.Errorf("disconnected output did not apply rules: got %d applies", applied)
+	}
+}
+
+func TestWatcherCooldown(t *testing.T) {
+	docked := randr.Outputs{
+		{Name: "LVDS1", Connected: true},
+		{Name: "HDMI1", Connected: true},
+	}
+	mobile := randr.Outputs{
+		{Name: "LVDS1", Connected: true},
+		{Name: "HDMI1"},
+	}
+
+	rules := []randr.Rule{
+		{Name: "Docked", OutputsConnected: []string{"HDMI1"}, Cooldown: 10 * time.Second},
+		{Name: "Mobile"},
+	}
+
+	now := time.Unix(1000, 0)
+	current := docked
+	var applied []string
+
+	w := newWatcher(rules)
+	w.now = func() time.Time { return now }
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		applied = append(applied, rule.Name)
+		return ApplyResult{}, nil
+	}
+
+	// the cable is flapping: docked, mobile and docked again within seconds
+	for _, outputs := range []randr.Outputs{docked, mobile, docked} {
+		current = outputs
+		now = now.Add(time.Second)
+		if _, err := w.update(false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"Docked", "Mobile"}
+	if !reflect.DeepEqual(applied, want) {
+		t.Fatalf("wrong rules applied: want %v, got %v", want, applied)
+	}
+
+	// after the cooldown, the next poll applies the rule
+	now = now.Add(10 * time.Second)
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want = append(want, "Docked")
+	if !reflect.DeepEqual(applied, want) {
+		t.Fatalf("wrong rules applied after cooldown: want %v, got %v", want, applied)
+	}
+}
+
+func TestWatcherVerify(t *testing.T) {
+	defer func(old io.Writer) { warnOutput = old }(warnOutput)
+	buf := bytes.NewBuffer(nil)
+	warnOutput = buf
+
+	before := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	// the driver ignored the request to enable HDMI1
+	after := before
+
+	rules := []randr.Rule{
+		{Name: "Docked", OutputsConnected: []string{"HDMI1"}, ConfigureRow: []string{"LVDS1", "HDMI1"}},
+	}
+
+	w := newWatcher(rules)
+	w.verify = true
+	w.getOutputs = func() (randr.Outputs, error) { return before, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		w.getOutputs = func() (randr.Outputs, error) { return after, nil }
+		return ApplyResult{}, nil
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "warning: rule Docked was not applied as expected: output HDMI1 is not active") {
+		t.Errorf("no warning printed, output: %q", buf.String())
+	}
+
+	buf.Reset()
+	after = randr.Outputs{
+		before[0],
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: randr.Offset{X: 1366}, Rotation: "normal"},
+	}
+
+	if _, err := w.update(false, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() > 0 {
+		t.Errorf("warning printed for correctly applied layout: %q", buf.String())
+	}
+}
+
+func TestWatcherVerifyRetry(t *testing.T) {
+	defer func(old io.Writer) { warnOutput = old }(warnOutput)
+	buf := bytes.NewBuffer(nil)
+	warnOutput = buf
+
+	before := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+	after := randr.Outputs{
+		before[0],
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: randr.Offset{X: 1366}, Rotation: "normal"},
+	}
+
+	rules := []randr.Rule{
+		{Name: "Docked", OutputsConnected: []string{"HDMI1"}, ConfigureRow: []string{"LVDS1", "HDMI1"}},
+	}
+
+	// the first application is ignored by the driver, the second one works
+	current := before
+	var applied int
+
+	w := newWatcher(rules)
+	w.verify = true
+	w.verifyRetries = 1
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		if !outputs.Equals(before) {
+			t.Errorf("rule applied again to wrong outputs: %v", outputs)
+		}
+
+		applied++
+		if applied == 2 {
+			current = after
+		}
+		return ApplyResult{}, nil
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if applied != 2 {
+		t.Errorf("rule was applied %d times, want 2", applied)
+	}
+
+	if buf.Len() > 0 {
+		t.Errorf("warning printed although the second application worked: %q", buf.String())
+	}
+}
+
+func TestWatcherConnectHooks(t *testing.T) {
+	docked := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+	applied := randr.Outputs{
+		docked[0],
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}},
+	}
+	mobile := randr.Outputs{
+		docked[0],
+		{Name: "HDMI1"},
+	}
+
+	rules := []randr.Rule{
+		{
+			Name:             "Docked",
+			OutputsConnected: []string{"HDMI1"},
+			ExecuteOnConnect: map[string][]string{"HDMI*": {"notify-send connected"}},
+		},
+		{Name: "Mobile"},
+	}
+
+	current := mobile
+	var hooks [][]string
+
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		if rule.Name == "Docked" {
+			current = applied
+		}
+		return ApplyResult{}, nil
+	}
+	w.runHook = func(hook string, env []string) error {
+		hooks = append(hooks, append([]string{hook}, env...))
+		return nil
+	}
+
+	// connect, stay connected, disconnect and connect again
+	for _, outputs := range []randr.Outputs{mobile, docked, docked, mobile, docked} {
+		current = outputs
+		if _, err := w.update(false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := [][]string{
+		{"notify-send connected", "GROBI_RULE=Docked", "GROBI_OUTPUT=HDMI1", "GROBI_MODE=1920x1080"},
+		{"notify-send connected", "GROBI_RULE=Docked", "GROBI_OUTPUT=HDMI1", "GROBI_MODE=1920x1080"},
+	}
+
+	if !reflect.DeepEqual(hooks, want) {
+		t.Errorf("wrong hooks run:\n  want %v\n  got  %v", want, hooks)
+	}
+}
+
+func TestWatcherQueriesOnce(t *testing.T) {
+	mobile := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "HDMI1"},
+	}
+	docked := randr.Outputs{
+		mobile[0],
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+	applied := randr.Outputs{
+		mobile[0],
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: randr.Offset{X: 1366}, Rotation: "normal"},
+	}
+
+	rules := []randr.Rule{
+		{
+			Name:             "Docked",
+			OutputsConnected: []string{"HDMI1"},
+			ConfigureRow:     []string{"LVDS1", "HDMI1"},
+			ExecuteOnConnect: map[string][]string{"HDMI1": {"notify-send connected"}},
+		},
+	}
+
+	var detects, queries, hooks int
+	w := newWatcher(rules)
+	w.verify = true
+	w.lastOutputs = mobile
+	w.detectOutputs = func() (randr.Outputs, error) {
+		detects++
+		return docked, nil
+	}
+	w.getOutputs = func() (randr.Outputs, error) {
+		queries++
+		return applied, nil
+	}
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		if !outputs.Equals(docked) {
+			t.Errorf("rule applied to wrong outputs: %v", outputs)
+		}
+		return ApplyResult{}, nil
+	}
+	w.runHook = func(hook string, env []string) error {
+		hooks++
+		return nil
+	}
+
+	ok, err := w.update(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || hooks != 1 {
+		t.Fatalf("rule not applied (%v) or wrong number of hooks run (%d)", ok, hooks)
+	}
+
+	// the outputs are detected once for matching and building the commands,
+	// and queried once after applying for both verification and the hooks
+	if detects != 1 || queries != 1 {
+		t.Errorf("wrong number of xrandr queries: want 1 detect and 1 query, got %d and %d", detects, queries)
+	}
+}
+
+func TestWatcherResetGamma(t *testing.T) {
+	docked := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+	mobile := randr.Outputs{
+		docked[0],
+		{Name: "HDMI1"},
+	}
+
+	rules := []randr.Rule{
+		{
+			Name:             "Night",
+			OutputsConnected: []string{"HDMI1"},
+			ConfigureRow:     []string{"LVDS1", "HDMI1"},
+			Gamma:            map[string]string{"LVDS1": "night", "HDMI1": "night"},
+		},
+		{
+			Name:            "Mobile",
+			ConfigureSingle: "LVDS1",
+		},
+	}
+
+	var args [][]string
+	current := docked
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		cmds, err := randr.BuildCommandOutputRow(rule, outputs, randr.Options{})
+		if err != nil {
+			return ApplyResult{}, err
+		}
+		args = testCommandArgs(cmds)
+		return ApplyResult{}, nil
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	current = mobile
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"xrandr", "--output", "LVDS1", "--auto", "--gamma", "1:1:1"}}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("gamma not reset:\n  want %v\n  got  %v", want, args)
+	}
+
+	if len(rules[1].Gamma) != 0 {
+		t.Errorf("rule in the config was modified: %v", rules[1].Gamma)
+	}
+
+	// no gamma was set by the last rule, nothing is reset anymore
+	if rule := w.resetGamma(rules[1]); len(rule.Gamma) != 0 {
+		t.Errorf("gamma reset again: %v", rule.Gamma)
+	}
+}
+
+func TestWatcherRunStop(t *testing.T) {
+	defer func(pause uint) { globalOpts.Pause = pause }(globalOpts.Pause)
+	globalOpts.Pause = 0
+
+	stop := make(chan struct{})
+	events := make(chan Event, 1)
+
+	var applied int
+	w := newWatcher(testWatchRules)
+	w.getOutputs = func() (randr.Outputs, error) { return testOutputs, nil }
+	w.detectOutputs = w.getOutputs
+	w.applyRule = func(randr.Outputs, randr.Rule) (ApplyResult, error) {
+		// the signal arrives while the rule is being applied, with another
+		// event pending
+		applied++
+		close(stop)
+		events <- Event{}
+		return ApplyResult{}, nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- w.run(stop, events, nil, nil, nil) }()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("run returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("run did not return after stop was closed")
+	}
+
+	if applied != 1 {
+		t.Errorf("wrong number of rules applied: want 1, got %d", applied)
+	}
+
+	// closing stop while waiting for events also ends the loop
+	stop = make(chan struct{})
+	go func() { errCh <- w.run(stop, nil, nil, nil, nil) }()
+	close(stop)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("run returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("run did not return after stop was closed")
+	}
+}
+
+func TestWatcherReconcile(t *testing.T) {
+	undocked := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+	docked := randr.Outputs{
+		undocked[0],
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: randr.Offset{X: 1366}, Rotation: "normal"},
+	}
+
+	// another tool moved HDMI1 below LVDS1
+	moved := randr.Outputs{
+		undocked[0],
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: randr.Offset{Y: 768}, Rotation: "normal"},
+	}
+
+	rules := []randr.Rule{
+		{Name: "Docked", OutputsConnected: []string{"HDMI1"}, ConfigureRow: []string{"LVDS1", "HDMI1"}},
+	}
+
+	current := undocked
+	var applied int
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(randr.Outputs, randr.Rule) (ApplyResult, error) {
+		applied++
+		current = docked
+		return ApplyResult{}, nil
+	}
+
+	if ok, err := w.reconcile(); err != nil || ok {
+		t.Fatalf("reconcile before any rule was applied: %v, %v", ok, err)
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := w.reconcile(); err != nil || ok {
+		t.Fatalf("reconcile applied the rule to the expected layout: %v, %v", ok, err)
+	}
+
+	current = moved
+	ok, err := w.reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || applied != 2 {
+		t.Fatalf("changed layout was not reconciled: applied %v, %d rules applied", ok, applied)
+	}
+
+	if ok, err = w.reconcile(); err != nil || ok {
+		t.Fatalf("reconcile applied the rule again: %v, %v", ok, err)
+	}
+
+	// once the rule no longer matches, the next update handles the outputs
+	current = randr.Outputs{undocked[0], {Name: "HDMI1"}}
+	if ok, err = w.reconcile(); err != nil || ok {
+		t.Fatalf("reconcile applied a rule which does not match: %v, %v", ok, err)
+	}
+}
+
+func TestWatcherReconcileManages(t *testing.T) {
+	// VGA1 is active, but the rule only manages the other outputs
+	current := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: randr.Offset{X: 1366}, Rotation: "normal"},
+		{Name: "VGA1", Connected: true, Modes: []randr.Mode{{Name: "1024x768", Default: true, Active: true}}, Offset: randr.Offset{X: 3286}, Rotation: "normal"},
+	}
+
+	rules := []randr.Rule{
+		{Name: "Docked", OutputsConnected: []string{"HDMI1"}, ConfigureRow: []string{"LVDS1", "HDMI1"}, Manages: []string{"LVDS1", "HDMI1"}},
+	}
+
+	var applied int
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(randr.Outputs, randr.Rule) (ApplyResult, error) {
+		applied++
+		return ApplyResult{}, nil
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if ok, err := w.reconcile(); err != nil || ok {
+			t.Fatalf("reconcile applied the rule for an output it does not manage: %v, %v", ok, err)
+		}
+	}
+
+	if applied != 1 {
+		t.Errorf("rule applied %d times, want once", applied)
+	}
+}
+
+func TestWatcherTimeWindow(t *testing.T) {
+	rules := []randr.Rule{
+		{Name: "Night", ActiveBetween: "20:00-06:00", Priority: 1},
+		{Name: "Day"},
+	}
+
+	env := randr.Environment{Time: time.Date(2016, 5, 3, 12, 0, 0, 0, time.Local)}
+	restore := randr.SetEnvironment(env)
+	defer func() { restore() }()
+
+	var applied []string
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return testOutputs, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		applied = append(applied, rule.Name)
+		return ApplyResult{}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.update(false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the outputs stay the same, but the time window opens
+	restore()
+	env.Time = time.Date(2016, 5, 3, 21, 0, 0, 0, time.Local)
+	restore = randr.SetEnvironment(env)
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.update(false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"Day", "Night"}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("wrong rules applied, want %v, got %v", want, applied)
+	}
+}
+
+func TestWatcherRunCooldown(t *testing.T) {
+	defer func(pause uint) { globalOpts.Pause = pause }(globalOpts.Pause)
+	globalOpts.Pause = 0
+
+	docked := randr.Outputs{
+		{Name: "LVDS1", Connected: true},
+		{Name: "HDMI1", Connected: true},
+	}
+	mobile := randr.Outputs{
+		{Name: "LVDS1", Connected: true},
+		{Name: "HDMI1"},
+	}
+
+	rules := []randr.Rule{
+		{Name: "Docked", OutputsConnected: []string{"HDMI1"}, Cooldown: 50 * time.Millisecond},
+		{Name: "Mobile"},
+	}
+
+	stop := make(chan struct{})
+	events := make(chan Event)
+
+	// the outputs change with every event: mobile, docked within the
+	// cooldown, and nothing afterwards
+	queue := []randr.Outputs{docked, mobile, docked}
+	current := queue[0]
+	var applied []string
+
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.detectOutputs = func() (randr.Outputs, error) {
+		queue = queue[1:]
+		current = queue[0]
+		return current, nil
+	}
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		applied = append(applied, rule.Name)
+		if len(applied) == 3 {
+			close(stop)
+		}
+		return ApplyResult{}, nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- w.run(stop, events, nil, nil, nil) }()
+
+	events <- Event{}
+	events <- Event{}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("run returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("rule was not applied after the cooldown, applied %v", applied)
+	}
+
+	want := []string{"Docked", "Mobile", "Docked"}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("wrong rules applied: want %v, got %v", want, applied)
+	}
+}
+
+func TestWatcherSkipsUnbuildableRule(t *testing.T) {
+	defer func(w io.Writer) { warnOutput = w }(warnOutput)
+	warn := bytes.NewBuffer(nil)
+	warnOutput = warn
+
+	// two external outputs are connected, so single_external fails
+	outputs := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+		{Name: "HDMI2", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	rules := []randr.Rule{
+		{Name: "Single", OutputsConnected: []string{"HDMI*"}, SingleExternal: true, Priority: 1},
+		{Name: "Mobile", ConfigureSingle: "LVDS1"},
+	}
+
+	var applied []string
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return outputs, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		applied = append(applied, rule.Name)
+		return ApplyResult{}, nil
+	}
+
+	ok, err := w.update(false, false)
+	if err != nil {
+		t.Fatalf("update returned error: %v", err)
+	}
+
+	if want := []string{"Mobile"}; !ok || !reflect.DeepEqual(applied, want) {
+		t.Errorf("wrong rules applied, want %v, got %v", want, applied)
+	}
+
+	if !strings.Contains(warn.String(), "rule Single cannot be applied") {
+		t.Errorf("skipped rule not reported, output: %q", warn.String())
+	}
+}
+
+func TestWatcherRunReconcileFailure(t *testing.T) {
+	defer func(pause uint, w io.Writer) {
+		globalOpts.Pause = pause
+		warnOutput = w
+	}(globalOpts.Pause, warnOutput)
+	globalOpts.Pause = 0
+
+	warn := bytes.NewBuffer(nil)
+	warnOutput = warn
+
+	single := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}},
+	}
+
+	// a second external output makes the layout of the rule fail
+	double := append(randr.Outputs{}, single...)
+	double = append(double, randr.Output{Name: "HDMI2", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}})
+
+	rules := []randr.Rule{
+		{Name: "Single", OutputsConnected: []string{"HDMI*"}, SingleExternal: true},
+	}
+
+	current := single
+	var applied int
+
+	w := newWatcher(rules)
+	w.getOutputs = func() (randr.Outputs, error) { return current, nil }
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		applied++
+		current = double
+		return ApplyResult{}, nil
+	}
+
+	stop := make(chan struct{})
+	reconcile := make(chan time.Time)
+	errCh := make(chan error, 1)
+	go func() { errCh <- w.run(stop, nil, nil, nil, reconcile) }()
+
+	// run only receives the second tick if it kept running after the first
+	for i := 0; i < 2; i++ {
+		select {
+		case reconcile <- time.Now():
+		case err := <-errCh:
+			t.Fatalf("run returned after a failed reconcile: %v", err)
+		}
+	}
+
+	close(stop)
+	if err := <-errCh; err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+
+	if applied != 1 {
+		t.Errorf("rule applied %d times, want once", applied)
+	}
+
+	if !strings.Contains(warn.String(), "unable to reconcile the layout of rule Single") {
+		t.Errorf("failed reconcile not reported, output: %q", warn.String())
+	}
+}
@@ -8,15 +8,135 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
+	"pkg/randr"
 )
 
 // Config holds all configuration for grobi.
 type Config struct {
-	Rules []Rule
+	Rules []randr.Rule
 
 	ExecuteAfter []string `yaml:"execute_after"`
+	HookShell    string   `yaml:"hook_shell"`
+
+	// ShutdownHook is run when watch mode exits, e.g. after SIGTERM.
+	ShutdownHook string `yaml:"shutdown_hook"`
+
+	// HookStdin selects what hooks receive on stdin, "outputs-json" passes
+	// the list of outputs as JSON.
+	HookStdin string `yaml:"hook_stdin"`
+
+	// Aliases maps old output names to the names currently used by the
+	// driver, e.g. {DP1: DP-1}, so that rules written for the old names keep
+	// working. Unlike groups, an alias renames exactly one output.
+	Aliases map[string]string `yaml:"aliases"`
+
+	// StateFile is the name of a file the layout is written to as JSON
+	// after a rule has been applied successfully.
+	StateFile string `yaml:"state_file"`
+
+	// HistoryFile is the name of a file a line is appended to whenever a
+	// rule is applied. It is rotated when it grows beyond HistoryMaxSize
+	// bytes (default 1 MiB), keeping one old file.
+	HistoryFile    string `yaml:"history_file"`
+	HistoryMaxSize int64  `yaml:"history_max_size"`
+
+	// Groups defines named groups of outputs, which are referenced as
+	// "@name" in the output lists of the rules.
+	Groups map[string][]string `yaml:"groups"`
+
+	// AutoPrimary makes the first configured output the primary output for
+	// rules which do not name one if true, or the physically largest output
+	// if set to "largest".
+	AutoPrimary string `yaml:"auto_primary"`
+
+	// InternalOutput is the name of the internal panel of a laptop, it is
+	// detected automatically if empty.
+	InternalOutput string `yaml:"internal_output"`
+
+	// ForceModes maps output name patterns to a mode which is used whenever
+	// a matching output is enabled, regardless of the rule.
+	ForceModes map[string]string `yaml:"force_modes"`
+
+	// DisableStale turns off outputs which are disconnected but still
+	// active whenever a rule is applied.
+	DisableStale bool `yaml:"disable_stale"`
+
+	// ResetBefore runs "xrandr --auto" before every rule is applied.
+	ResetBefore bool `yaml:"reset_before"`
+
+	// GammaPresets defines gamma presets ("R:G:B") by name, which can be
+	// used in the gamma setting of the rules.
+	GammaPresets map[string]string `yaml:"gamma_presets"`
+
+	// XrandrExtraArgs are passed to xrandr whenever it configures the
+	// outputs, for options grobi does not know about.
+	XrandrExtraArgs []string `yaml:"xrandr_extra_args"`
+
+	// ReconcileInterval is the time between two checks in watch mode
+	// whether the outputs still match the rule applied last, which is
+	// applied again if another tool changed them. Zero disables the check.
+	ReconcileInterval time.Duration `yaml:"reconcile_interval"`
+
+	// Seat restricts grobi to the outputs of the graphics cards assigned
+	// to the seat by systemd-logind, e.g. "seat1", or "current" for the
+	// seat in $XDG_SEAT.
+	Seat string `yaml:"seat"`
+
+	// GetQuery and DetectQuery select whether the X server rescans the
+	// outputs ("rescan") or reports their current state ("current") when
+	// grobi queries them without or with detecting changes. By default,
+	// only detecting changes rescans the outputs.
+	GetQuery    string `yaml:"get_query"`
+	DetectQuery string `yaml:"detect_query"`
+
+	// Tests lists scenarios for the test command, each with the outputs
+	// which are connected and the rule which must be selected for them.
+	Tests []ConfigTest `yaml:"tests"`
+
+	// PinOutputs maps output names to a mode or "keep", these outputs are
+	// configured whenever a rule is applied and never disabled.
+	PinOutputs map[string]string `yaml:"pin_outputs"`
+}
+
+// Options returns the settings from the config which apply to all rules.
+func (cfg Config) Options() randr.Options {
+	autoPrimary, largest, _ := cfg.autoPrimary()
+	return randr.Options{
+		AutoPrimary:     autoPrimary,
+		PrimaryLargest:  largest,
+		InternalOutput:  cfg.InternalOutput,
+		ForceModes:      cfg.ForceModes,
+		DisableStale:    cfg.DisableStale,
+		ResetBefore:     cfg.ResetBefore,
+		GammaPresets:    cfg.GammaPresets,
+		XrandrExtraArgs: cfg.XrandrExtraArgs,
+		PinOutputs:      cfg.PinOutputs,
+	}
+}
+
+// autoPrimaryLargest is the value of auto_primary which selects the
+// physically largest output as the primary one.
+const autoPrimaryLargest = "largest"
+
+// autoPrimary returns whether auto_primary is enabled and whether it selects
+// the largest output instead of the first one. Besides "largest", the option
+// accepts the boolean values YAML knows.
+func (cfg Config) autoPrimary() (enabled, largest bool, err error) {
+	switch strings.ToLower(cfg.AutoPrimary) {
+	case "", "false", "no", "off":
+		return false, false, nil
+	case "true", "yes", "on":
+		return true, false, nil
+	case autoPrimaryLargest:
+		return true, true, nil
+	default:
+		return false, false, fmt.Errorf("invalid value %q for auto_primary, must be true, false or %q", cfg.AutoPrimary, autoPrimaryLargest)
+	}
 }
 
 // xdgConfigDir returns the config directory according to the xdg standard, see
@@ -29,6 +149,9 @@ func xdgConfigDir() string {
 	return filepath.Join(os.Getenv("HOME"), ".config")
 }
 
+// errNoConfigFile is returned when none of the config files exists.
+var errNoConfigFile = errors.New("could not find config file")
+
 // openConfigFile returns a reader for the config file.
 func openConfigFile(name string) (io.ReadCloser, error) {
 	for _, filename := range []string{
@@ -44,28 +167,42 @@ func openConfigFile(name string) (io.ReadCloser, error) {
 		}
 	}
 
-	return nil, errors.New("could not find config file")
+	return nil, errNoConfigFile
 }
 
-// readConfig returns a configuration struct read from a configuration file.
-func readConfig(name string) (Config, error) {
+// readConfigFile returns the contents of the configuration file.
+func readConfigFile(name string) ([]byte, error) {
 	rd, err := openConfigFile(name)
 	if err != nil {
-		return Config{}, err
+		return nil, err
 	}
 
 	buf, err := ioutil.ReadAll(rd)
 	if err != nil {
-		return Config{}, err
+		return nil, err
 	}
 
-	var cfg Config
-	err = yaml.Unmarshal(buf, &cfg)
+	err = rd.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// readConfig returns a configuration struct read from a configuration file.
+func readConfig(name string) (Config, error) {
+	buf, err := readConfigFile(name)
 	if err != nil {
 		return Config{}, err
 	}
 
-	err = rd.Close()
+	return parseConfig(buf)
+}
+
+// parseConfig returns the configuration parsed from buf and validates it.
+func parseConfig(buf []byte) (Config, error) {
+	cfg, err := decodeConfig(buf)
 	if err != nil {
 		return Config{}, err
 	}
@@ -77,16 +214,268 @@ func readConfig(name string) (Config, error) {
 	return cfg, nil
 }
 
+// decodeConfig returns the configuration parsed from buf without validating
+// it. Unnamed rules are named after their index, environment variables and
+// groups in the rules are expanded and aliased outputs are renamed.
+func decodeConfig(buf []byte) (Config, error) {
+	var cfg Config
+	err := yaml.Unmarshal(buf, &cfg)
+	if err != nil {
+		return Config{}, err
+	}
+
+	for i := range cfg.Rules {
+		if cfg.Rules[i].Name == "" {
+			cfg.Rules[i].Name = fmt.Sprintf("rule[%d]", i)
+		}
+
+		// groups are expanded first, so that their members may contain
+		// environment variables too
+		if err = cfg.expandGroups(&cfg.Rules[i]); err != nil {
+			return Config{}, fmt.Errorf("rule %v: %v", cfg.Rules[i].Name, err)
+		}
+
+		cfg.Rules[i].ExpandEnv()
+
+		cfg.Rules[i].RenameOutputs(cfg.Aliases)
+	}
+
+	for i := range cfg.Tests {
+		if cfg.Tests[i].Name == "" {
+			cfg.Tests[i].Name = fmt.Sprintf("tests[%d]", i)
+		}
+
+		cfg.Tests[i].renameOutputs(cfg.Aliases)
+	}
+
+	if alias, ok := cfg.Aliases[cfg.InternalOutput]; ok {
+		cfg.InternalOutput = alias
+	}
+
+	randr.ExpandEnvKeys(cfg.PinOutputs)
+	randr.ExpandEnvKeys(cfg.ForceModes)
+
+	// patterns of force_modes are only renamed if they are spelled exactly
+	// like an alias
+	randr.RenameKeys(cfg.PinOutputs, cfg.Aliases)
+	randr.RenameKeys(cfg.ForceModes, cfg.Aliases)
+
+	return cfg, nil
+}
+
+// expandGroup returns the members of the named group, with groups referenced
+// by the members expanded recursively. seen contains the groups currently
+// being expanded and is used to detect cycles.
+func (cfg Config) expandGroup(name string, seen map[string]bool) ([]string, error) {
+	members, ok := cfg.Groups[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown group %q", name)
+	}
+
+	if seen[name] {
+		return nil, fmt.Errorf("group %q references itself", name)
+	}
+	seen[name] = true
+	defer delete(seen, name)
+
+	return cfg.expandGroupList(members, seen)
+}
+
+// expandGroupList replaces all entries of the form "@name" in list by the
+// members of the group.
+func (cfg Config) expandGroupList(list []string, seen map[string]bool) ([]string, error) {
+	var res []string
+	for _, entry := range list {
+		if !strings.HasPrefix(entry, "@") {
+			res = append(res, entry)
+			continue
+		}
+
+		members, err := cfg.expandGroup(entry[1:], seen)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, members...)
+	}
+
+	return res, nil
+}
+
+// expandGroupKeys replaces the keys of the form "@name" in m, which must be a
+// map with string keys, by the members of the group, each with the value of
+// the group. An output which is a key of m itself keeps its own value, an
+// output which is a member of two groups used as keys is an error.
+func (cfg Config) expandGroupKeys(m interface{}) error {
+	v := reflect.ValueOf(m)
+
+	groupOf := make(map[string]string)
+	for _, key := range v.MapKeys() {
+		group := key.String()
+		if !strings.HasPrefix(group, "@") {
+			continue
+		}
+
+		members, err := cfg.expandGroup(group[1:], make(map[string]bool))
+		if err != nil {
+			return err
+		}
+
+		value := v.MapIndex(key)
+		v.SetMapIndex(key, reflect.Value{})
+
+		for _, member := range members {
+			if other, ok := groupOf[member]; ok && other != group {
+				return fmt.Errorf("output %v is set by both %v and %v", member, other, group)
+			}
+
+			name := reflect.ValueOf(member)
+			if _, ok := groupOf[member]; !ok && v.MapIndex(name).IsValid() {
+				continue
+			}
+
+			groupOf[member] = group
+			v.SetMapIndex(name, value)
+		}
+	}
+
+	return nil
+}
+
+// expandGroups replaces references to groups in the output names of the rule
+// by the members of the group: in the output lists, the candidates of
+// Primary, the keys of the per-output settings and ConfigureSingle, where the
+// group must have exactly one member.
+func (cfg Config) expandGroups(rule *randr.Rule) error {
+	for _, list := range []*[]string{
+		&rule.OutputsConnected,
+		&rule.OutputsDisconnected,
+		&rule.OutputsPresent,
+		&rule.OutputsAbsent,
+		&rule.OutputsPresentDisconnected,
+		&rule.ConfigureRow,
+		&rule.DisableOrder,
+		&rule.Manages,
+	} {
+		expanded, err := cfg.expandGroupList(*list, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		*list = expanded
+	}
+
+	if strings.HasPrefix(rule.ConfigureSingle, "@") {
+		members, err := cfg.expandGroup(rule.ConfigureSingle[1:], make(map[string]bool))
+		if err != nil {
+			return err
+		}
+
+		if len(members) != 1 {
+			return fmt.Errorf("configure_single: group %q has %d members, need exactly one", rule.ConfigureSingle[1:], len(members))
+		}
+		rule.ConfigureSingle = members[0]
+	}
+
+	candidates, err := cfg.expandGroupList(rule.PrimaryCandidates(), make(map[string]bool))
+	if err != nil {
+		return err
+	}
+	rule.Primary = strings.Join(candidates, ",")
+
+	for _, m := range []interface{}{
+		rule.SupportsMode,
+		rule.MinModes,
+		rule.Vendor,
+		rule.Positions,
+		rule.Rotate,
+		rule.Transform,
+		rule.ScaleTo,
+		rule.CRTC,
+		rule.Gamma,
+		rule.TearFree,
+		rule.Underscan,
+		rule.NewModes,
+		rule.ExecuteOnConnect,
+	} {
+		if err := cfg.expandGroupKeys(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Valid returns an error if the config is invalid, ie a pattern is malformed.
 func (cfg Config) Valid() error {
+	if err := cfg.validOptions(); err != nil {
+		return err
+	}
 
 	for _, rule := range cfg.Rules {
-		for _, list := range [][]string{rule.OutputsPresent, rule.OutputsAbsent, rule.OutputsConnected, rule.OutputsDisconnected} {
-			for _, pat := range list {
-				if _, err := path.Match(pat, ""); err != nil {
-					return fmt.Errorf("pattern %q malformed: %v", pat, err)
-				}
-			}
+		if err := validRule(rule, cfg.GammaPresets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validRule returns an error if rule is invalid or uses a gamma preset which
+// is not defined in presets.
+func validRule(rule randr.Rule, presets map[string]string) error {
+	if err := rule.Valid(); err != nil {
+		return err
+	}
+
+	for name, gamma := range rule.Gamma {
+		if _, err := randr.ResolveGamma(gamma, presets); err != nil {
+			return fmt.Errorf("rule %v, output %v: %v", rule.Name, name, err)
+		}
+	}
+
+	return nil
+}
+
+// validOptions returns an error if an option outside of the rules is invalid.
+func (cfg Config) validOptions() error {
+	if _, err := hookStdin(cfg.HookStdin, nil); err != nil {
+		return err
+	}
+
+	for name, gamma := range cfg.GammaPresets {
+		if !strings.Contains(gamma, ":") {
+			return fmt.Errorf("gamma preset %v: %q is not of the form R:G:B", name, gamma)
+		}
+
+		if _, err := randr.ResolveGamma(gamma, nil); err != nil {
+			return fmt.Errorf("gamma preset %v: %v", name, err)
+		}
+	}
+
+	if _, _, err := cfg.autoPrimary(); err != nil {
+		return err
+	}
+
+	for _, variant := range []string{cfg.GetQuery, cfg.DetectQuery} {
+		if _, err := queryArgs(variant, queryCurrent); err != nil {
+			return err
+		}
+	}
+
+	for _, test := range cfg.Tests {
+		if err := test.Valid(); err != nil {
+			return err
+		}
+	}
+
+	for name, mode := range cfg.PinOutputs {
+		if mode == "" {
+			return fmt.Errorf("pinned output %v: no mode, use \"keep\" to keep the current one", name)
+		}
+	}
+
+	for pat := range cfg.ForceModes {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("pattern %q malformed: %v", pat, err)
 		}
 	}
 
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"pkg/randr"
+)
+
+const testConfigEnv = `
+force_modes:
+  ${GROBI_TEST_OUTPUT}: 1280x720
+rules:
+  - name: Docked
+    outputs_connected: ["${GROBI_TEST_OUTPUT}"]
+    configure_row:
+      - LVDS1
+      - ${GROBI_TEST_OUTPUT}@${GROBI_TEST_MODE}
+    primary: $GROBI_TEST_OUTPUT
+    rotate:
+      ${GROBI_TEST_OUTPUT}: $GROBI_TEST_ROTATION
+    execute_after:
+      - notify-send "$GROBI_RULE"
+`
+
+func TestConfigExpandEnv(t *testing.T) {
+	for name, value := range map[string]string{
+		"GROBI_TEST_OUTPUT":   "HDMI1",
+		"GROBI_TEST_MODE":     "1920x1080",
+		"GROBI_TEST_ROTATION": "left",
+	} {
+		defer os.Unsetenv(name)
+		os.Setenv(name, value)
+	}
+
+	cfg, err := parseConfig([]byte(testConfigEnv))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	rule := cfg.Rules[0]
+	if !reflect.DeepEqual(rule.OutputsConnected, []string{"HDMI1"}) {
+		t.Errorf("outputs_connected not expanded: %v", rule.OutputsConnected)
+	}
+
+	if want := map[string]string{"HDMI1": "1280x720"}; !reflect.DeepEqual(cfg.ForceModes, want) {
+		t.Errorf("force_modes not expanded: %v", cfg.ForceModes)
+	}
+
+	// hooks are run by a shell, which expands the variables itself
+	if want := []string{`notify-send "$GROBI_RULE"`}; !reflect.DeepEqual(rule.ExecuteAfter, want) {
+		t.Errorf("hook was expanded: %v", rule.ExecuteAfter)
+	}
+
+	current := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	cmds, err := randr.BuildCommandOutputRow(rule, current, randr.Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "LVDS1", "--auto"},
+		{"xrandr", "--output", "HDMI1", "--mode", "1920x1080", "--primary", "--rotate", "left", "--right-of", "LVDS1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+const testConfigAliases = `
+aliases:
+  DP1: DP-1
+  HDMI1: HDMI-1
+rules:
+  - name: Docked
+    outputs_connected: [DP1]
+    outputs_disconnected: [HDMI1]
+    configure_row:
+      - eDP1
+      - DP1@1920x1080
+    primary: DP1
+    rotate:
+      DP1: left
+`
+
+func TestConfigAliases(t *testing.T) {
+	cfg, err := parseConfig([]byte(testConfigAliases))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	rule := cfg.Rules[0]
+	current := randr.Outputs{
+		{Name: "eDP1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP-1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true}}},
+		{Name: "HDMI-1"},
+	}
+
+	if !rule.Match(current) {
+		t.Fatalf("rule written for the old names does not match the renamed outputs")
+	}
+
+	cmds, err := randr.BuildCommandOutputRow(rule, current, randr.Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "eDP1", "--auto"},
+		{"xrandr", "--output", "DP-1", "--mode", "1920x1080", "--primary", "--rotate", "left", "--right-of", "eDP1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestConfigAliasesChained(t *testing.T) {
+	// DP1 was renamed to DP-1, and the output formerly called DP-1 is now
+	// DP-1-1; every name is renamed exactly once
+	const config = `
+aliases:
+  DP1: DP-1
+  DP-1: DP-1-1
+force_modes:
+  DP1: 1920x1080
+  "HDMI*": 1280x720
+rules:
+  - name: Docked
+    outputs_connected: [DP1, DP-1]
+    configure_row: [DP1, DP-1]
+    rotate:
+      DP1: left
+      DP-1: right
+`
+
+	// map order varies between runs, so parse the config several times
+	for i := 0; i < 20; i++ {
+		cfg, err := parseConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("parseConfig returned error: %v", err)
+		}
+
+		rule := cfg.Rules[0]
+		if want := []string{"DP-1", "DP-1-1"}; !reflect.DeepEqual(rule.ConfigureRow, want) {
+			t.Fatalf("wrong configure_row: want %v, got %v", want, rule.ConfigureRow)
+		}
+
+		wantRotate := map[string]string{"DP-1": "left", "DP-1-1": "right"}
+		if !reflect.DeepEqual(rule.Rotate, wantRotate) {
+			t.Fatalf("wrong rotate: want %v, got %v", wantRotate, rule.Rotate)
+		}
+
+		wantForced := map[string]string{"DP-1": "1920x1080", "HDMI*": "1280x720"}
+		if !reflect.DeepEqual(cfg.ForceModes, wantForced) {
+			t.Fatalf("wrong force_modes: want %v, got %v", wantForced, cfg.ForceModes)
+		}
+	}
+}
+
+func TestConfigPinOutputs(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+aliases:
+  DP1: DP-1
+pin_outputs:
+  DP1: keep
+  VGA1: 1280x1024
+rules:
+  - configure_single: HDMI1
+`))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	want := map[string]string{"DP-1": "keep", "VGA1": "1280x1024"}
+	if got := cfg.Options().PinOutputs; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong pinned outputs: want %v, got %v", want, got)
+	}
+
+	_, err = parseConfig([]byte(`
+pin_outputs:
+  DP1:
+rules:
+  - configure_single: HDMI1
+`))
+	if err == nil {
+		t.Errorf("pinned output without mode did not return an error")
+	}
+}
+
+func TestConfigAutoPrimary(t *testing.T) {
+	var tests = []struct {
+		value            string
+		enabled, largest bool
+	}{
+		{"false", false, false},
+		{"true", true, false},
+		{"yes", true, false},
+		{"largest", true, true},
+	}
+
+	for _, test := range tests {
+		cfg, err := parseConfig([]byte("auto_primary: " + test.value + "\n"))
+		if err != nil {
+			t.Errorf("auto_primary %v: parseConfig returned error: %v", test.value, err)
+			continue
+		}
+
+		opts := cfg.Options()
+		if opts.AutoPrimary != test.enabled || opts.PrimaryLargest != test.largest {
+			t.Errorf("auto_primary %v: want %v/%v, got %v/%v", test.value,
+				test.enabled, test.largest, opts.AutoPrimary, opts.PrimaryLargest)
+		}
+	}
+
+	if _, err := parseConfig([]byte("auto_primary: smallest\n")); err == nil {
+		t.Errorf("invalid value for auto_primary did not return an error")
+	}
+}
+
+const testConfigGroups = `
+groups:
+  externals: [DP2-1, "@hdmi"]
+  hdmi: [HDMI1, HDMI2]
+  laptop: [LVDS1]
+  env: ["${GROBI_TEST_OUTPUT}"]
+rules:
+  - name: Docked
+    outputs_connected: ["@externals"]
+    configure_row:
+      - LVDS1
+      - "@externals"
+    disable_order: ["@hdmi"]
+  - name: Single
+    configure_single: "@laptop"
+    primary: "@hdmi, LVDS1"
+    gamma:
+      "@hdmi": 1:0.9:0.8
+      HDMI2: 1:1:1
+    tear_free:
+      "@externals": true
+  - name: Env
+    configure_single: "@env"
+`
+
+func TestConfigGroups(t *testing.T) {
+	defer os.Setenv("GROBI_TEST_OUTPUT", os.Getenv("GROBI_TEST_OUTPUT"))
+	os.Setenv("GROBI_TEST_OUTPUT", "DP-3")
+
+	cfg, err := parseConfig([]byte(testConfigGroups))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+
+	rule := cfg.Rules[0]
+	want := []string{"LVDS1", "DP2-1", "HDMI1", "HDMI2"}
+	if !reflect.DeepEqual(rule.ConfigureRow, want) {
+		t.Errorf("configure_row not expanded: want %v, got %v", want, rule.ConfigureRow)
+	}
+
+	want = []string{"DP2-1", "HDMI1", "HDMI2"}
+	if !reflect.DeepEqual(rule.OutputsConnected, want) {
+		t.Errorf("outputs_connected not expanded: want %v, got %v", want, rule.OutputsConnected)
+	}
+
+	want = []string{"HDMI1", "HDMI2"}
+	if !reflect.DeepEqual(rule.DisableOrder, want) {
+		t.Errorf("disable_order not expanded: want %v, got %v", want, rule.DisableOrder)
+	}
+
+	rule = cfg.Rules[1]
+	if rule.ConfigureSingle != "LVDS1" {
+		t.Errorf("configure_single not expanded: want LVDS1, got %v", rule.ConfigureSingle)
+	}
+
+	if rule.Primary != "HDMI1,HDMI2,LVDS1" {
+		t.Errorf("primary not expanded: want HDMI1,HDMI2,LVDS1, got %v", rule.Primary)
+	}
+
+	wantGamma := map[string]string{"HDMI1": "1:0.9:0.8", "HDMI2": "1:1:1"}
+	if !reflect.DeepEqual(rule.Gamma, wantGamma) {
+		t.Errorf("gamma not expanded: want %v, got %v", wantGamma, rule.Gamma)
+	}
+
+	wantTearFree := map[string]bool{"DP2-1": true, "HDMI1": true, "HDMI2": true}
+	if !reflect.DeepEqual(rule.TearFree, wantTearFree) {
+		t.Errorf("tear_free not expanded: want %v, got %v", wantTearFree, rule.TearFree)
+	}
+
+	if rule := cfg.Rules[2]; rule.ConfigureSingle != "DP-3" {
+		t.Errorf("environment variable in group not expanded: want DP-3, got %v", rule.ConfigureSingle)
+	}
+}
+
+func TestConfigGroupsInvalid(t *testing.T) {
+	var tests = []string{
+		`
+groups:
+  a: [HDMI1, "@b"]
+  b: ["@a"]
+rules:
+  - configure_row: ["@a"]
+`,
+		`
+groups:
+  a: ["@a"]
+rules:
+  - outputs_present: ["@a"]
+`,
+		`
+rules:
+  - configure_row: ["@missing"]
+`,
+		`
+groups:
+  hdmi: [HDMI1, HDMI2]
+rules:
+  - configure_single: "@hdmi"
+`,
+		`
+groups:
+  a: [HDMI1, HDMI2]
+  b: [HDMI2]
+rules:
+  - configure_row: ["@a"]
+    rotate:
+      "@a": left
+      "@b": right
+`,
+		`
+rules:
+  - configure_single: HDMI1
+    gamma:
+      "@missing": 1:1:1
+`,
+	}
+
+	for i, test := range tests {
+		if _, err := parseConfig([]byte(test)); err == nil {
+			t.Errorf("test %d: expected error, got nil", i)
+		}
+	}
+}
+
+func TestConfigGamma(t *testing.T) {
+	var tests = []struct {
+		config string
+		valid  bool
+	}{
+		{`
+gamma_presets:
+  evening: 1:0.85:0.7
+rules:
+  - configure_single: HDMI1
+    gamma:
+      HDMI1: evening
+`, true},
+		{`
+rules:
+  - configure_single: HDMI1
+    gamma:
+      HDMI1: night
+`, true},
+		{`
+rules:
+  - configure_single: HDMI1
+    gamma:
+      HDMI1: evening
+`, false},
+		{`
+gamma_presets:
+  evening: warm
+rules:
+  - configure_single: HDMI1
+`, false},
+		{`
+gamma_presets:
+  evening: 1:0.85
+rules:
+  - configure_single: HDMI1
+`, false},
+	}
+
+	for i, test := range tests {
+		_, err := parseConfig([]byte(test.config))
+		if test.valid && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("test %d: expected error, got nil", i)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"pkg/randr"
+)
+
+// defaultHistoryMaxSize is the size in bytes at which the history file is
+// rotated if history_max_size is not set.
+const defaultHistoryMaxSize = 1 << 20
+
+// historyLine returns the line recorded in the history file when rule was
+// applied to outputs at time t.
+func historyLine(t time.Time, rule string, outputs randr.Outputs, success bool) string {
+	var connected []string
+	for _, o := range outputs {
+		if o.Connected {
+			connected = append(connected, o.Name)
+		}
+	}
+
+	result := "ok"
+	if !success {
+		result = "failed"
+	}
+
+	return fmt.Sprintf("%v rule=%q connected=%v %v\n",
+		t.Format(time.RFC3339), rule, strings.Join(connected, ","), result)
+}
+
+// appendHistory appends line to the history file filename. If the file would
+// grow beyond maxSize bytes, it is renamed to filename.1 first, replacing the
+// previous one, and a new file is started.
+func appendHistory(filename string, maxSize int64, line string) error {
+	if maxSize <= 0 {
+		maxSize = defaultHistoryMaxSize
+	}
+
+	fi, err := os.Stat(filename)
+	if err == nil && fi.Size()+int64(len(line)) > maxSize {
+		if err = os.Rename(filename, filename+".1"); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(line)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+func TestHistoryLine(t *testing.T) {
+	ts := time.Date(2016, 1, 5, 15, 4, 5, 0, time.UTC)
+
+	got := historyLine(ts, "Docked", testOutputs, true)
+	want := "2016-01-05T15:04:05Z rule=\"Docked\" connected=LVDS,VGA,HDMI ok\n"
+	if got != want {
+		t.Errorf("wrong line:\n  want %q\n  got  %q", want, got)
+	}
+
+	got = historyLine(ts, "Mobile", testOutputs[3:], false)
+	want = "2016-01-05T15:04:05Z rule=\"Mobile\" connected= failed\n"
+	if got != want {
+		t.Errorf("wrong line:\n  want %q\n  got  %q", want, got)
+	}
+}
+
+func TestAppendHistoryRotate(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	filename := filepath.Join(tempdir, "history")
+	line := strings.Repeat("x", 9) + "\n"
+
+	for i := 0; i < 3; i++ {
+		if err := appendHistory(filename, 30, line); err != nil {
+			t.Fatalf("appendHistory returned error: %v", err)
+		}
+	}
+
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != strings.Repeat(line, 3) {
+		t.Fatalf("entries not appended: %q", buf)
+	}
+
+	if _, err := os.Stat(filename + ".1"); !os.IsNotExist(err) {
+		t.Fatalf("history rotated too early: %v", err)
+	}
+
+	// the fourth line exceeds the maximum size
+	if err := appendHistory(filename, 30, line); err != nil {
+		t.Fatalf("appendHistory returned error: %v", err)
+	}
+
+	buf, err = ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != line {
+		t.Errorf("wrong content after rotation: %q", buf)
+	}
+
+	old, err := ioutil.ReadFile(filename + ".1")
+	if err != nil {
+		t.Fatalf("rotated file not found: %v", err)
+	}
+
+	if string(old) != strings.Repeat(line, 3) {
+		t.Errorf("wrong content of the rotated file: %q", old)
+	}
+}
+
+func TestApplierHistoryFile(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	defer func(cfg *Config) { globalOpts.cfg = cfg }(globalOpts.cfg)
+	filename := filepath.Join(tempdir, "history")
+	globalOpts.cfg = &Config{HistoryFile: filename}
+
+	a := NewApplier(testOutputs)
+	a.query = func() (randr.Outputs, error) { return testOutputs, nil }
+	a.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		if rule.Name == "Broken" {
+			return ApplyResult{Rule: rule.Name}, errors.New("xrandr failed")
+		}
+		return ApplyResult{Rule: rule.Name}, nil
+	}
+
+	if _, err = a.Apply(randr.Rule{Name: "Docked"}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	if _, err = a.Apply(randr.Rule{Name: "Broken"}); err == nil {
+		t.Fatalf("Apply did not return the error")
+	}
+
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("history file not written: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if len(lines) != 2 ||
+		!strings.HasSuffix(lines[0], `rule="Docked" connected=LVDS,VGA,HDMI ok`) ||
+		!strings.HasSuffix(lines[1], `rule="Broken" connected=LVDS,VGA,HDMI failed`) {
+		t.Errorf("wrong history:\n%s", buf)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"pkg/randr"
+)
+
+// defaultHookShell is the shell used to run hooks when none is configured.
+const defaultHookShell = "sh"
+
+// hookExecutor runs the command for a hook and returns its combined output.
+// It is replaced in tests.
+var hookExecutor = func(cmd *exec.Cmd) ([]byte, error) {
+	return cmd.CombinedOutput()
+}
+
+// HookCommand returns the command which runs hook via `shell -c`. When shell
+// is empty, the default shell is used.
+func HookCommand(shell, hook string) *exec.Cmd {
+	if shell == "" {
+		shell = defaultHookShell
+	}
+
+	return exec.Command(shell, "-c", hook)
+}
+
+// hookStdinOutputsJSON is the value for the hook_stdin option which passes
+// the outputs as JSON to hooks on stdin.
+const hookStdinOutputsJSON = "outputs-json"
+
+// hookStdin returns the data hooks receive on stdin for the hook_stdin option
+// value mode.
+func hookStdin(mode string, outputs randr.Outputs) ([]byte, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case hookStdinOutputsJSON:
+		return json.Marshal(outputs)
+	default:
+		return nil, fmt.Errorf("unknown hook_stdin value %q", mode)
+	}
+}
+
+// RunHook runs hook in shell with env added to the environment and stdin
+// passed on standard input. If globalOpts.DryRun is true, the hook is not run
+// and only the command line is printed, so that a dry run has no side
+// effects. The output of a failed hook is included in the returned error.
+func RunHook(shell, hook string, env []string, stdin []byte) error {
+	cmd := HookCommand(shell, hook)
+	if globalOpts.DryRun {
+		fmt.Fprintf(dryRunOutput, "%s %s %q\n", cmd.Args[0], cmd.Args[1], hook)
+		return nil
+	}
+
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	verbosePrintf("running hook %q\n", hook)
+	setDisplay(cmd)
+	out, err := hookExecutor(cmd)
+	if globalOpts.Verbose {
+		os.Stdout.Write(out)
+	}
+
+	if err != nil {
+		return fmt.Errorf("hook %q failed: %v, output: %q", hook, err, out)
+	}
+
+	return nil
+}
+
+// runHookAsync runs hook for the named rule like RunHook in the background
+// and returns immediately. A failure is printed when the hook has finished.
+func runHookAsync(rule, shell, hook string, env []string, stdin []byte) {
+	go func() {
+		if err := RunHook(shell, hook, env, stdin); err != nil {
+			fmt.Fprintf(stderrOutput, "executing hook for rule %v failed: %v\n", rule, err)
+			return
+		}
+
+		verbosePrintf("hook %q for rule %v finished\n", hook, rule)
+	}()
+}
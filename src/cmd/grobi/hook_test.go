@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pkg/randr"
+)
+
+func TestRunHookShellPipe(t *testing.T) {
+	defer func(old func(*exec.Cmd) ([]byte, error)) { hookExecutor = old }(hookExecutor)
+
+	var args []string
+	var output string
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		out, err := cmd.CombinedOutput()
+		output = string(out)
+		return out, err
+	}
+
+	hook := "echo foo | tr a-z A-Z"
+	if err := RunHook("", hook, nil, nil); err != nil {
+		t.Fatalf("RunHook returned error: %v", err)
+	}
+
+	want := []string{"sh", "-c", hook}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("wrong command: want %v, got %v", want, args)
+	}
+
+	if output != "FOO\n" {
+		t.Errorf("wrong hook output: want %q, got %q", "FOO\n", output)
+	}
+}
+
+func TestRunHookError(t *testing.T) {
+	defer func(old func(*exec.Cmd) ([]byte, error)) { hookExecutor = old }(hookExecutor)
+
+	var args []string
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		return []byte("something broke"), errors.New("exit status 1")
+	}
+
+	err := RunHook("/bin/bash", "false", nil, nil)
+	if err == nil {
+		t.Fatalf("RunHook did not return an error")
+	}
+
+	if !strings.Contains(err.Error(), "something broke") {
+		t.Errorf("error does not contain the hook output: %v", err)
+	}
+
+	want := []string{"/bin/bash", "-c", "false"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("wrong command: want %v, got %v", want, args)
+	}
+}
+
+func TestApplyRuleHookStdinJSON(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, hook func(*exec.Cmd) ([]byte, error), cfg *Config) {
+		runCommand = run
+		hookExecutor = hook
+		globalOpts.cfg = cfg
+	}(runCommand, hookExecutor, globalOpts.cfg)
+
+	var received randr.Outputs
+	var calls int
+	runCommand = func(cmd *exec.Cmd) error { return nil }
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) {
+		calls++
+		buf, err := ioutil.ReadAll(cmd.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return nil, json.Unmarshal(buf, &received)
+	}
+	globalOpts.cfg = &Config{HookStdin: "outputs-json"}
+
+	rule := randr.Rule{
+		Name:            "Projector",
+		ConfigureSingle: "VGA",
+		ExecuteAfter:    []string{"my-script"},
+	}
+
+	result, err := ApplyRule(testOutputs, rule)
+	if err != nil {
+		t.Fatalf("ApplyRule returned error: %v", err)
+	}
+
+	if calls != 1 || !result.Success() {
+		t.Fatalf("hook not run successfully: %v", result.Commands)
+	}
+
+	if !reflect.DeepEqual(received, testOutputs) {
+		t.Errorf("hook received wrong outputs:\n  want %v\n  got  %v", testOutputs, received)
+	}
+}
+
+func TestApplyRuleDryRunSkipsHooks(t *testing.T) {
+	defer func(hook func(*exec.Cmd) ([]byte, error), out io.Writer, dryRun bool, cfg *Config) {
+		hookExecutor = hook
+		dryRunOutput = out
+		globalOpts.DryRun = dryRun
+		globalOpts.cfg = cfg
+	}(hookExecutor, dryRunOutput, globalOpts.DryRun, globalOpts.cfg)
+
+	var calls int
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) {
+		calls++
+		return nil, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	dryRunOutput = buf
+	globalOpts.DryRun = true
+	globalOpts.cfg = &Config{ExecuteAfter: []string{"notify-send 'new layout'"}}
+
+	rule := randr.Rule{
+		Name:            "Projector",
+		ConfigureSingle: "VGA",
+		ExecuteAfter:    []string{"pkill xautolock"},
+	}
+
+	if _, err := ApplyRule(testOutputs, rule); err != nil {
+		t.Fatalf("ApplyRule returned error: %v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("hook executor was called %d times during dry run", calls)
+	}
+
+	// the order of the outputs disabled by xrandr varies, only check that
+	// the hooks are printed after the xrandr commands
+	out := buf.String()
+	want := "sh -c \"notify-send 'new layout'\"\n" +
+		"sh -c \"pkill xautolock\"\n"
+	if !strings.HasPrefix(out, "xrandr ") || !strings.HasSuffix(out, want) {
+		t.Errorf("wrong output, want hooks %q, got %q", want, out)
+	}
+}
+
+// messageWriter sends each message written to it to the channel.
+type messageWriter chan string
+
+func (w messageWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestApplyRuleAsyncHooks(t *testing.T) {
+	defer func(run func(*exec.Cmd) error, hook func(*exec.Cmd) ([]byte, error), cfg *Config, verbose io.Writer, v bool) {
+		runCommand = run
+		hookExecutor = hook
+		globalOpts.cfg = cfg
+		verboseOutput = verbose
+		globalOpts.Verbose = v
+	}(runCommand, hookExecutor, globalOpts.cfg, verboseOutput, globalOpts.Verbose)
+
+	// the hook is finished when its goroutine reports it, it must not
+	// touch the outputs of later tests
+	messages := make(messageWriter, 100)
+	verboseOutput = messages
+	globalOpts.Verbose = true
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	runCommand = func(cmd *exec.Cmd) error { return nil }
+	hookExecutor = func(cmd *exec.Cmd) ([]byte, error) {
+		<-release
+		close(done)
+		return nil, nil
+	}
+	globalOpts.cfg = &Config{}
+
+	rule := randr.Rule{
+		Name:            "Projector",
+		ConfigureSingle: "VGA",
+		ExecuteAfter:    []string{"restart-compositor"},
+		Async:           true,
+	}
+
+	result, err := ApplyRule(testOutputs, rule)
+	if err != nil {
+		t.Fatalf("ApplyRule returned error: %v", err)
+	}
+
+	select {
+	case <-done:
+		t.Fatalf("ApplyRule waited for the async hook")
+	default:
+	}
+
+	for _, c := range result.Commands {
+		if c.Args[0] != "xrandr" {
+			t.Errorf("async hook recorded in result: %v", c.Args)
+		}
+	}
+
+	close(release)
+	<-done
+
+	for msg := range messages {
+		if strings.Contains(msg, "finished") {
+			break
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
+	"pkg/randr"
 )
 
 // GlobalOptions contains all global options.
@@ -16,6 +20,10 @@ type GlobalOptions struct {
 	DryRun       bool   `short:"n" long:"dry-run"                     description:"Only print what commands would be executed without actually runnig them"`
 	PollInterval uint   `short:"i" long:"interval"    default:"5"     description:"Number of seconds between polls, set to zero to disable polling"`
 	Pause        uint   `short:"p" long:"pause"       default:"2"     description:"Number of seconds to pause after a change was executed"`
+	Display      string `          long:"display"     env:"GROBI_DISPLAY" description:"Use this X display instead of $DISPLAY"`
+
+	Settle         time.Duration `long:"settle"                           description:"Wait this long for the outputs to settle between two calls to xrandr"`
+	SettleStrategy string        `long:"settle-strategy" default:"sleep" description:"Wait for the whole settle time (sleep) or until the outputs stop changing (stabilize)"`
 
 	cfg *Config
 }
@@ -34,32 +42,135 @@ func (gopts *GlobalOptions) ReadConfigfile() {
 	gopts.cfg = &cfg
 }
 
+// ReadConfigfileIfPresent reads the config file like ReadConfigfile, but an
+// empty config is used if there is none. It is used by commands which do not
+// need any rules, so that they query the outputs like the other commands.
+func (gopts *GlobalOptions) ReadConfigfileIfPresent() {
+	if gopts.cfg != nil {
+		return
+	}
+
+	cfg, err := readConfig(gopts.Config)
+	if err == errNoConfigFile {
+		cfg, err = Config{}, nil
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading config file: %v\n", err)
+		os.Exit(1)
+	}
+
+	gopts.cfg = &cfg
+}
+
+// config returns the configuration read from the config file, or an empty
+// configuration if none has been read.
+func (gopts *GlobalOptions) config() Config {
+	if gopts.cfg == nil {
+		return Config{}
+	}
+
+	return *gopts.cfg
+}
+
+// setDisplay sets DISPLAY in the environment of cmd if globalOpts.Display is
+// not empty.
+func setDisplay(cmd *exec.Cmd) {
+	if globalOpts.Display == "" {
+		return
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "DISPLAY="+globalOpts.Display)
+}
+
+// runCommand executes cmd, it is replaced in tests.
+var runCommand = func(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// stderrOutput is where the standard error output of commands is copied to,
+// it is replaced in tests.
+var stderrOutput io.Writer = os.Stderr
+
+// CommandError is returned when a command failed, it contains the standard
+// error output of the command, e.g. to detect known xrandr errors.
+type CommandError struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%v: %v", strings.Join(e.Args, " "), e.Err)
+}
+
+// captureStderr copies the standard error output of cmd to stderrOutput and
+// the returned buffer.
+func captureStderr(cmd *exec.Cmd) *bytes.Buffer {
+	buf := bytes.NewBuffer(nil)
+	cmd.Stderr = io.MultiWriter(buf, stderrOutput)
+	return buf
+}
+
+// commandError returns a *CommandError for err returned by cmd, or nil if
+// err is nil.
+func commandError(cmd *exec.Cmd, stderr *bytes.Buffer, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &CommandError{Args: cmd.Args, Stderr: stderr.String(), Err: err}
+}
+
 // RunCommand runs the given command or prints the arguments to stdout if
-// globalOpts.DryRun is true.
+// globalOpts.DryRun is true. If the command fails, a *CommandError is
+// returned.
 func RunCommand(cmd *exec.Cmd) error {
 	if globalOpts.DryRun {
 		s := fmt.Sprintf("%s", cmd.Args)
-		fmt.Printf("%s\n", s[1:len(s)-1])
+		fmt.Fprintf(dryRunOutput, "%s\n", s[1:len(s)-1])
 		return nil
 	}
 
 	verbosePrintf("running command %v %v\n", cmd.Path, strings.Join(cmd.Args, " "))
-	cmd.Stderr = os.Stderr
+	setDisplay(cmd)
+	stderr := captureStderr(cmd)
 	if globalOpts.Verbose {
 		cmd.Stdout = os.Stdout
 	}
-	return cmd.Run()
+	return commandError(cmd, stderr, runCommand(cmd))
 }
 
 var globalOpts = GlobalOptions{}
 var parser = flags.NewParser(&globalOpts, flags.Default)
 
+// dryRunOutput is where the commands are printed to instead of running them
+// when globalOpts.DryRun is set, it is replaced in tests.
+var dryRunOutput io.Writer = os.Stdout
+
+// verboseOutput is where verbosePrintf writes to, it is replaced in tests.
+var verboseOutput io.Writer = os.Stdout
+
 func verbosePrintf(format string, args ...interface{}) {
 	if !globalOpts.Verbose {
 		return
 	}
 
-	fmt.Printf(format, args...)
+	fmt.Fprintf(verboseOutput, format, args...)
+}
+
+// warnOutput is where warnf writes to, it is replaced in tests.
+var warnOutput io.Writer = os.Stderr
+
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(warnOutput, "warning: "+format, args...)
+}
+
+func init() {
+	randr.Logf = verbosePrintf
 }
 
 func main() {
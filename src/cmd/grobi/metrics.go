@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Metrics records what the watch loop does.
+type Metrics interface {
+	// Applied records that a rule has been applied at t, success is false
+	// if a command failed.
+	Applied(t time.Time, success bool)
+
+	// Outputs records the number of connected outputs.
+	Outputs(connected int)
+}
+
+// noMetrics discards all metrics.
+type noMetrics struct{}
+
+func (noMetrics) Applied(time.Time, bool) {}
+func (noMetrics) Outputs(int)             {}
+
+// watchMetrics collects the metrics of the watch loop and serves them in the
+// text format understood by Prometheus.
+type watchMetrics struct {
+	mu        sync.Mutex
+	applies   int
+	failed    int
+	lastApply time.Time
+	connected int
+}
+
+func (m *watchMetrics) Applied(t time.Time, success bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.applies++
+	if !success {
+		m.failed++
+	}
+	m.lastApply = t
+}
+
+func (m *watchMetrics) Outputs(connected int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.connected = connected
+}
+
+func (m *watchMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last int64
+	if !m.lastApply.IsZero() {
+		last = m.lastApply.Unix()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, metric := range []struct {
+		name, typ, help string
+		value           int64
+	}{
+		{"grobi_applies_total", "counter", "Number of rules applied.", int64(m.applies)},
+		{"grobi_applies_failed_total", "counter", "Number of rules which failed to apply.", int64(m.failed)},
+		{"grobi_last_apply_timestamp_seconds", "gauge", "Time a rule was last applied.", last},
+		{"grobi_connected_outputs", "gauge", "Number of connected outputs.", int64(m.connected)},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n",
+			metric.name, metric.help, metric.name, metric.typ, metric.name, metric.value)
+	}
+}
+
+// serveMetrics serves the metrics on addr at /metrics in the background. An
+// error is returned if addr cannot be listened on.
+func serveMetrics(addr string, m *watchMetrics) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+
+	go func() {
+		err := http.Serve(ln, mux)
+		warnf("metrics server stopped: %v\n", err)
+	}()
+
+	verbosePrintf("serving metrics on http://%v/metrics\n", ln.Addr())
+	return nil
+}
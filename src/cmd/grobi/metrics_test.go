@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+func TestWatcherMetrics(t *testing.T) {
+	rules := []randr.Rule{
+		{Name: "Projector", OutputsConnected: []string{"VGA"}, ConfigureSingle: "VGA"},
+	}
+
+	m := &watchMetrics{}
+	w := newWatcher(rules)
+	w.metrics = m
+	w.getOutputs = func() (randr.Outputs, error) { return testOutputs, nil }
+	w.now = func() time.Time { return time.Unix(1450000000, 0) }
+
+	var fail bool
+	w.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		result := ApplyResult{Rule: rule.Name}
+		if fail {
+			result.Commands = append(result.Commands, CommandResult{Err: errors.New("exit status 1")})
+		}
+		return result, nil
+	}
+
+	if _, err := w.update(false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	fail = true
+	if _, err := w.update(false, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	for _, line := range []string{
+		"grobi_applies_total 2",
+		"grobi_applies_failed_total 1",
+		"grobi_last_apply_timestamp_seconds 1450000000",
+		"grobi_connected_outputs 3",
+		"# TYPE grobi_applies_total counter",
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("line %q not found in metrics:\n%v", line, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"pkg/randr"
+)
+
+// seatCurrent is the value of the seat option which selects the seat of the
+// session grobi runs in.
+const seatCurrent = "current"
+
+// SeatLister lists the connectors of the graphics cards assigned to a seat,
+// e.g. "DP-1" for the device drm:card0-DP-1.
+type SeatLister interface {
+	SeatConnectors(seat string) ([]string, error)
+}
+
+// logindSeatLister asks systemd-logind with loginctl for the devices of a
+// seat.
+type logindSeatLister struct{}
+
+// SeatConnectors returns the connectors listed by `loginctl seat-status`.
+func (logindSeatLister) SeatConnectors(seat string) ([]string, error) {
+	cmd := exec.Command("loginctl", "seat-status", "--no-pager", "--full", seat)
+	stderr := captureStderr(cmd)
+	buf, err := cmd.Output()
+	if err != nil {
+		return nil, commandError(cmd, stderr, err)
+	}
+
+	return parseSeatStatus(string(buf)), nil
+}
+
+// seatLister lists the connectors of a seat, it is replaced in tests.
+var seatLister SeatLister = logindSeatLister{}
+
+// parseSeatStatus returns the connectors of the graphics cards in the output
+// of `loginctl seat-status`, which lists them as "drm:card0-DP-1" below the
+// sysfs path of the device.
+func parseSeatStatus(s string) []string {
+	var connectors []string
+	for _, field := range strings.Fields(s) {
+		if !strings.HasPrefix(field, "drm:card") {
+			continue
+		}
+
+		data := strings.SplitN(field, "-", 2)
+		if len(data) == 2 && data[1] != "" {
+			connectors = append(connectors, data[1])
+		}
+	}
+
+	return connectors
+}
+
+// connectorKey returns the name of an output or connector without dashes and
+// without the type of HDMI and DVI connectors, so that xrandr names of
+// different drivers (e.g. "HDMI1" and "HDMI-1") match the kernel name
+// ("HDMI-A-1").
+func connectorKey(name string) string {
+	for _, prefix := range []string{"HDMI-A-", "HDMI-B-", "DVI-I-", "DVI-D-", "DVI-A-"} {
+		if strings.HasPrefix(name, prefix) {
+			name = name[:strings.Index(prefix, "-")] + name[len(prefix)-1:]
+			break
+		}
+	}
+
+	return strings.ToLower(strings.Replace(name, "-", "", -1))
+}
+
+// filterSeat returns the outputs which are connected to one of the
+// connectors.
+func filterSeat(outputs randr.Outputs, connectors []string) randr.Outputs {
+	keys := make(map[string]struct{}, len(connectors))
+	for _, c := range connectors {
+		keys[connectorKey(c)] = struct{}{}
+	}
+
+	var res randr.Outputs
+	for _, o := range outputs {
+		if _, ok := keys[connectorKey(o.Name)]; ok {
+			res = append(res, o)
+		}
+	}
+
+	return res
+}
+
+// seatCache holds the connectors of the seat looked up last, so that
+// loginctl is not run whenever the outputs are polled. They are looked up
+// again when the outputs are rescanned, e.g. after a RandR event.
+var seatCache struct {
+	seat       string
+	connectors []string
+	valid      bool
+}
+
+// seatWarnings records the warnings about the seat which have been printed,
+// so that they are not repeated on every poll.
+var seatWarnings = make(map[string]struct{})
+
+// warnSeat prints a warning about the seat unless it has been printed before.
+func warnSeat(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if _, ok := seatWarnings[msg]; ok {
+		return
+	}
+	seatWarnings[msg] = struct{}{}
+
+	warnf("%s", msg)
+}
+
+// seatConnectors returns the connectors of seat, from the cache unless
+// refresh is true.
+func seatConnectors(seat string, refresh bool) ([]string, error) {
+	if !refresh && seatCache.valid && seatCache.seat == seat {
+		return seatCache.connectors, nil
+	}
+
+	connectors, err := seatLister.SeatConnectors(seat)
+	if err != nil {
+		return nil, err
+	}
+
+	seatCache.seat, seatCache.connectors, seatCache.valid = seat, connectors, true
+	return connectors, nil
+}
+
+// seatOutputs returns the outputs which belong to the configured seat. If no
+// seat is configured or the seat has no connectors (e.g. with drivers which
+// do not use the kernel for the outputs), all outputs are returned. The
+// connectors of the seat are only looked up again if refresh is true.
+func seatOutputs(outputs randr.Outputs, refresh bool) (randr.Outputs, error) {
+	seat := globalOpts.config().Seat
+	if seat == "" {
+		return outputs, nil
+	}
+
+	if seat == seatCurrent {
+		seat = os.Getenv("XDG_SEAT")
+		if seat == "" {
+			warnSeat("XDG_SEAT is not set, managing outputs of all seats\n")
+			return outputs, nil
+		}
+	}
+
+	connectors, err := seatConnectors(seat, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(connectors) == 0 {
+		warnSeat("no connectors found for seat %v, managing outputs of all seats\n", seat)
+		return outputs, nil
+	}
+
+	// some drivers name the outputs differently than the kernel (e.g.
+	// amdgpu counts from zero), all outputs are kept rather than none
+	res := filterSeat(outputs, connectors)
+	if len(res) == 0 {
+		warnSeat("no output matches the connectors %v of seat %v, managing outputs of all seats\n", strings.Join(connectors, ", "), seat)
+		return outputs, nil
+	}
+
+	return res, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"pkg/randr"
+)
+
+const testSeatStatus = `seat1
+	 Devices:
+		  ├─/sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/drm/card1
+		  │ [MASTER] drm:card1
+		  │ ├─/sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/drm/card1/card1-DP-2
+		  │ │ [MASTER] drm:card1-DP-2
+		  │ ├─/sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/drm/card1/card1-HDMI-A-1
+		  │ │ [MASTER] drm:card1-HDMI-A-1
+		  │ └─/sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/drm/renderD129
+		  │   drm:renderD129
+		  └─/sys/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/input/input8
+		    input:input8 "USB Keyboard"
+`
+
+// fakeSeatLister returns the connectors by seat.
+type fakeSeatLister map[string][]string
+
+func (f fakeSeatLister) SeatConnectors(seat string) ([]string, error) {
+	return f[seat], nil
+}
+
+func TestParseSeatStatus(t *testing.T) {
+	want := []string{"DP-2", "HDMI-A-1"}
+	if got := parseSeatStatus(testSeatStatus); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong connectors: want %v, got %v", want, got)
+	}
+}
+
+func TestConnectorKey(t *testing.T) {
+	var tests = []struct {
+		name, key string
+	}{
+		{"DP-1", "dp1"},
+		{"DP1", "dp1"},
+		{"eDP-1", "edp1"},
+		{"HDMI-A-1", "hdmi1"},
+		{"HDMI-1", "hdmi1"},
+		{"HDMI1", "hdmi1"},
+		{"DVI-I-2", "dvi2"},
+		{"DP-2-1", "dp21"},
+	}
+
+	for _, test := range tests {
+		if key := connectorKey(test.name); key != test.key {
+			t.Errorf("%v: wrong key: want %v, got %v", test.name, test.key, key)
+		}
+	}
+}
+
+func TestSeatOutputs(t *testing.T) {
+	defer func(lister SeatLister, cfg *Config, w io.Writer, output func(*exec.Cmd) ([]byte, error)) {
+		seatLister = lister
+		globalOpts.cfg = cfg
+		warnOutput = w
+		xrandrOutput = output
+	}(seatLister, globalOpts.cfg, warnOutput, xrandrOutput)
+
+	warnOutput = bytes.NewBuffer(nil)
+	seatLister = fakeSeatLister{
+		"seat0": {"eDP-1", "DP-1"},
+		"seat1": parseSeatStatus(testSeatStatus),
+		"seat2": {"DP-3"},
+	}
+	xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) {
+		return []byte(`Screen 0: minimum 8 x 8, current 1920 x 1080, maximum 32767 x 32767
+eDP1 connected 1920x1080+0+0 (normal left inverted right x axis y axis) 309mm x 174mm
+   1920x1080     60.02*+
+DP1 disconnected (normal left inverted right x axis y axis)
+DP2 connected (normal left inverted right x axis y axis)
+   2560x1440     59.95 +
+HDMI1 disconnected (normal left inverted right x axis y axis)
+`), nil
+	}
+
+	var tests = []struct {
+		seat  string
+		names []string
+	}{
+		{"", []string{"eDP1", "DP1", "DP2", "HDMI1"}},
+		{"seat0", []string{"eDP1", "DP1"}},
+		{"seat1", []string{"DP2", "HDMI1"}},
+		// no connectors or no matching outputs, all outputs are kept
+		{"seat2", []string{"eDP1", "DP1", "DP2", "HDMI1"}},
+		{"seat3", []string{"eDP1", "DP1", "DP2", "HDMI1"}},
+	}
+
+	for _, test := range tests {
+		globalOpts.cfg = &Config{Seat: test.seat}
+		outputs, err := GetOutputs()
+		if err != nil {
+			t.Fatalf("seat %q: GetOutputs returned error: %v", test.seat, err)
+		}
+
+		var names []string
+		for _, o := range outputs {
+			names = append(names, o.Name)
+		}
+
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("seat %q: wrong outputs: want %v, got %v", test.seat, test.names, names)
+		}
+	}
+
+	filtered := filterSeat(randr.Outputs{{Name: "HDMI-A-0"}}, []string{"HDMI-A-1"})
+	if len(filtered) != 0 {
+		t.Errorf("outputs with different numbers matched: %v", filtered)
+	}
+}
+
+// countingSeatLister counts the lookups of the connectors.
+type countingSeatLister struct {
+	connectors []string
+	calls      int
+}
+
+func (c *countingSeatLister) SeatConnectors(seat string) ([]string, error) {
+	c.calls++
+	return c.connectors, nil
+}
+
+func TestSeatOutputsCached(t *testing.T) {
+	defer func(lister SeatLister, cfg *Config, w io.Writer) {
+		seatLister = lister
+		globalOpts.cfg = cfg
+		warnOutput = w
+	}(seatLister, globalOpts.cfg, warnOutput)
+
+	lister := &countingSeatLister{}
+	seatLister = lister
+	seatCache.valid = false
+	seatWarnings = make(map[string]struct{})
+	globalOpts.cfg = &Config{Seat: "seat4"}
+
+	buf := bytes.NewBuffer(nil)
+	warnOutput = buf
+
+	outputs := randr.Outputs{{Name: "DP1"}, {Name: "HDMI1"}}
+	for _, refresh := range []bool{false, false, false, true} {
+		if _, err := seatOutputs(outputs, refresh); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if lister.calls != 2 {
+		t.Errorf("connectors looked up %d times, want 2", lister.calls)
+	}
+
+	want := "warning: no connectors found for seat seat4, managing outputs of all seats\n"
+	if buf.String() != want {
+		t.Errorf("wrong warnings, want:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"pkg/randr"
+)
+
+// Settler waits for the outputs to settle after xrandr changed them, before
+// the next command is run.
+type Settler interface {
+	Settle() error
+}
+
+// settleStrategies lists the values accepted by --settle-strategy.
+const (
+	settleSleep     = "sleep"
+	settleStabilize = "stabilize"
+)
+
+// newSettler returns the Settler for the strategy, which waits at most for
+// delay. It returns nil if delay is zero.
+func newSettler(strategy string, delay time.Duration) (Settler, error) {
+	switch strategy {
+	case settleSleep, "":
+	case settleStabilize:
+	default:
+		return nil, fmt.Errorf("unknown settle strategy %q, must be %q or %q", strategy, settleSleep, settleStabilize)
+	}
+
+	if delay <= 0 {
+		return nil, nil
+	}
+
+	if strategy == settleStabilize {
+		return &stabilizeSettler{
+			detect:   DetectOutputs,
+			interval: settlePollInterval,
+			timeout:  delay,
+			sleep:    time.Sleep,
+		}, nil
+	}
+
+	return sleepSettler{delay: delay}, nil
+}
+
+// sleepSettler waits for a fixed time.
+type sleepSettler struct {
+	delay time.Duration
+}
+
+func (s sleepSettler) Settle() error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+// settlePollInterval is the time between two queries of the outputs while
+// waiting for them to stabilize.
+var settlePollInterval = 100 * time.Millisecond
+
+// stabilizeSettler queries the outputs until they are the same for three
+// queries in a row, but not for longer than timeout.
+type stabilizeSettler struct {
+	detect   func() (randr.Outputs, error)
+	interval time.Duration
+	timeout  time.Duration
+
+	// sleep waits between two queries, it is replaced in tests.
+	sleep func(time.Duration)
+}
+
+func (s *stabilizeSettler) Settle() error {
+	last, err := s.detect()
+	if err != nil {
+		return err
+	}
+
+	var equal int
+	for waited := time.Duration(0); waited < s.timeout; waited += s.interval {
+		s.sleep(s.interval)
+
+		outputs, err := s.detect()
+		if err != nil {
+			return err
+		}
+
+		if !outputs.Equals(last) {
+			equal = 0
+			last = outputs
+			continue
+		}
+
+		equal++
+		if equal == 2 {
+			return nil
+		}
+	}
+
+	verbosePrintf("outputs did not settle within %v\n", s.timeout)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"pkg/randr"
+)
+
+func TestStabilizeSettler(t *testing.T) {
+	before := randr.Outputs{
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Active: true}, {Name: "1280x720"}}},
+	}
+	after := randr.Outputs{
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080"}, {Name: "1280x720", Active: true}}},
+	}
+
+	var tests = []struct {
+		queries []randr.Outputs
+		want    int
+	}{
+		// stable right away
+		{[]randr.Outputs{after, after, after}, 3},
+		// the outputs change once before they settle
+		{[]randr.Outputs{before, before, after, after, after}, 5},
+		// never settles, gives up after the timeout
+		{[]randr.Outputs{before, after, before, after, before, after, before, after, before, after, before, after}, 11},
+	}
+
+	for i, test := range tests {
+		var calls int
+		var slept time.Duration
+		s := &stabilizeSettler{
+			detect: func() (randr.Outputs, error) {
+				outputs := test.queries[calls]
+				calls++
+				return outputs, nil
+			},
+			interval: 100 * time.Millisecond,
+			timeout:  time.Second,
+			sleep:    func(d time.Duration) { slept += d },
+		}
+
+		if err := s.Settle(); err != nil {
+			t.Errorf("test %d: Settle returned error: %v", i, err)
+			continue
+		}
+
+		if calls != test.want {
+			t.Errorf("test %d: wrong number of queries, want %d, got %d", i, test.want, calls)
+		}
+
+		if slept > s.timeout {
+			t.Errorf("test %d: waited %v, longer than the timeout", i, slept)
+		}
+	}
+
+	s := &stabilizeSettler{
+		detect:   func() (randr.Outputs, error) { return nil, errors.New("xrandr failed") },
+		interval: time.Millisecond,
+		timeout:  time.Second,
+		sleep:    func(time.Duration) {},
+	}
+	if err := s.Settle(); err == nil {
+		t.Errorf("error of the query not returned")
+	}
+}
+
+func TestNewSettler(t *testing.T) {
+	if s, err := newSettler("sleep", 0); s != nil || err != nil {
+		t.Errorf("settler without delay: got %v, %v", s, err)
+	}
+
+	if s, err := newSettler("stabilize", time.Second); err != nil {
+		t.Errorf("newSettler returned error: %v", err)
+	} else if _, ok := s.(*stabilizeSettler); !ok {
+		t.Errorf("wrong settler %T for strategy stabilize", s)
+	}
+
+	if _, err := newSettler("udev", time.Second); err == nil {
+		t.Errorf("unknown strategy accepted")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"pkg/randr"
+)
+
+// Snapshot is a saved layout of the active outputs.
+type Snapshot struct {
+	Outputs []SnapshotOutput `yaml:"outputs"`
+}
+
+// SnapshotOutput is the configuration of an active output in a snapshot.
+type SnapshotOutput struct {
+	Name     string `yaml:"name"`
+	Mode     string `yaml:"mode"`
+	X        int    `yaml:"x"`
+	Y        int    `yaml:"y"`
+	Rotation string `yaml:"rotation"`
+	Primary  bool   `yaml:"primary"`
+}
+
+// byPosition sorts snapshot outputs left to right, then top to bottom.
+type byPosition []SnapshotOutput
+
+func (l byPosition) Len() int      { return len(l) }
+func (l byPosition) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byPosition) Less(i, j int) bool {
+	if l[i].X != l[j].X {
+		return l[i].X < l[j].X
+	}
+	return l[i].Y < l[j].Y
+}
+
+// NewSnapshot returns a snapshot of the connected and active outputs.
+func NewSnapshot(outputs randr.Outputs) Snapshot {
+	var snap Snapshot
+	for _, o := range outputs {
+		mode, ok := o.ActiveMode()
+		if !o.Connected || !ok {
+			continue
+		}
+
+		snap.Outputs = append(snap.Outputs, SnapshotOutput{
+			Name:     o.Name,
+			Mode:     mode.Name,
+			X:        o.Offset.X,
+			Y:        o.Offset.Y,
+			Rotation: o.Rotation,
+			Primary:  o.Primary,
+		})
+	}
+
+	sort.Sort(byPosition(snap.Outputs))
+	return snap
+}
+
+// Rule returns a rule named name which restores the layout of the snapshot.
+// All outputs not contained in the snapshot are disabled.
+func (s Snapshot) Rule(name string) randr.Rule {
+	rule := randr.Rule{
+		Name:      name,
+		Positions: make(map[string]string),
+		Rotate:    make(map[string]string),
+	}
+
+	for _, o := range s.Outputs {
+		rule.ConfigureRow = append(rule.ConfigureRow, o.Name+"@"+o.Mode)
+		rule.Positions[o.Name] = randr.Offset{X: o.X, Y: o.Y}.String()
+		if o.Rotation != "" {
+			rule.Rotate[o.Name] = o.Rotation
+		}
+		if o.Primary {
+			rule.Primary = o.Name
+		}
+	}
+
+	return rule
+}
+
+// snapshotFile returns the file name for the snapshot called name.
+func snapshotFile(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name[0] == '.' {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+
+	return filepath.Join(xdgConfigDir(), "grobi", "snapshots", name+".yaml"), nil
+}
+
+// writeSnapshot saves the snapshot to the file filename.
+func writeSnapshot(filename string, snap Snapshot) error {
+	buf, err := yaml.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, buf, 0644)
+}
+
+// readSnapshot loads a snapshot from the file filename.
+func readSnapshot(filename string) (Snapshot, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var snap Snapshot
+	err = yaml.Unmarshal(buf, &snap)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if len(snap.Outputs) == 0 {
+		return Snapshot{}, errors.New("snapshot does not contain any outputs")
+	}
+
+	return snap, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pkg/randr"
+)
+
+var testSnapshotOutputs = randr.Outputs{
+	{
+		Name:      "eDP1",
+		Connected: true,
+		Modes:     []randr.Mode{{Name: "1920x1080", Default: true, Active: true}},
+		Offset:    randr.Offset{X: 2560, Y: 0},
+		Rotation:  "normal",
+	},
+	{Name: "DP1"},
+	{
+		Name:      "DP2-2",
+		Connected: true,
+		Primary:   true,
+		Modes: []randr.Mode{
+			{Name: "2560x1440", Default: true, Active: true},
+			{Name: "1920x1080"},
+		},
+		Rotation: "normal",
+	},
+	{
+		Name:      "HDMI1",
+		Connected: true,
+		Modes:     []randr.Mode{{Name: "1920x1080", Default: true}},
+	},
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	snap := NewSnapshot(testSnapshotOutputs)
+	want := Snapshot{
+		Outputs: []SnapshotOutput{
+			{Name: "DP2-2", Mode: "2560x1440", Rotation: "normal", Primary: true},
+			{Name: "eDP1", Mode: "1920x1080", X: 2560, Rotation: "normal"},
+		},
+	}
+
+	if !reflect.DeepEqual(snap, want) {
+		t.Fatalf("wrong snapshot:\n  want %+v\n  got  %+v", want, snap)
+	}
+
+	filename := filepath.Join(tempdir, "snapshots", "work.yaml")
+	if err = writeSnapshot(filename, snap); err != nil {
+		t.Fatal(err)
+	}
+
+	snap2, err := readSnapshot(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(snap, snap2) {
+		t.Fatalf("snapshot changed after round trip:\n  want %+v\n  got  %+v", snap, snap2)
+	}
+}
+
+func TestSnapshotRestoreCommands(t *testing.T) {
+	snap := NewSnapshot(testSnapshotOutputs)
+
+	current := randr.Outputs{
+		{
+			Name:      "eDP1",
+			Connected: true,
+			Modes:     []randr.Mode{{Name: "1920x1080", Default: true, Active: true}},
+			Rotation:  "normal",
+		},
+		{
+			Name:      "DP2-2",
+			Connected: true,
+			Modes:     []randr.Mode{{Name: "2560x1440", Default: true}},
+		},
+		{
+			Name:      "HDMI1",
+			Connected: true,
+			Modes:     []randr.Mode{{Name: "1920x1080", Default: true, Active: true}},
+			Offset:    randr.Offset{X: 1920},
+			Rotation:  "normal",
+		},
+	}
+
+	cmds, err := randr.BuildCommandOutputRow(snap.Rule("work"), current, randr.Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "HDMI1", "--off"},
+		{"xrandr", "--output", "DP2-2", "--mode", "2560x1440", "--primary", "--rotate", "normal", "--pos", "0x0"},
+		{"xrandr", "--output", "eDP1", "--mode", "1920x1080", "--rotate", "normal", "--pos", "2560x0"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	for _, name := range []string{"", "../foo", "a/b", ".hidden"} {
+		if _, err := snapshotFile(name); err == nil {
+			t.Errorf("invalid snapshot name %q did not return an error", name)
+		}
+	}
+}
+
+func TestRestoreHistory(t *testing.T) {
+	defer func(output func(*exec.Cmd) ([]byte, error), run func(*exec.Cmd) error, cfg *Config, xdg string) {
+		xrandrOutput = output
+		runCommand = run
+		globalOpts.cfg = cfg
+		os.Setenv("XDG_CONFIG_HOME", xdg)
+	}(xrandrOutput, runCommand, globalOpts.cfg, os.Getenv("XDG_CONFIG_HOME"))
+
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	os.Setenv("XDG_CONFIG_HOME", tempdir)
+	xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) { return []byte(testXrandrDump), nil }
+
+	var run [][]string
+	runCommand = func(cmd *exec.Cmd) error {
+		run = append(run, cmd.Args)
+		return nil
+	}
+
+	history := filepath.Join(tempdir, "history")
+	globalOpts.cfg = &Config{HistoryFile: history}
+
+	outputs, err := randr.RandrParse(strings.NewReader(testXrandrDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename, err := snapshotFile("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writeSnapshot(filename, NewSnapshot(outputs)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = (CmdRestore{}).Execute([]string{"work"}); err != nil {
+		t.Fatalf("restore returned error: %v", err)
+	}
+
+	if len(run) == 0 {
+		t.Errorf("no commands run")
+	}
+
+	buf, err := ioutil.ReadFile(history)
+	if err != nil {
+		t.Fatalf("history not written: %v", err)
+	}
+
+	if !strings.Contains(string(buf), "work") {
+		t.Errorf("restore not recorded in the history: %q", buf)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"pkg/randr"
+)
+
+// State is the layout written to the state file after a rule has been
+// applied, for window managers which need to know the arrangement of the
+// outputs.
+type State struct {
+	Rule    string        `json:"rule"`
+	Outputs []StateOutput `json:"outputs"`
+}
+
+// StateOutput is the geometry of an active output, the outputs are ordered
+// left to right, then top to bottom.
+type StateOutput struct {
+	Name     string `json:"name"`
+	Mode     string `json:"mode"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Rotation string `json:"rotation"`
+	Primary  bool   `json:"primary"`
+}
+
+// NewState returns the state of the outputs after rule has been applied.
+func NewState(rule string, outputs randr.Outputs) State {
+	state := State{Rule: rule, Outputs: []StateOutput{}}
+	for _, o := range NewSnapshot(outputs).Outputs {
+		mode := randr.Mode{Name: o.Mode}
+		width, height := mode.Width(), mode.Height()
+		if o.Rotation == "left" || o.Rotation == "right" {
+			width, height = height, width
+		}
+
+		state.Outputs = append(state.Outputs, StateOutput{
+			Name:     o.Name,
+			Mode:     o.Mode,
+			X:        o.X,
+			Y:        o.Y,
+			Width:    width,
+			Height:   height,
+			Rotation: o.Rotation,
+			Primary:  o.Primary,
+		})
+	}
+
+	return state
+}
+
+// writeState writes the state as JSON to the file filename. The data is
+// written to a temporary file first, which is then renamed, so readers never
+// see a partially written file.
+func writeState(filename string, state State) error {
+	buf, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(buf, '\n'))
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
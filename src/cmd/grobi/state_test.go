@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pkg/randr"
+)
+
+func TestApplierStateFile(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	defer func(cfg *Config) { globalOpts.cfg = cfg }(globalOpts.cfg)
+	filename := filepath.Join(tempdir, "layout.json")
+	globalOpts.cfg = &Config{StateFile: filename}
+
+	after := randr.Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []randr.Mode{{Name: "1366x768", Default: true, Active: true}}, Offset: randr.Offset{X: 1080}, Rotation: "normal"},
+		{Name: "HDMI1", Connected: true, Modes: []randr.Mode{{Name: "1920x1080", Default: true, Active: true}}, Rotation: "left", Primary: true},
+		{Name: "VGA1"},
+	}
+
+	a := NewApplier(testOutputs)
+	a.query = func() (randr.Outputs, error) { return after, nil }
+	a.applyRule = func(outputs randr.Outputs, rule randr.Rule) (ApplyResult, error) {
+		return ApplyResult{Rule: rule.Name}, nil
+	}
+
+	if _, err = a.Apply(randr.Rule{Name: "Docked"}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+
+	want := `{
+  "rule": "Docked",
+  "outputs": [
+    {
+      "name": "HDMI1",
+      "mode": "1920x1080",
+      "x": 0,
+      "y": 0,
+      "width": 1080,
+      "height": 1920,
+      "rotation": "left",
+      "primary": true
+    },
+    {
+      "name": "LVDS1",
+      "mode": "1366x768",
+      "x": 1080,
+      "y": 0,
+      "width": 1366,
+      "height": 768,
+      "rotation": "normal",
+      "primary": false
+    }
+  ]
+}
+`
+
+	if string(buf) != want {
+		t.Errorf("wrong state file contents:\n%s", buf)
+	}
+
+	files, err := ioutil.ReadDir(tempdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("temporary file left behind: %v", files)
+	}
+}
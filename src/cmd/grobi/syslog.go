@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/syslog"
+	"strings"
+)
+
+// syslogWriter is the part of *syslog.Writer used for logging.
+type syslogWriter interface {
+	Info(string) error
+	Warning(string) error
+	Err(string) error
+}
+
+// newSyslog connects to the system logger, it is replaced in tests.
+var newSyslog = func() (syslogWriter, error) {
+	return syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "grobi")
+}
+
+// syslogLevel writes messages to syslog with one priority.
+type syslogLevel func(string) error
+
+func (l syslogLevel) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	if msg == "" {
+		return len(p), nil
+	}
+
+	if err := l(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setupLogging routes verbose messages, warnings and the error output of
+// commands to target, which is either "stderr" or "syslog". If syslog is not
+// available, messages are written to stderr.
+func setupLogging(target string) error {
+	switch target {
+	case "", "stderr":
+		return nil
+	case "syslog":
+	default:
+		return fmt.Errorf("invalid log target %q, must be stderr or syslog", target)
+	}
+
+	w, err := newSyslog()
+	if err != nil {
+		warnf("syslog is not available, logging to stderr: %v\n", err)
+		return nil
+	}
+
+	verboseOutput = syslogLevel(w.Info)
+	warnOutput = syslogLevel(w.Warning)
+	stderrOutput = syslogLevel(w.Err)
+	log.SetFlags(0)
+	log.SetOutput(syslogLevel(w.Err))
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeSyslog struct {
+	msgs []string
+}
+
+func (f *fakeSyslog) Info(msg string) error {
+	f.msgs = append(f.msgs, "info: "+msg)
+	return nil
+}
+
+func (f *fakeSyslog) Warning(msg string) error {
+	f.msgs = append(f.msgs, "warning: "+msg)
+	return nil
+}
+
+func (f *fakeSyslog) Err(msg string) error {
+	f.msgs = append(f.msgs, "err: "+msg)
+	return nil
+}
+
+func TestSetupLoggingSyslog(t *testing.T) {
+	defer func(verbose, warn, stderr io.Writer, newLog func() (syslogWriter, error), v bool) {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+		verboseOutput = verbose
+		warnOutput = warn
+		stderrOutput = stderr
+		newSyslog = newLog
+		globalOpts.Verbose = v
+	}(verboseOutput, warnOutput, stderrOutput, newSyslog, globalOpts.Verbose)
+
+	fake := &fakeSyslog{}
+	newSyslog = func() (syslogWriter, error) { return fake, nil }
+	globalOpts.Verbose = true
+
+	if err := setupLogging("syslog"); err != nil {
+		t.Fatal(err)
+	}
+
+	verbosePrintf("applying rule %v\n", "Docked")
+	warnf("rule %v was not applied as expected\n", "Docked")
+	stderrOutput.Write([]byte("xrandr: cannot find crtc for output HDMI1\n"))
+
+	want := []string{
+		"info: applying rule Docked",
+		"warning: warning: rule Docked was not applied as expected",
+		"err: xrandr: cannot find crtc for output HDMI1",
+	}
+
+	if !reflect.DeepEqual(fake.msgs, want) {
+		t.Errorf("wrong messages:\n  want %q\n  got  %q", want, fake.msgs)
+	}
+
+	if err := setupLogging("journal"); err == nil {
+		t.Errorf("invalid log target did not return an error")
+	}
+}
+
+func TestSetupLoggingSyslogUnavailable(t *testing.T) {
+	defer func(verbose, warn io.Writer, newLog func() (syslogWriter, error)) {
+		verboseOutput = verbose
+		warnOutput = warn
+		newSyslog = newLog
+	}(verboseOutput, warnOutput, newSyslog)
+
+	warn := bytes.NewBuffer(nil)
+	warnOutput = warn
+	newSyslog = func() (syslogWriter, error) { return nil, errors.New("no syslog") }
+
+	if err := setupLogging("syslog"); err != nil {
+		t.Fatal(err)
+	}
+
+	if warnOutput != warn || !strings.Contains(warn.String(), "no syslog") {
+		t.Errorf("warnings are not written to stderr any more, output: %q", warn.String())
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"pkg/randr"
+)
+
+// runXrandr returns the command which queries the outputs. The properties are
+// only included if a rule needs the EDID of the monitors, printing them makes
+// xrandr slower.
+func runXrandr(extraArgs ...string) *exec.Cmd {
+	args := []string{"--query"}
+	if needsProps() {
+		args = append(args, "--props")
+	}
+	args = append(args, extraArgs...)
+	cmd := exec.Command("xrandr", args...)
+	setDisplay(cmd)
+	return cmd
+}
+
+// needsProps returns true if a rule of the config matches the EDID of the
+// monitors.
+func needsProps() bool {
+	for _, rule := range globalOpts.config().Rules {
+		if rule.NeedsEDID() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// xrandrOutput runs cmd and returns its standard output, it is replaced in
+// tests.
+var xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) {
+	return cmd.Output()
+}
+
+// rawXrandr runs `xrandr` with extraArgs and returns the output without
+// parsing it. If xrandr fails, a *CommandError is returned.
+func rawXrandr(extraArgs ...string) ([]byte, error) {
+	cmd := runXrandr(extraArgs...)
+	stderr := captureStderr(cmd)
+	output, err := xrandrOutput(cmd)
+	if err != nil {
+		return nil, commandError(cmd, stderr, err)
+	}
+
+	return output, nil
+}
+
+// queryXrandr runs `xrandr` with extraArgs and returns the parsed output,
+// restricted to the outputs of the configured seat. The connectors of the
+// seat are looked up again if rescan is true. If xrandr fails, a
+// *CommandError is returned.
+func queryXrandr(rescan bool, extraArgs ...string) (randr.Outputs, error) {
+	output, err := rawXrandr(extraArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := randr.RandrParse(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+
+	return seatOutputs(outputs, rescan)
+}
+
+// Values for the get_query and detect_query options, which select whether
+// the X server rescans the outputs when they are queried.
+const (
+	queryCurrent = "current"
+	queryRescan  = "rescan"
+)
+
+// queryArgs returns the extra arguments to xrandr for the query variant, or
+// for def if variant is empty.
+func queryArgs(variant, def string) ([]string, error) {
+	if variant == "" {
+		variant = def
+	}
+
+	switch variant {
+	case queryCurrent:
+		return []string{"--current"}, nil
+	case queryRescan:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown query variant %q, must be %q or %q", variant, queryCurrent, queryRescan)
+	}
+}
+
+// GetOutputs runs `xrandr` and returns the parsed output. By default, the
+// outputs are not rescanned, this can be changed with get_query.
+func GetOutputs() (randr.Outputs, error) {
+	args, err := queryArgs(globalOpts.config().GetQuery, queryCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryXrandr(false, args...)
+}
+
+// DetectOutputs runs `xrandr` and returns the parsed output. By default, the
+// outputs are rescanned, this can be changed with detect_query.
+func DetectOutputs() (randr.Outputs, error) {
+	args, err := queryArgs(globalOpts.config().DetectQuery, queryRescan)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryXrandr(true, args...)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"pkg/randr"
+)
+
+func TestQueryVariant(t *testing.T) {
+	defer func(old func(*exec.Cmd) ([]byte, error), cfg *Config) {
+		xrandrOutput = old
+		globalOpts.cfg = cfg
+	}(xrandrOutput, globalOpts.cfg)
+
+	var args []string
+	xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		return []byte(testXrandrDump), nil
+	}
+
+	current := []string{"xrandr", "--query", "--current"}
+	rescan := []string{"xrandr", "--query"}
+
+	var tests = []struct {
+		cfg         *Config
+		get, detect []string
+	}{
+		{nil, current, rescan},
+		{&Config{}, current, rescan},
+		{&Config{GetQuery: "rescan"}, rescan, rescan},
+		{&Config{DetectQuery: "current"}, current, current},
+		{&Config{GetQuery: "rescan", DetectQuery: "current"}, rescan, current},
+	}
+
+	for i, test := range tests {
+		globalOpts.cfg = test.cfg
+
+		if _, err := GetOutputs(); err != nil {
+			t.Fatalf("test %d: GetOutputs returned error: %v", i, err)
+		}
+		if !reflect.DeepEqual(args, test.get) {
+			t.Errorf("test %d: wrong args for GetOutputs: want %v, got %v", i, test.get, args)
+		}
+
+		if _, err := DetectOutputs(); err != nil {
+			t.Fatalf("test %d: DetectOutputs returned error: %v", i, err)
+		}
+		if !reflect.DeepEqual(args, test.detect) {
+			t.Errorf("test %d: wrong args for DetectOutputs: want %v, got %v", i, test.detect, args)
+		}
+	}
+
+	globalOpts.cfg = &Config{DetectQuery: "probe"}
+	if _, err := DetectOutputs(); err == nil {
+		t.Errorf("unknown query variant did not return an error")
+	}
+
+	if err := globalOpts.cfg.Valid(); err == nil {
+		t.Errorf("config with unknown query variant is valid")
+	}
+}
+
+func TestQueryProps(t *testing.T) {
+	defer func(old func(*exec.Cmd) ([]byte, error), cfg *Config) {
+		xrandrOutput = old
+		globalOpts.cfg = cfg
+	}(xrandrOutput, globalOpts.cfg)
+
+	var args []string
+	xrandrOutput = func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		return []byte(testXrandrDump), nil
+	}
+
+	var tests = []struct {
+		rule randr.Rule
+		want []string
+	}{
+		{randr.Rule{Name: "Docked"}, []string{"xrandr", "--query", "--current"}},
+		{randr.Rule{Name: "Office", Vendor: map[string]string{"DP1": "DEL"}}, []string{"xrandr", "--query", "--props", "--current"}},
+		{randr.Rule{Name: "Home", ConnectedSerial: "1234"}, []string{"xrandr", "--query", "--props", "--current"}},
+	}
+
+	for _, test := range tests {
+		globalOpts.cfg = &Config{Rules: []randr.Rule{test.rule}}
+		if _, err := GetOutputs(); err != nil {
+			t.Fatalf("rule %v: GetOutputs returned error: %v", test.rule.Name, err)
+		}
+
+		if !reflect.DeepEqual(args, test.want) {
+			t.Errorf("rule %v: wrong arguments, want %v, got %v", test.rule.Name, test.want, args)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package randr
+
+import "strings"
+
+// Connector types returned by Output.ConnectorType.
+const (
+	connectorDisplayPort = "DisplayPort"
+	connectorHDMI        = "HDMI"
+	connectorInternal    = "Internal"
+	connectorVGA         = "VGA"
+	connectorDVI         = "DVI"
+)
+
+// connectorPrefixes maps prefixes of output names to the connector type. The
+// names differ between drivers, e.g. "DP1" (intel) and "DisplayPort-0"
+// (amdgpu), so the longer prefixes are tried first.
+var connectorPrefixes = []struct {
+	prefix, connector string
+}{
+	{"DisplayPort", connectorDisplayPort},
+	{"eDP", connectorInternal},
+	{"LVDS", connectorInternal},
+	{"DSI", connectorInternal},
+	{"DP", connectorDisplayPort},
+	{"HDMI", connectorHDMI},
+	{"VGA", connectorVGA},
+	{"DVI", connectorDVI},
+}
+
+// ConnectorType returns the type of the connector of the output derived from
+// its name, e.g. "DisplayPort" for DP-1, or the empty string if the type is
+// not known.
+func (o Output) ConnectorType() string {
+	for _, p := range connectorPrefixes {
+		if strings.HasPrefix(o.Name, p.prefix) {
+			return p.connector
+		}
+	}
+
+	return ""
+}
+
+// ConnectedType returns true iff an output with the connector type is
+// connected. The type is compared case-insensitively.
+func (os Outputs) ConnectedType(connector string) bool {
+	for _, o := range os {
+		if o.Connected && strings.EqualFold(o.ConnectorType(), connector) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validConnectorType returns true iff connector is a known connector type.
+func validConnectorType(connector string) bool {
+	for _, p := range connectorPrefixes {
+		if strings.EqualFold(p.connector, connector) {
+			return true
+		}
+	}
+
+	return false
+}
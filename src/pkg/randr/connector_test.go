@@ -0,0 +1,60 @@
+package randr
+
+import "testing"
+
+func TestOutputConnectorType(t *testing.T) {
+	var tests = []struct {
+		name, connector string
+	}{
+		{"DP1", "DisplayPort"},
+		{"DP-2-1", "DisplayPort"},
+		{"DisplayPort-0", "DisplayPort"},
+		{"HDMI1", "HDMI"},
+		{"HDMI-A-0", "HDMI"},
+		{"eDP1", "Internal"},
+		{"eDP-1", "Internal"},
+		{"LVDS1", "Internal"},
+		{"DSI-1", "Internal"},
+		{"VGA1", "VGA"},
+		{"DVI-I-1", "DVI"},
+		{"Virtual-1", ""},
+		{"screen1:DP1", ""},
+	}
+
+	for _, test := range tests {
+		if c := (Output{Name: test.name}).ConnectorType(); c != test.connector {
+			t.Errorf("output %v: wrong connector type: want %q, got %q", test.name, test.connector, c)
+		}
+	}
+}
+
+func TestRuleMatchConnectedType(t *testing.T) {
+	outputs := Outputs{
+		{Name: "eDP1", Connected: true},
+		{Name: "DP1", Connected: true},
+		{Name: "HDMI1"},
+	}
+
+	var tests = []struct {
+		connector string
+		match     bool
+	}{
+		{"", true},
+		{"DisplayPort", true},
+		{"displayport", true},
+		{"Internal", true},
+		{"HDMI", false},
+		{"VGA", false},
+	}
+
+	for _, test := range tests {
+		rule := Rule{ConnectedType: test.connector}
+		if m := rule.Match(outputs); m != test.match {
+			t.Errorf("connected_type %q: wrong match: want %v, got %v", test.connector, test.match, m)
+		}
+	}
+
+	if err := (Rule{ConnectedType: "Thunderbolt"}).Valid(); err == nil {
+		t.Errorf("unknown connector type did not return an error")
+	}
+}
@@ -0,0 +1,52 @@
+package randr
+
+// Change describes the differences between two lists of outputs by output
+// name.
+type Change struct {
+	// Added and Removed list the outputs which appeared or vanished.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+
+	// Connected and Disconnected list the outputs which were present before
+	// and changed their connection state.
+	Connected    []string `json:"connected,omitempty"`
+	Disconnected []string `json:"disconnected,omitempty"`
+
+	// Changed lists the outputs which are still (dis)connected, but whose
+	// modes, position, rotation or primary flag changed.
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty returns true iff no output changed.
+func (c Change) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 &&
+		len(c.Connected) == 0 && len(c.Disconnected) == 0 &&
+		len(c.Changed) == 0
+}
+
+// Diff returns the changes from the outputs before to the outputs after.
+func Diff(before, after Outputs) Change {
+	var c Change
+
+	for _, o := range after {
+		prev, ok := before.Get(o.Name)
+		switch {
+		case !ok:
+			c.Added = append(c.Added, o.Name)
+		case !prev.Connected && o.Connected:
+			c.Connected = append(c.Connected, o.Name)
+		case prev.Connected && !o.Connected:
+			c.Disconnected = append(c.Disconnected, o.Name)
+		case !prev.Equals(o) || prev.Offset != o.Offset || prev.Rotation != o.Rotation || prev.Primary != o.Primary:
+			c.Changed = append(c.Changed, o.Name)
+		}
+	}
+
+	for _, o := range before {
+		if _, ok := after.Get(o.Name); !ok {
+			c.Removed = append(c.Removed, o.Name)
+		}
+	}
+
+	return c
+}
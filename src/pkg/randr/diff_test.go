@@ -0,0 +1,39 @@
+package randr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1"},
+		{Name: "HDMI2", Connected: true},
+		{Name: "DP2-1"},
+	}
+
+	after := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}, Offset: Offset{X: 1920}},
+		{Name: "HDMI1", Connected: true},
+		{Name: "HDMI2"},
+		{Name: "DP1", Connected: true},
+	}
+
+	want := Change{
+		Added:        []string{"DP1"},
+		Removed:      []string{"DP2-1"},
+		Connected:    []string{"HDMI1"},
+		Disconnected: []string{"HDMI2"},
+		Changed:      []string{"LVDS1"},
+	}
+
+	c := Diff(before, after)
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("wrong diff:\n  want %+v\n  got  %+v", want, c)
+	}
+
+	if !Diff(after, after).Empty() {
+		t.Errorf("diff of identical outputs is not empty: %+v", Diff(after, after))
+	}
+}
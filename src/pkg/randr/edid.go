@@ -0,0 +1,102 @@
+package randr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// edidHeader is the fixed pattern every EDID block starts with.
+var edidHeader = []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}
+
+// EDIDVendor returns the manufacturer ID of the EDID block, the three letter
+// PNP code like "DEL" for Dell. It is encoded in bytes 8 and 9 as three
+// letters of five bits each, "A" is one.
+func EDIDVendor(edid []byte) (string, error) {
+	if len(edid) < 10 || !bytes.Equal(edid[:len(edidHeader)], edidHeader) {
+		return "", errors.New("invalid EDID header")
+	}
+
+	id := uint16(edid[8])<<8 | uint16(edid[9])
+
+	var vendor []byte
+	for _, shift := range []uint{10, 5, 0} {
+		c := (id >> shift) & 0x1f
+		if c < 1 || c > 26 {
+			return "", errors.New("invalid manufacturer ID in EDID")
+		}
+		vendor = append(vendor, byte('A'+c-1))
+	}
+
+	return string(vendor), nil
+}
+
+// edidSerialTag is the tag of the display descriptor which contains the
+// serial number as a string.
+const edidSerialTag = 0xff
+
+// EDIDSerial returns the serial number of the monitor from the EDID block.
+// The string from the serial number descriptor is preferred, it is what
+// monitors print on their label. Otherwise the 32 bit number in bytes 12 to
+// 15 is returned in decimal, or the empty string if it is zero.
+func EDIDSerial(edid []byte) (string, error) {
+	if len(edid) < 16 || !bytes.Equal(edid[:len(edidHeader)], edidHeader) {
+		return "", errors.New("invalid EDID header")
+	}
+
+	// the four descriptors are 18 bytes each, display descriptors start
+	// with two zero bytes followed by a reserved byte and the tag, the text
+	// ends with a newline and is padded with spaces
+	for offset := 54; offset+18 <= len(edid) && offset < 126; offset += 18 {
+		d := edid[offset : offset+18]
+		if d[0] != 0 || d[1] != 0 || d[3] != edidSerialTag {
+			continue
+		}
+
+		text := d[5:]
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+
+		if serial := strings.TrimSpace(string(text)); serial != "" {
+			return serial, nil
+		}
+	}
+
+	n := uint32(edid[12]) | uint32(edid[13])<<8 | uint32(edid[14])<<16 | uint32(edid[15])<<24
+	if n == 0 {
+		return "", nil
+	}
+
+	return strconv.FormatUint(uint64(n), 10), nil
+}
+
+// parseEDIDHex decodes the EDID printed by xrandr --props as hex and returns
+// the vendor and the serial number, or empty strings if the EDID is invalid.
+func parseEDIDHex(s string) (vendor, serial string) {
+	if s == "" {
+		return "", ""
+	}
+
+	buf, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		Logf("unable to decode EDID: %v\n", err)
+		return "", ""
+	}
+
+	vendor, err = EDIDVendor(buf)
+	if err != nil {
+		Logf("unable to parse EDID: %v\n", err)
+		return "", ""
+	}
+
+	serial, err = EDIDSerial(buf)
+	if err != nil {
+		Logf("unable to parse EDID: %v\n", err)
+		return vendor, ""
+	}
+
+	return vendor, serial
+}
@@ -0,0 +1,191 @@
+package randr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// testEDIDDell is the start of the EDID of a Dell U2515H.
+const testEDIDDell = "00ffffffffffff0010aca0d04c4a4b30" +
+	"2d19010380371f78ea9455a9554d9d26" +
+	"105054a54b00714f8180a9c0d1c00101"
+
+func TestEDIDVendor(t *testing.T) {
+	var tests = []struct {
+		edid   string
+		vendor string
+	}{
+		{testEDIDDell, "DEL"},
+		{"00ffffffffffff004c2d", "SAM"},
+		{"00ffffffffffff0030ae", "LEN"},
+		{"00ffffffffffff000000", ""},
+		{"00ffffffffffff0010", ""},
+		{"ffffffffffffffff10ac", ""},
+	}
+
+	for _, test := range tests {
+		buf, err := hex.DecodeString(test.edid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vendor, err := EDIDVendor(buf)
+		if test.vendor == "" {
+			if err == nil {
+				t.Errorf("%v: expected error, got vendor %v", test.edid, vendor)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: returned error: %v", test.edid, err)
+			continue
+		}
+
+		if vendor != test.vendor {
+			t.Errorf("%v: wrong vendor: want %v, got %v", test.edid, test.vendor, vendor)
+		}
+	}
+}
+
+// testEDIDSerial returns an EDID block with the number serial in bytes 12 to
+// 15 and, if text is not empty, a serial number descriptor containing it.
+func testEDIDSerial(serial uint32, text string) []byte {
+	buf := make([]byte, 128)
+	copy(buf, edidHeader)
+	buf[12], buf[13], buf[14], buf[15] = byte(serial), byte(serial>>8), byte(serial>>16), byte(serial>>24)
+
+	// the first descriptor is the detailed timing of the preferred mode
+	buf[54], buf[55] = 0x02, 0x3a
+
+	if text != "" {
+		d := buf[72:90]
+		d[3] = 0xff
+		copy(d[5:], []byte(text+"\n             "))
+	}
+
+	return buf
+}
+
+func TestEDIDSerial(t *testing.T) {
+	dell, err := hex.DecodeString(testEDIDDell)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		edid   []byte
+		serial string
+	}{
+		{dell, "810240588"},
+		{testEDIDSerial(0, ""), ""},
+		{testEDIDSerial(1234, ""), "1234"},
+		{testEDIDSerial(1234, "CN0ABC123"), "CN0ABC123"},
+		{testEDIDSerial(0, "1A2B3C4D5E6F7"), "1A2B3C4D5E6F7"},
+	}
+
+	for i, test := range tests {
+		serial, err := EDIDSerial(test.edid)
+		if err != nil {
+			t.Errorf("test %d: returned error: %v", i, err)
+			continue
+		}
+
+		if serial != test.serial {
+			t.Errorf("test %d: wrong serial: want %q, got %q", i, test.serial, serial)
+		}
+	}
+
+	if _, err := EDIDSerial(dell[:12]); err == nil {
+		t.Errorf("short EDID did not return an error")
+	}
+}
+
+func TestRuleMatchConnectedSerial(t *testing.T) {
+	outputs := Outputs{
+		{Name: "DP-1", Connected: true, Vendor: "DEL", Serial: "CN0ABC123"},
+		{Name: "DP-2", Connected: true},
+		{Name: "HDMI-1", Serial: "FX123"},
+	}
+
+	var tests = []struct {
+		serial string
+		match  bool
+	}{
+		{"", true},
+		{"CN0ABC123", true},
+		{"CN0*", true},
+		{"cn0*", false},
+		{"CN1*", false},
+		{"FX*", false},
+	}
+
+	for _, test := range tests {
+		rule := Rule{ConnectedSerial: test.serial}
+		if m := rule.Match(outputs); m != test.match {
+			t.Errorf("connected_serial %q: wrong match: want %v, got %v", test.serial, test.match, m)
+		}
+	}
+
+	if err := (Rule{ConnectedSerial: "CN0["}).Valid(); err == nil {
+		t.Errorf("malformed pattern did not return an error")
+	}
+}
+
+const testRandrProps = `Screen 0: minimum 8 x 8, current 2560 x 1440, maximum 32767 x 32767
+DP-1 connected primary 2560x1440+0+0 (normal left inverted right x axis y axis) 553mm x 311mm
+	EDID: 
+		00ffffffffffff0010aca0d04c4a4b30
+		2d19010380371f78ea9455a9554d9d26
+		105054a54b00714f8180a9c0d1c00101
+	Broadcast RGB: Automatic 
+		supported: Automatic, Full, Limited 16:235
+	audio: auto 
+		supported: force-dvi, off, auto, on
+   2560x1440     59.95*+
+   1920x1080     60.00  
+DP-2 disconnected (normal left inverted right x axis y axis)
+	Broadcast RGB: Automatic 
+		supported: Automatic, Full, Limited 16:235
+`
+
+func TestRandrParseEDID(t *testing.T) {
+	outputs, err := RandrParse(bytes.NewReader([]byte(testRandrProps)))
+	if err != nil {
+		t.Fatalf("RandrParse returned error: %v", err)
+	}
+
+	if len(outputs) != 2 {
+		t.Fatalf("wrong number of outputs: %v", outputs)
+	}
+
+	if outputs[0].Vendor != "DEL" || len(outputs[0].Modes) != 2 {
+		t.Errorf("wrong vendor or modes for DP-1: %q, %v", outputs[0].Vendor, outputs[0].Modes)
+	}
+
+	if outputs[0].Serial != "810240588" {
+		t.Errorf("wrong serial for DP-1: %q", outputs[0].Serial)
+	}
+
+	if outputs[1].Vendor != "" {
+		t.Errorf("vendor found for disconnected output: %v", outputs[1].Vendor)
+	}
+
+	var tests = []struct {
+		vendor map[string]string
+		match  bool
+	}{
+		{map[string]string{"DP-1": "DEL"}, true},
+		{map[string]string{"DP-*": "del"}, true},
+		{map[string]string{"DP-1": "SAM"}, false},
+		{map[string]string{"DP-2": "DEL"}, false},
+	}
+
+	for _, test := range tests {
+		rule := Rule{Vendor: test.vendor}
+		if m := rule.Match(outputs); m != test.match {
+			t.Errorf("vendor %v: wrong match: want %v, got %v", test.vendor, test.match, m)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package randr
+
+import "time"
+
+// Environment describes the state of the machine which the conditions of the
+// rules besides the outputs depend on. Fields which are empty keep the state
+// of the machine.
+type Environment struct {
+	// Time is used for active_between.
+	Time time.Time
+
+	// Hostname is used for hostname.
+	Hostname string
+
+	// Power is "ac" or "battery" and used for power.
+	Power string
+
+	// Processes lists the names of the running processes for
+	// unless_process, it is used if it is not nil.
+	Processes []string
+}
+
+// fixedPower reports a fixed power state.
+type fixedPower bool
+
+func (p fixedPower) OnAC() (bool, error) {
+	return bool(p), nil
+}
+
+// fixedProcesses reports a fixed list of processes.
+type fixedProcesses []string
+
+func (p fixedProcesses) Processes() ([]string, error) {
+	return p, nil
+}
+
+// SetEnvironment makes the conditions of the rules use env instead of the
+// state of the machine, e.g. for testing a config. The returned function
+// restores the previous state.
+func SetEnvironment(env Environment) (restore func()) {
+	oldTime, oldHostname, oldPower, oldProcesses := timeNow, hostname, powerSource, processLister
+
+	if !env.Time.IsZero() {
+		timeNow = func() time.Time { return env.Time }
+	}
+
+	if env.Hostname != "" {
+		hostname = func() (string, error) { return env.Hostname, nil }
+	}
+
+	if env.Power != "" {
+		powerSource = fixedPower(env.Power == powerAC)
+	}
+
+	if env.Processes != nil {
+		processLister = fixedProcesses(env.Processes)
+	}
+
+	return func() {
+		timeNow, hostname, powerSource, processLister = oldTime, oldHostname, oldPower, oldProcesses
+	}
+}
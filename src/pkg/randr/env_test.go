@@ -0,0 +1,34 @@
+package randr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetEnvironment(t *testing.T) {
+	rule := Rule{
+		ActiveBetween: "08:00-18:00",
+		Hostname:      "work*",
+		Power:         powerAC,
+		UnlessProcess: "vlc",
+	}
+
+	restore := SetEnvironment(Environment{
+		Time:      time.Date(2016, 5, 3, 9, 30, 0, 0, time.Local),
+		Hostname:  "workstation",
+		Power:     powerAC,
+		Processes: []string{"bash"},
+	})
+
+	if !rule.Match(nil) {
+		t.Errorf("rule does not match the environment: %v", rule.Conditions(nil))
+	}
+
+	restore()
+	restore = SetEnvironment(Environment{Processes: []string{"vlc"}})
+	defer restore()
+
+	if rule.Match(nil) {
+		t.Errorf("rule matches although vlc is running")
+	}
+}
@@ -0,0 +1,36 @@
+package randr_test
+
+import (
+	"fmt"
+	"strings"
+
+	"pkg/randr"
+)
+
+const exampleXrandr = `Screen 0: minimum 8 x 8, current 1366 x 768, maximum 32767 x 32767
+LVDS1 connected 1366x768+0+0 (normal left inverted right x axis y axis) 344mm x 193mm
+   1366x768      60.02*+
+   1024x768      60.00
+VGA1 connected (normal left inverted right x axis y axis)
+   1280x1024     60.02 +
+   1024x768      60.00
+`
+
+func ExampleBuildCommandOutputRow() {
+	outputs, err := randr.RandrParse(strings.NewReader(exampleXrandr))
+	if err != nil {
+		panic(err)
+	}
+
+	rule := randr.Rule{ConfigureRow: []string{"LVDS1", "VGA1"}, Atomic: true}
+	cmds, err := randr.BuildCommandOutputRow(rule, outputs, randr.Options{})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, cmd := range cmds {
+		fmt.Println(strings.Join(cmd.Args, " "))
+	}
+	// Output:
+	// xrandr --output LVDS1 --auto --output VGA1 --auto --right-of LVDS1
+}
@@ -0,0 +1,52 @@
+package randr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gammaPresets are the built-in gamma presets, as "R:G:B" for xrandr --gamma.
+var gammaPresets = map[string]string{
+	"normal": "1:1:1",
+	"warm":   "1:0.9:0.8",
+	"cool":   "0.9:0.95:1",
+	"night":  "1:0.75:0.5",
+}
+
+// parseGamma returns an error if s is not a gamma value of the form "R:G:B"
+// with positive numbers.
+func parseGamma(s string) error {
+	data := strings.Split(s, ":")
+	if len(data) != 3 {
+		return fmt.Errorf("gamma %q is not of the form R:G:B", s)
+	}
+
+	for _, str := range data {
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("gamma %q is not of the form R:G:B", s)
+		}
+	}
+
+	return nil
+}
+
+// ResolveGamma returns the gamma value "R:G:B" for value, which is either such
+// a value or the name of a preset. Presets defined in presets take precedence
+// over the built-in ones.
+func ResolveGamma(value string, presets map[string]string) (string, error) {
+	if strings.Contains(value, ":") {
+		return value, parseGamma(value)
+	}
+
+	if gamma, ok := presets[value]; ok {
+		return gamma, parseGamma(gamma)
+	}
+
+	if gamma, ok := gammaPresets[value]; ok {
+		return gamma, nil
+	}
+
+	return "", fmt.Errorf("unknown gamma preset %q", value)
+}
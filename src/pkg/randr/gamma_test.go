@@ -0,0 +1,78 @@
+package randr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveGamma(t *testing.T) {
+	presets := map[string]string{
+		"evening": "1:0.85:0.7",
+		"warm":    "1:0.95:0.9",
+	}
+
+	var tests = []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{"1:0.9:0.8", "1:0.9:0.8", false},
+		{"night", "1:0.75:0.5", false},
+		{"evening", "1:0.85:0.7", false},
+		{"warm", "1:0.95:0.9", false},
+		{"sepia", "", true},
+		{"1:0.9", "", true},
+		{"1:0:1", "", true},
+		{"1:x:1", "", true},
+	}
+
+	for i, test := range tests {
+		got, err := ResolveGamma(test.value, presets)
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d: expected error for %q, got %q", i, test.value, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: ResolveGamma(%q) returned error: %v", i, test.value, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("test %d: ResolveGamma(%q): want %q, got %q", i, test.value, test.want, got)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowGamma(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"DP1", "eDP1"},
+		Gamma:        map[string]string{"DP1": "warm", "eDP1": "evening"},
+	}
+	opts := Options{GammaPresets: map[string]string{"evening": "1:0.85:0.7"}}
+
+	cmds, err := BuildCommandOutputRow(rule, current, opts)
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "DP1", "--auto", "--gamma", "1:0.9:0.8"},
+		{"xrandr", "--output", "eDP1", "--auto", "--gamma", "1:0.85:0.7", "--right-of", "DP1"},
+	}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+
+	rule.Gamma["DP1"] = "sepia"
+	if _, err := BuildCommandOutputRow(rule, current, opts); err == nil {
+		t.Errorf("expected error for unknown preset")
+	}
+}
@@ -0,0 +1,788 @@
+package randr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OutputTarget describes the configuration of an output enabled by a rule.
+type OutputTarget struct {
+	Name string
+
+	// Mode is the mode requested by the rule, empty for the default mode
+	// (--auto). ModeName is the name of the mode the output is expected to
+	// use, it is empty if the mode is not known.
+	Mode     string
+	ModeName string
+
+	// Rate is the refresh rate to pass to xrandr, empty for the default
+	// rate of the mode.
+	Rate string
+
+	// Scale is the scale factor to pass to xrandr (e.g. "0.5x0.5"), empty
+	// if the output is not scaled.
+	Scale string
+
+	// Gamma is the gamma correction to pass to xrandr as "R:G:B", empty to
+	// keep the current one.
+	Gamma string
+
+	// Transform is the transformation matrix to pass to xrandr, empty to
+	// keep the current one.
+	Transform string
+
+	// Offset is the expected position of the output, OffsetKnown is false if
+	// it cannot be computed because the size of a previous output in the row
+	// is not known. Absolute is true if the position is set explicitly.
+	Offset      Offset
+	OffsetKnown bool
+	Absolute    bool
+
+	// Rotated is true if the rotation is set by the rule.
+	Rotation string
+	Rotated  bool
+	Primary  bool
+}
+
+// Unchanged returns true iff the output is active in current and configured
+// as described by the target. The rotation is only compared if the rule sets
+// it, otherwise xrandr keeps the current one.
+func (t OutputTarget) Unchanged(current Outputs) bool {
+	// the active refresh rate, scale, gamma and transform are not known
+	if t.ModeName == "" || !t.OffsetKnown || t.Rate != "" || t.Scale != "" || t.Gamma != "" || t.Transform != "" {
+		return false
+	}
+
+	cur, ok := current.Get(t.Name)
+	if !ok {
+		return false
+	}
+
+	mode, ok := cur.ActiveMode()
+	if !ok || mode.Name != t.ModeName || cur.Offset != t.Offset {
+		return false
+	}
+
+	return !t.Rotated || cur.Rotation == t.Rotation
+}
+
+// inlinePrimary is the inline option of an entry which makes the output the
+// primary output.
+const inlinePrimary = "primary"
+
+// SplitEntry splits an entry of ConfigureRow or ConfigureSingle of the form
+// name@mode@rate/option/... into the output name, the mode (which may be
+// followed by "@" and the rate) and the inline options, e.g. a rotation or
+// "primary". All parts except the name are optional.
+func SplitEntry(entry string) (name, mode string, options []string) {
+	data := strings.Split(entry, "/")
+	spec := strings.SplitN(data[0], "@", 2)
+	name = spec[0]
+	if len(spec) > 1 {
+		mode = spec[1]
+	}
+
+	return name, mode, data[1:]
+}
+
+// validEntryOptions returns an error if an inline option of the entry is
+// neither a rotation nor "primary".
+func validEntryOptions(entry string) error {
+	name, _, options := SplitEntry(entry)
+	for _, opt := range options {
+		if opt != inlinePrimary && !validRotation(opt) {
+			return fmt.Errorf("invalid option %q for output %v", opt, name)
+		}
+	}
+
+	return nil
+}
+
+// removeOutput returns the entries of row (names optionally followed by "@"
+// and a mode) without the ones for the named output.
+func removeOutput(row []string, name string) []string {
+	var res []string
+	for _, entry := range row {
+		if n, _, _ := SplitEntry(entry); n != name {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+// singleExternal returns the name of the only connected output which is not
+// the internal output.
+func singleExternal(current Outputs, opts Options) (string, error) {
+	internal, _ := current.internalOutputName(opts.InternalOutput)
+
+	var externals []string
+	for _, o := range current {
+		if o.Connected && o.Name != internal {
+			externals = append(externals, o.Name)
+		}
+	}
+
+	switch len(externals) {
+	case 0:
+		return "", errors.New("no external output connected")
+	case 1:
+		return externals[0], nil
+	default:
+		return "", fmt.Errorf("more than one external output connected: %v", strings.Join(externals, ", "))
+	}
+}
+
+// rowOutputs returns the entries of ConfigureSingle or ConfigureRow of the
+// rule, or the only external output if rule.SingleExternal is set. The
+// internal output is removed if rule.DisableInternal is set and disconnected
+// outputs which are still active are removed if opts.DisableStale is set.
+func rowOutputs(rule Rule, current Outputs, opts Options) ([]string, error) {
+	var outputs []string
+
+	switch {
+	case rule.SingleExternal:
+		external, err := singleExternal(current, opts)
+		if err != nil {
+			return nil, err
+		}
+		Logf("using single external output %v\n", external)
+		outputs = []string{external}
+	case rule.ConfigureSingle != "":
+		outputs = []string{rule.ConfigureSingle}
+	case len(rule.ConfigureRow) > 0:
+		outputs = rule.ConfigureRow
+	default:
+		return nil, errors.New("empty monitor row configuration")
+	}
+
+	if rule.DisableInternal {
+		internal, ok := current.internalOutputName(opts.InternalOutput)
+		if ok {
+			Logf("disabling internal output %v\n", internal)
+			outputs = removeOutput(outputs, internal)
+			if len(outputs) == 0 {
+				return nil, fmt.Errorf("no outputs left to enable after disabling internal output %v", internal)
+			}
+		}
+	}
+
+	if opts.DisableStale {
+		for _, o := range current {
+			if o.Connected || !o.Active() {
+				continue
+			}
+
+			Logf("disabling stale output %v\n", o.Name)
+			outputs = removeOutput(outputs, o.Name)
+			if len(outputs) == 0 {
+				return nil, fmt.Errorf("no outputs left to enable after disabling stale output %v", o.Name)
+			}
+		}
+	}
+
+	return outputs, nil
+}
+
+// rateTolerance is the maximal difference for a refresh rate to be considered
+// equal to the requested one, xrandr reports rates with two decimals.
+const rateTolerance = 0.005
+
+// selectRate returns the refresh rate for the mode of the output described by
+// refresh: "max" for the highest rate available, ">=N" for the lowest rate of
+// at least N, "<=N" for the highest rate of at most N or "N" for exactly N.
+func selectRate(output Output, mode, refresh string) (string, error) {
+	if mode == "" {
+		return "", errors.New("refresh rate set without a mode")
+	}
+
+	// xrandr may list several lines for the same mode name
+	var rates []float64
+	for _, m := range output.Modes {
+		if m.Name == mode {
+			rates = append(rates, m.Refresh...)
+		}
+	}
+
+	if len(rates) == 0 {
+		return "", fmt.Errorf("no refresh rate known for mode %v", mode)
+	}
+
+	var op string
+	var value float64
+	switch {
+	case refresh == "max":
+		op = refresh
+	case strings.HasPrefix(refresh, ">="), strings.HasPrefix(refresh, "<="):
+		op = refresh[:2]
+		refresh = refresh[2:]
+	}
+
+	if op != "max" {
+		var err error
+		value, err = strconv.ParseFloat(refresh, 64)
+		if err != nil || value <= 0 {
+			return "", fmt.Errorf("invalid refresh rate %q", refresh)
+		}
+	}
+
+	var found bool
+	var rate float64
+	for _, r := range rates {
+		switch op {
+		case "max":
+			if !found || r > rate {
+				rate, found = r, true
+			}
+		case ">=":
+			if r > value-rateTolerance && (!found || r < rate) {
+				rate, found = r, true
+			}
+		case "<=":
+			if r < value+rateTolerance && (!found || r > rate) {
+				rate, found = r, true
+			}
+		default:
+			if math.Abs(r-value) < rateTolerance {
+				rate, found = r, true
+			}
+		}
+	}
+
+	if !found {
+		return "", fmt.Errorf("no refresh rate %v%v available for mode %v", op, refresh, mode)
+	}
+
+	return strconv.FormatFloat(rate, 'f', 2, 64), nil
+}
+
+// closestMode returns the name of the mode of the output which is closest to
+// spec, which is of the form WxH~T: a mode whose width and height differ by at
+// most T pixels from W and H.
+func closestMode(output Output, spec string) (string, error) {
+	data := strings.SplitN(spec, "~", 2)
+	want := Mode{Name: data[0]}
+	tolerance, err := strconv.Atoi(data[1])
+	if err != nil || tolerance < 0 || want.Width() == 0 || want.Height() == 0 {
+		return "", fmt.Errorf("invalid mode %q, must be of the form WxH~T", spec)
+	}
+
+	abs := func(i int) int {
+		if i < 0 {
+			return -i
+		}
+		return i
+	}
+
+	var best string
+	var bestDist int
+	for _, m := range output.Modes {
+		dw, dh := abs(m.Width()-want.Width()), abs(m.Height()-want.Height())
+		if m.Width() == 0 || dw > tolerance || dh > tolerance {
+			continue
+		}
+
+		if best == "" || dw+dh < bestDist {
+			best, bestDist = m.Name, dw+dh
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("no mode within %d pixels of %v", tolerance, want.Name)
+	}
+
+	Logf("using mode %v for %v\n", best, spec)
+	return best, nil
+}
+
+// largestOutput returns the name of the output with the largest physical
+// area among the entries of a row. It returns false if the size of none of
+// them is known.
+func largestOutput(entries []string, current Outputs) (string, bool) {
+	var (
+		largest string
+		area    int
+	)
+	for _, entry := range entries {
+		name, _, _ := SplitEntry(entry)
+		o, ok := current.Get(name)
+		if !ok {
+			continue
+		}
+
+		if a := o.WidthMM * o.HeightMM; a > area {
+			largest, area = o.Name, a
+		}
+	}
+
+	return largest, area > 0
+}
+
+// parseWidth returns the width of a mode given only by its width, either as
+// "1920x" or as "w1920".
+func parseWidth(spec string) (int, bool) {
+	var str string
+	switch {
+	case strings.HasSuffix(spec, "x"):
+		str = strings.TrimSuffix(spec, "x")
+	case strings.HasPrefix(spec, "w"):
+		str = strings.TrimPrefix(spec, "w")
+	default:
+		return 0, false
+	}
+
+	width, err := strconv.Atoi(str)
+	if err != nil || width <= 0 {
+		return 0, false
+	}
+
+	return width, true
+}
+
+// tallestMode returns the name of the mode of the output with the given width
+// and the highest height. Of several modes with the same size, the one listed
+// first by xrandr is used.
+func tallestMode(output Output, width int) (string, error) {
+	var best Mode
+	for _, m := range output.Modes {
+		if m.Width() != width {
+			continue
+		}
+
+		if best.Name == "" || m.Height() > best.Height() {
+			best = m
+		}
+	}
+
+	if best.Name == "" {
+		return "", fmt.Errorf("no mode with a width of %d", width)
+	}
+
+	Logf("using mode %v for width %d\n", best.Name, width)
+	return best.Name, nil
+}
+
+// parseResolution parses a logical resolution of the form "WxH".
+func parseResolution(s string) (width, height int, err error) {
+	width, height = Mode{Name: s}.size()
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("resolution %q is not of the form WxH", s)
+	}
+
+	return width, height, nil
+}
+
+// scaleTo returns the xrandr scale factor which makes the mode cover the
+// logical resolution res, and the logical width and height.
+func scaleTo(mode Mode, res string) (string, int, int, error) {
+	width, height, err := parseResolution(res)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	if mode.Width() == 0 || mode.Height() == 0 {
+		return "", 0, 0, fmt.Errorf("cannot scale to %v, the size of the mode is not known", res)
+	}
+
+	sx := float64(width) / float64(mode.Width())
+	sy := float64(height) / float64(mode.Height())
+	scale := strconv.FormatFloat(sx, 'f', -1, 64) + "x" + strconv.FormatFloat(sy, 'f', -1, 64)
+
+	return scale, width, height, nil
+}
+
+// forcedMode returns the mode forced for the named output, patterns are
+// tried in lexical order.
+func forcedMode(force map[string]string, name string) (string, bool) {
+	var patterns []string
+	for pat := range force {
+		patterns = append(patterns, pat)
+	}
+	sort.Strings(patterns)
+
+	for _, pat := range patterns {
+		if m, _ := path.Match(pat, name); m {
+			return force[pat], true
+		}
+	}
+
+	return "", false
+}
+
+// resolvePrimary returns the first connected output in the order listed by
+// xrandr which matches the first possible of the candidates (names or
+// patterns) and is part of the row.
+func resolvePrimary(candidates []string, row []string, current Outputs) (string, error) {
+	inRow := make(map[string]struct{})
+	for _, entry := range row {
+		name, _, _ := SplitEntry(entry)
+		inRow[name] = struct{}{}
+	}
+
+	for _, pattern := range candidates {
+		for _, o := range current {
+			if _, ok := inRow[o.Name]; !ok || !o.Connected {
+				continue
+			}
+
+			if m, _ := o.MatchName(pattern); m {
+				Logf("using output %v as primary for %v\n", o.Name, pattern)
+				return o.Name, nil
+			}
+		}
+	}
+
+	if len(candidates) > 1 {
+		return "", fmt.Errorf("none of the primary outputs %v is connected and configured by the rule", strings.Join(candidates, ", "))
+	}
+
+	return "", fmt.Errorf("primary pattern %v matches no connected output configured by the rule", candidates[0])
+}
+
+// TargetLayout returns the configuration of the outputs enabled by the rule,
+// in the order of the row, given the currently active outputs. The modes of
+// rule.NewModes are available as if they had been added already.
+func TargetLayout(rule Rule, current Outputs, opts Options) ([]OutputTarget, error) {
+	current = withNewModes(rule, current)
+
+	outputs, err := rowOutputs(rule, current, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	Logf("enable outputs: %v\n", outputs)
+
+	primary := rule.Primary
+	for _, entry := range outputs {
+		name, _, options := SplitEntry(entry)
+		for _, opt := range options {
+			if opt != inlinePrimary {
+				continue
+			}
+
+			if primary != "" && primary != name {
+				return nil, fmt.Errorf("output %v is marked as primary, but the primary output is %v", name, primary)
+			}
+			primary = name
+		}
+	}
+
+	if strings.ContainsAny(primary, "*?[,") {
+		primary, err = resolvePrimary(rule.PrimaryCandidates(), outputs, current)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rule.SingleExternal && primary == "" {
+		primary = outputs[0]
+	}
+	autoPrimary := primary == "" && opts.AutoPrimary
+	if autoPrimary && opts.PrimaryLargest {
+		if name, ok := largestOutput(outputs, current); ok {
+			Logf("using largest output %v as primary\n", name)
+			primary = name
+			autoPrimary = false
+		}
+	}
+	var primaryFound bool
+
+	// x and y are the offset the next output in the row will have when it is
+	// placed right of the previous one
+	var x, y int
+	var xKnown = true
+
+	var targets []OutputTarget
+	for i, output := range outputs {
+		name, spec, options := SplitEntry(output)
+
+		// xrandr only knows the names without the screen
+		if o, ok := current.Get(name); ok {
+			name = o.Name
+		}
+		if strings.Contains(name, ":") {
+			return nil, fmt.Errorf("output %v cannot be configured, its name is used by several screens", name)
+		}
+
+		t := OutputTarget{
+			Name:     name,
+			Rotation: "normal",
+		}
+		var refresh string
+		if spec != "" {
+			mode := strings.SplitN(spec, "@", 2)
+			t.Mode = mode[0]
+			if len(mode) > 1 {
+				refresh = strings.TrimSpace(mode[1])
+			}
+		}
+
+		for _, opt := range options {
+			switch {
+			case opt == inlinePrimary:
+			case validRotation(opt):
+				t.Rotation = opt
+				t.Rotated = true
+			default:
+				return nil, fmt.Errorf("invalid option %q for output %v", opt, t.Name)
+			}
+		}
+
+		if t.Mode != "" {
+			cur, _ := current.Get(t.Name)
+			t.Mode = cur.canonicalMode(t.Mode)
+		}
+
+		if strings.Contains(t.Mode, "~") {
+			cur, _ := current.Get(t.Name)
+			t.Mode, err = closestMode(cur, t.Mode)
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", t.Name, err)
+			}
+		}
+
+		if width, ok := parseWidth(t.Mode); ok {
+			cur, _ := current.Get(t.Name)
+			t.Mode, err = tallestMode(cur, width)
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", t.Name, err)
+			}
+		}
+
+		if mode, ok := forcedMode(opts.ForceModes, t.Name); ok {
+			cur, _ := current.Get(t.Name)
+			if _, found := cur.findMode(mode); !found {
+				return nil, fmt.Errorf("forced mode %v is not supported by output %v", mode, t.Name)
+			}
+
+			Logf("using forced mode %v for output %v\n", mode, t.Name)
+			t.Mode = mode
+		}
+
+		if autoPrimary && i == 0 {
+			Logf("using output %v as primary\n", t.Name)
+			primary = t.Name
+		}
+
+		if t.Name == primary {
+			t.Primary = true
+			primaryFound = true
+		}
+
+		if r, ok := rule.Rotate[t.Name]; ok {
+			if !validRotation(r) {
+				return nil, fmt.Errorf("invalid rotation %q for output %v", r, t.Name)
+			}
+			t.Rotation = r
+			t.Rotated = true
+		}
+
+		if g, ok := rule.Gamma[t.Name]; ok {
+			t.Gamma, err = ResolveGamma(g, opts.GammaPresets)
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", t.Name, err)
+			}
+		}
+
+		if tr, ok := rule.Transform[t.Name]; ok {
+			if err = validTransform(tr); err != nil {
+				return nil, fmt.Errorf("output %v: %v", t.Name, err)
+			}
+			t.Transform = tr
+		}
+
+		if pos, ok := rule.Positions[t.Name]; ok {
+			t.Offset, err = parsePosition(pos)
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", t.Name, err)
+			}
+			t.Absolute = true
+			t.OffsetKnown = true
+		} else {
+			t.Offset = Offset{X: x, Y: y}
+			t.OffsetKnown = xKnown
+		}
+
+		cur, _ := current.Get(t.Name)
+		mode, ok := cur.findMode(t.Mode)
+		if ok {
+			t.ModeName = mode.Name
+		}
+
+		if refresh != "" {
+			t.Rate, err = selectRate(cur, t.Mode, refresh)
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", t.Name, err)
+			}
+		}
+
+		width, height := mode.Width(), mode.Height()
+		if res, found := rule.ScaleTo[t.Name]; found {
+			if !ok {
+				return nil, fmt.Errorf("output %v: cannot scale to %v, the mode is not known", t.Name, res)
+			}
+
+			t.Scale, width, height, err = scaleTo(mode, res)
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", t.Name, err)
+			}
+		}
+
+		if t.Rotation == "left" || t.Rotation == "right" {
+			width = height
+		}
+
+		if ok && width > 0 && t.OffsetKnown {
+			x, y = t.Offset.X+width, t.Offset.Y
+			xKnown = true
+		} else {
+			xKnown = false
+		}
+
+		targets = append(targets, t)
+	}
+
+	if primary != "" && !primaryFound {
+		return nil, fmt.Errorf("primary output %v is not configured by the rule", primary)
+	}
+
+	if rule.NormalizeOrigin {
+		normalizeOrigin(targets)
+	}
+
+	return targets, nil
+}
+
+// normalizeOrigin shifts the targets whose offset is known so that the
+// leftmost and the topmost of them are at 0,0. Shifted targets are positioned
+// absolutely, a layout which already starts at the origin is left alone.
+func normalizeOrigin(targets []OutputTarget) {
+	var min Offset
+	var found bool
+	for _, t := range targets {
+		if !t.OffsetKnown {
+			continue
+		}
+
+		if !found || t.Offset.X < min.X {
+			min.X = t.Offset.X
+		}
+		if !found || t.Offset.Y < min.Y {
+			min.Y = t.Offset.Y
+		}
+		found = true
+	}
+
+	if !found || (min.X == 0 && min.Y == 0) {
+		return
+	}
+
+	Logf("shifting the layout by %d,%d to the origin\n", -min.X, -min.Y)
+	for i := range targets {
+		if !targets[i].OffsetKnown {
+			continue
+		}
+
+		targets[i].Offset.X -= min.X
+		targets[i].Offset.Y -= min.Y
+		targets[i].Absolute = true
+	}
+}
+
+// Layout returns the active outputs in the compact notation name@mode+x+y,
+// separated by spaces.
+func (os Outputs) Layout() string {
+	var entries []string
+	for _, o := range os {
+		mode, ok := o.ActiveMode()
+		if !ok {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("%v@%v+%d+%d", o.Name, mode.Name, o.Offset.X, o.Offset.Y))
+	}
+
+	if len(entries) == 0 {
+		return "none"
+	}
+
+	return strings.Join(entries, " ")
+}
+
+// FormatLayout returns the targets in the notation used by Outputs.Layout.
+// The mode is "auto" if it is not known, the offset is left out if it cannot
+// be computed.
+func FormatLayout(targets []OutputTarget) string {
+	var entries []string
+	for _, t := range targets {
+		mode := t.ModeName
+		if mode == "" {
+			mode = t.Mode
+		}
+		if mode == "" {
+			mode = "auto"
+		}
+
+		entry := t.Name + "@" + mode
+		if t.OffsetKnown {
+			entry += fmt.Sprintf("+%d+%d", t.Offset.X, t.Offset.Y)
+		}
+		entries = append(entries, entry)
+	}
+
+	return strings.Join(entries, " ")
+}
+
+// VerifyLayout compares the outputs after rule has been applied to the
+// targets and returns a description of every difference found. Active outputs
+// which are not targets are only reported if the rule would have disabled
+// them, i.e. they are managed by the rule, not pinned and can be addressed by
+// xrandr.
+func VerifyLayout(rule Rule, targets []OutputTarget, outputs Outputs, opts Options) []string {
+	var diffs []string
+
+	enabled := make(map[string]struct{})
+	for _, t := range targets {
+		enabled[t.Name] = struct{}{}
+
+		cur, ok := outputs.Get(t.Name)
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("output %v not found", t.Name))
+			continue
+		}
+
+		mode, ok := cur.ActiveMode()
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("output %v is not active", t.Name))
+			continue
+		}
+
+		if t.ModeName != "" && mode.Name != t.ModeName {
+			diffs = append(diffs, fmt.Sprintf("output %v uses mode %v instead of %v", t.Name, mode.Name, t.ModeName))
+		}
+
+		if t.OffsetKnown && cur.Offset != t.Offset {
+			diffs = append(diffs, fmt.Sprintf("output %v is at %v instead of %v", t.Name, cur.Offset, t.Offset))
+		}
+
+		if cur.Rotation != t.Rotation {
+			diffs = append(diffs, fmt.Sprintf("output %v is rotated %v instead of %v", t.Name, cur.Rotation, t.Rotation))
+		}
+	}
+
+	for _, o := range outputs {
+		if _, ok := enabled[o.Name]; ok || !o.Active() {
+			continue
+		}
+
+		if _, ok := opts.PinOutputs[o.Name]; ok || !rule.Managed(o) || strings.Contains(o.Name, ":") {
+			continue
+		}
+
+		diffs = append(diffs, fmt.Sprintf("output %v is still active", o.Name))
+	}
+
+	return diffs
+}
@@ -0,0 +1,103 @@
+package randr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseModeline parses a modeline as printed by cvt or gtf, e.g.
+// `"2560x1080_75.00" 294.00 2560 2744 3016 3472 1080 1083 1093 1130 -hsync
+// +vsync`. The keyword "Modeline" in front of it is optional. It returns the
+// name of the mode and the arguments for xrandr --newmode.
+func parseModeline(line string) (name string, args []string, err error) {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "modeline") {
+		fields = fields[1:]
+	}
+
+	// name, clock, four horizontal and four vertical timings
+	if len(fields) < 10 {
+		return "", nil, fmt.Errorf("modeline %q is too short", line)
+	}
+
+	if _, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return "", nil, fmt.Errorf("modeline %q: invalid clock %q", line, fields[1])
+	}
+
+	for _, f := range fields[2:10] {
+		if _, err = strconv.Atoi(f); err != nil {
+			return "", nil, fmt.Errorf("modeline %q: invalid timing %q", line, f)
+		}
+	}
+
+	name = strings.Trim(fields[0], `"`)
+	if name == "" {
+		return "", nil, fmt.Errorf("modeline %q has no name", line)
+	}
+
+	return name, append([]string{name}, fields[1:]...), nil
+}
+
+// newModeArgs returns the arguments for the calls to xrandr which create the
+// modes of rule.NewModes and add them to the outputs. Modes an output already
+// lists are skipped, so that applying the rule again does not fail.
+func newModeArgs(rule Rule, current Outputs) ([][]string, error) {
+	var names []string
+	for name := range rule.NewModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res [][]string
+	created := make(map[string]bool)
+	for _, output := range names {
+		o, _ := current.Get(output)
+		for _, line := range rule.NewModes[output] {
+			mode, args, err := parseModeline(line)
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", output, err)
+			}
+
+			if _, ok := o.findMode(mode); ok {
+				Logf("output %v already has mode %v\n", output, mode)
+				continue
+			}
+
+			if !created[mode] {
+				res = append(res, append([]string{"--newmode"}, args...))
+				created[mode] = true
+			}
+			res = append(res, []string{"--addmode", output, mode})
+		}
+	}
+
+	return res, nil
+}
+
+// withNewModes returns a copy of current in which the outputs also list the
+// modes of rule.NewModes, as they will after the modes have been added.
+func withNewModes(rule Rule, current Outputs) Outputs {
+	if len(rule.NewModes) == 0 {
+		return current
+	}
+
+	res := make(Outputs, len(current))
+	copy(res, current)
+	for i, o := range res {
+		for _, line := range rule.NewModes[o.Name] {
+			mode, _, err := parseModeline(line)
+			if err != nil {
+				continue
+			}
+
+			if _, ok := o.findMode(mode); !ok {
+				o.Modes = append(append([]Mode(nil), o.Modes...), Mode{Name: mode})
+			}
+		}
+		res[i] = o
+	}
+
+	return res
+}
@@ -0,0 +1,99 @@
+package randr
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testModeline = `"2560x1080_75.00"  294.00  2560 2744 3016 3472  1080 1083 1093 1130 -hsync +vsync`
+
+func TestParseModeline(t *testing.T) {
+	var tests = []struct {
+		line string
+		name string
+		args []string
+	}{
+		{testModeline, "2560x1080_75.00", []string{"2560x1080_75.00", "294.00",
+			"2560", "2744", "3016", "3472", "1080", "1083", "1093", "1130", "-hsync", "+vsync"}},
+		{"Modeline " + testModeline, "2560x1080_75.00", []string{"2560x1080_75.00", "294.00",
+			"2560", "2744", "3016", "3472", "1080", "1083", "1093", "1130", "-hsync", "+vsync"}},
+		{"custom 148.5 1920 2008 2052 2200 1080 1084 1089 1125", "custom", []string{"custom", "148.5",
+			"1920", "2008", "2052", "2200", "1080", "1084", "1089", "1125"}},
+		{"custom 148.5 1920 2008 2052 2200 1080 1084 1089", "", nil},
+		{"custom fast 1920 2008 2052 2200 1080 1084 1089 1125", "", nil},
+		{"custom 148.5 1920 2008 2052 2200 1080 1084 1089 1125.5", "", nil},
+		{`"" 148.5 1920 2008 2052 2200 1080 1084 1089 1125`, "", nil},
+		{"", "", nil},
+	}
+
+	for _, test := range tests {
+		name, args, err := parseModeline(test.line)
+		if test.name == "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got mode %v", test.line, name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: returned error: %v", test.line, err)
+			continue
+		}
+
+		if name != test.name || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%q: wrong result: want %v %v, got %v %v", test.line, test.name, test.args, name, args)
+		}
+	}
+
+	if err := (Rule{NewModes: map[string][]string{"DP1": {"2560x1080"}}}).Valid(); err == nil {
+		t.Errorf("invalid modeline did not return an error")
+	}
+}
+
+func TestBuildCommandOutputRowNewModes(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+		{Name: "DP2", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"LVDS1", "DP1@2560x1080_75.00", "DP2@2560x1080_75.00"},
+		NewModes: map[string][]string{
+			"DP2": {testModeline},
+			"DP1": {testModeline},
+		},
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--newmode", "2560x1080_75.00", "294.00", "2560", "2744", "3016", "3472",
+			"1080", "1083", "1093", "1130", "-hsync", "+vsync"},
+		{"xrandr", "--addmode", "DP1", "2560x1080_75.00"},
+		{"xrandr", "--addmode", "DP2", "2560x1080_75.00"},
+		{"xrandr", "--output", "LVDS1", "--auto"},
+		{"xrandr", "--output", "DP1", "--mode", "2560x1080_75.00", "--right-of", "LVDS1"},
+		{"xrandr", "--output", "DP2", "--mode", "2560x1080_75.00", "--right-of", "DP1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+
+	// modes the output already lists are not created again
+	current[1].Modes = append(current[1].Modes, Mode{Name: "2560x1080_75.00"})
+	current[2].Modes = append(current[2].Modes, Mode{Name: "2560x1080_75.00"})
+
+	cmds, err = BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want[3:]) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want[3:], got)
+	}
+}
@@ -0,0 +1,66 @@
+package randr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	powerAC      = "ac"
+	powerBattery = "battery"
+)
+
+// PowerSource reports whether the system is running on AC power.
+type PowerSource interface {
+	OnAC() (bool, error)
+}
+
+// sysfsPowerSource reads the power state from the "online" files of the
+// power supplies in dir, usually /sys/class/power_supply.
+type sysfsPowerSource struct {
+	dir string
+}
+
+// OnAC returns true if any power supply is online. Systems without power
+// supplies which report their state (e.g. desktops) are considered to run on
+// AC power.
+func (p sysfsPowerSource) OnAC() (bool, error) {
+	files, err := filepath.Glob(filepath.Join(p.dir, "*", "online"))
+	if err != nil {
+		return true, err
+	}
+
+	if len(files) == 0 {
+		return true, nil
+	}
+
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return true, err
+		}
+
+		if strings.TrimSpace(string(buf)) == "1" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// powerSource is used to match the power state of rules, it is replaced in
+// tests.
+var powerSource PowerSource = sysfsPowerSource{dir: "/sys/class/power_supply"}
+
+// onAC returns true if the system is running on AC power, or if the power
+// state cannot be determined.
+func onAC() bool {
+	ac, err := powerSource.OnAC()
+	if err != nil {
+		Logf("unable to read power state, assuming AC: %v\n", err)
+		return true
+	}
+
+	return ac
+}
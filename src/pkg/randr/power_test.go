@@ -0,0 +1,80 @@
+package randr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakePowerSource bool
+
+func (f fakePowerSource) OnAC() (bool, error) {
+	return bool(f), nil
+}
+
+func TestRuleMatchPower(t *testing.T) {
+	defer func(old PowerSource) { powerSource = old }(powerSource)
+
+	var tests = []struct {
+		power string
+		ac    bool
+		match bool
+	}{
+		{"", true, true},
+		{"", false, true},
+		{"ac", true, true},
+		{"ac", false, false},
+		{"battery", true, false},
+		{"battery", false, true},
+	}
+
+	for i, test := range tests {
+		powerSource = fakePowerSource(test.ac)
+		rule := Rule{Power: test.power, OutputsConnected: []string{"LVDS"}}
+		if m := rule.Match(testOutputs); m != test.match {
+			t.Errorf("test %d: wrong match: want %v, got %v", i, test.match, m)
+		}
+	}
+
+	if err := (Rule{Power: "mains"}).Valid(); err == nil {
+		t.Errorf("invalid power state did not return an error")
+	}
+}
+
+func TestSysfsPowerSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grobi-power-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := sysfsPowerSource{dir: dir}
+
+	// no power supplies at all
+	ac, err := p.OnAC()
+	if err != nil || !ac {
+		t.Errorf("empty dir: want AC, got %v (err %v)", ac, err)
+	}
+
+	write := func(name, online string) {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name, "online"), []byte(online+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("AC", "0")
+	ac, err = p.OnAC()
+	if err != nil || ac {
+		t.Errorf("AC offline: want battery, got AC %v (err %v)", ac, err)
+	}
+
+	write("AC", "1")
+	ac, err = p.OnAC()
+	if err != nil || !ac {
+		t.Errorf("AC online: want AC, got %v (err %v)", ac, err)
+	}
+}
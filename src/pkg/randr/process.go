@@ -0,0 +1,69 @@
+package randr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ProcessLister lists the names of the running processes.
+type ProcessLister interface {
+	Processes() ([]string, error)
+}
+
+// procProcessLister reads the names of the running processes from the "comm"
+// files of the processes in dir, usually /proc.
+type procProcessLister struct {
+	dir string
+}
+
+// Processes returns the names of all processes which are running.
+func (p procProcessLister) Processes() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(p.dir, "[0-9]*", "comm"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			// the process has exited in the meantime
+			continue
+		}
+
+		names = append(names, strings.TrimSpace(string(buf)))
+	}
+
+	return names, nil
+}
+
+// processLister is used to match the running processes of rules, it is
+// replaced in tests.
+var processLister ProcessLister = procProcessLister{dir: "/proc"}
+
+// commLength is the maximal length of a process name in /proc/PID/comm, longer
+// names are truncated by the kernel.
+const commLength = 15
+
+// processRunning returns true if a process called name is running, or if the
+// processes cannot be listed.
+func processRunning(name string) bool {
+	names, err := processLister.Processes()
+	if err != nil {
+		Logf("unable to list processes, assuming %v is running: %v\n", name, err)
+		return true
+	}
+
+	if len(name) > commLength {
+		name = name[:commLength]
+	}
+
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
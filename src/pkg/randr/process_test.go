@@ -0,0 +1,75 @@
+package randr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type fakeProcessLister []string
+
+func (f fakeProcessLister) Processes() ([]string, error) {
+	if f == nil {
+		return nil, errors.New("no processes")
+	}
+	return f, nil
+}
+
+func TestRuleMatchUnlessProcess(t *testing.T) {
+	defer func(old ProcessLister) { processLister = old }(processLister)
+
+	var tests = []struct {
+		process   string
+		processes fakeProcessLister
+		match     bool
+	}{
+		{"", fakeProcessLister{"steam"}, true},
+		{"steam", fakeProcessLister{"bash", "i3"}, true},
+		{"steam", fakeProcessLister{"bash", "steam", "i3"}, false},
+		{"steamwebhelper-long", fakeProcessLister{"steamwebhelper-"}, false},
+		{"steam", fakeProcessLister{"steamwebhelper"}, true},
+		// assume the process is running if the processes cannot be listed
+		{"steam", nil, false},
+	}
+
+	for i, test := range tests {
+		processLister = test.processes
+		rule := Rule{UnlessProcess: test.process, OutputsConnected: []string{"LVDS"}}
+		if m := rule.Match(testOutputs); m != test.match {
+			t.Errorf("test %d: wrong match: want %v, got %v", i, test.match, m)
+		}
+	}
+}
+
+func TestProcProcessLister(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "grobi-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	for pid, name := range map[string]string{"1": "init", "42": "steam", "self": "grobi"} {
+		dir := filepath.Join(tempdir, pid)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "comm"), []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := procProcessLister{dir: tempdir}.Processes()
+	if err != nil {
+		t.Fatalf("Processes returned error: %v", err)
+	}
+
+	sort.Strings(names)
+	want := []string{"init", "steam"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong processes: want %v, got %v", want, names)
+	}
+}
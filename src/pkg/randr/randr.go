@@ -0,0 +1,1420 @@
+// Package randr parses the output of xrandr and builds the xrandr commands to
+// configure the outputs as described by a rule.
+package randr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Output encapsulates a physical output with detected modes.
+type Output struct {
+	Name      string `json:"name"`
+	Modes     Modes  `json:"modes"`
+	Connected bool   `json:"connected"`
+
+	// Offset is the position of the output on the screen and Rotation its
+	// rotation (normal, left, right or inverted), they are only meaningful
+	// when the output is active.
+	Offset   Offset `json:"offset"`
+	Rotation string `json:"rotation,omitempty"`
+
+	Primary bool `json:"primary"`
+
+	// Screen is the number of the X screen the output belongs to, as listed
+	// by xrandr. If outputs of two screens have the same name, their names
+	// are qualified with the screen, e.g. "screen1:DP-1".
+	Screen int `json:"screen"`
+
+	// Vendor is the manufacturer ID (e.g. "DEL") from the EDID of the
+	// connected monitor, it is empty if xrandr did not report an EDID.
+	Vendor string `json:"vendor,omitempty"`
+
+	// Serial is the serial number from the EDID of the connected monitor,
+	// it is empty if it is not known.
+	Serial string `json:"serial,omitempty"`
+
+	// WidthMM and HeightMM are the physical size of the connected monitor
+	// in millimeters, they are zero if it is not known.
+	WidthMM  int `json:"width_mm,omitempty"`
+	HeightMM int `json:"height_mm,omitempty"`
+}
+
+// Offset is the position of the top left corner of an output on the screen.
+type Offset struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (o Output) String() string {
+	var con string
+	if o.Connected {
+		con = " (connected)"
+	}
+	str := fmt.Sprintf("%s%s", o.Name, con)
+	if len(o.Modes) > 0 {
+		str += fmt.Sprintf(" %v", o.Modes)
+	}
+	return str
+}
+
+// screenPrefix is the prefix of output names qualified with the screen.
+const screenPrefix = "screen"
+
+// qualifiedName returns the name of the output qualified with its screen.
+func (o Output) qualifiedName() string {
+	if strings.Contains(o.Name, ":") {
+		return o.Name
+	}
+
+	return fmt.Sprintf("%s%d:%s", screenPrefix, o.Screen, o.Name)
+}
+
+// MatchName returns true iff pattern matches the name of the output. A
+// pattern containing a screen like "screen0:DP-1" also matches the output if
+// its name is not qualified because it is unambiguous.
+func (o Output) MatchName(pattern string) (bool, error) {
+	m, err := path.Match(pattern, o.Name)
+	if err != nil || m || !strings.Contains(pattern, ":") {
+		return m, err
+	}
+
+	return path.Match(pattern, o.qualifiedName())
+}
+
+// WithMode returns a copy of the output with the mode appended to its modes.
+func (o Output) WithMode(name string, active, def bool) Output {
+	modes := make(Modes, len(o.Modes), len(o.Modes)+1)
+	copy(modes, o.Modes)
+	o.Modes = append(modes, Mode{Name: name, Active: active, Default: def})
+	return o
+}
+
+// Equals checks whether the two Outputs are equal.
+func (o Output) Equals(other Output) bool {
+	if o.Name != other.Name || o.Connected != other.Connected || o.Vendor != other.Vendor || o.Serial != other.Serial {
+		return false
+	}
+
+	if len(o.Modes) != len(other.Modes) {
+		return false
+	}
+
+	for i := range o.Modes {
+		m1 := o.Modes[i]
+		m2 := other.Modes[i]
+
+		if !m1.Equals(m2) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ActiveMode returns the currently active mode of the output.
+func (o Output) ActiveMode() (Mode, bool) {
+	for _, mode := range o.Modes {
+		if mode.Active {
+			return mode, true
+		}
+	}
+
+	return Mode{}, false
+}
+
+// Active returns true iff the output has an active mode.
+func (o Output) Active() bool {
+	_, ok := o.ActiveMode()
+	return ok
+}
+
+// findMode returns the mode named name, an empty name selects the default
+// mode which xrandr uses for --auto.
+func (o Output) findMode(name string) (Mode, bool) {
+	for _, mode := range o.Modes {
+		if (name == "" && mode.Default) || (name != "" && mode.Name == name) {
+			return mode, true
+		}
+	}
+
+	return Mode{}, false
+}
+
+// normalizeMode returns the mode name without whitespace and in lower case,
+// so that e.g. " 1920X1080" and "1920x1080" are the same mode.
+func normalizeMode(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+// canonicalMode returns the name of the mode as listed by xrandr for the mode
+// name, which may contain whitespace or differ in case. Names the output does
+// not list are returned normalized if they are not used as is.
+func (o Output) canonicalMode(name string) string {
+	for _, mode := range o.Modes {
+		if mode.Name == name {
+			return name
+		}
+	}
+
+	norm := normalizeMode(name)
+	for _, mode := range o.Modes {
+		if normalizeMode(mode.Name) == norm {
+			return mode.Name
+		}
+	}
+
+	return norm
+}
+
+// Outputs is a list of outputs.
+type Outputs []Output
+
+// NewOutputs returns a list of outputs without modes, the connected outputs
+// are followed by the disconnected ones.
+func NewOutputs(connected, disconnected []string) Outputs {
+	var outputs Outputs
+	for _, name := range connected {
+		outputs = append(outputs, Output{Name: name, Connected: true})
+	}
+	for _, name := range disconnected {
+		outputs = append(outputs, Output{Name: name})
+	}
+	return outputs
+}
+
+// Get returns the output with exactly the given name, which may be qualified
+// with the screen.
+func (os Outputs) Get(name string) (Output, bool) {
+	for _, o := range os {
+		if o.Name == name || o.qualifiedName() == name {
+			return o, true
+		}
+	}
+
+	return Output{}, false
+}
+
+// Present returns true iff the list of outputs contains the named output.
+func (os Outputs) Present(name string) bool {
+	for _, o := range os {
+		m, err := o.MatchName(name)
+		if err != nil {
+			return false
+		}
+
+		if m {
+			return true
+		}
+	}
+	return false
+}
+
+// Mirrored returns the names of the active outputs which share their position
+// with another active output and thus show the same picture, grouped by
+// position. It returns nil if no outputs are mirrored.
+func (os Outputs) Mirrored() [][]string {
+	var offsets []Offset
+	byOffset := make(map[Offset][]string)
+	for _, o := range os {
+		if !o.Active() {
+			continue
+		}
+
+		if _, ok := byOffset[o.Offset]; !ok {
+			offsets = append(offsets, o.Offset)
+		}
+		byOffset[o.Offset] = append(byOffset[o.Offset], o.Name)
+	}
+
+	var groups [][]string
+	for _, offset := range offsets {
+		if len(byOffset[offset]) > 1 {
+			groups = append(groups, byOffset[offset])
+		}
+	}
+
+	return groups
+}
+
+// rightToLeft sorts outputs by their position from right to left.
+type rightToLeft Outputs
+
+func (os rightToLeft) Len() int           { return len(os) }
+func (os rightToLeft) Swap(i, j int)      { os[i], os[j] = os[j], os[i] }
+func (os rightToLeft) Less(i, j int) bool { return os[i].Offset.X > os[j].Offset.X }
+
+// Connected returns true iff the list of outputs contains the named output and
+// it is connected.
+func (os Outputs) Connected(name string) bool {
+	for _, o := range os {
+		m, err := o.MatchName(name)
+		if err != nil {
+			return false
+		}
+
+		if m && o.Connected {
+			return true
+		}
+	}
+	return false
+}
+
+// Disconnected returns true iff the list of outputs contains the named output
+// and it is not connected.
+func (os Outputs) Disconnected(name string) bool {
+	for _, o := range os {
+		m, err := o.MatchName(name)
+		if err != nil {
+			return false
+		}
+
+		if m && !o.Connected {
+			return true
+		}
+	}
+	return false
+}
+
+// internalOutputPrefixes are the name prefixes of outputs which are usually
+// the internal panel of a laptop.
+var internalOutputPrefixes = []string{"eDP", "LVDS", "DSI"}
+
+// Connected monitors with a diagonal between minInternalDiagonal and
+// maxInternalDiagonal millimeters (4 and 18 inches) are considered to be
+// internal panels if no output is named like one. Smaller sizes are often
+// only the aspect ratio reported by TVs.
+const (
+	minInternalDiagonal = 100
+	maxInternalDiagonal = 457
+)
+
+// diagonal returns the physical diagonal of the monitor in millimeters, or
+// zero if the size is not known.
+func (o Output) diagonal() float64 {
+	return math.Hypot(float64(o.WidthMM), float64(o.HeightMM))
+}
+
+// InternalOutput returns the output which is most likely the internal panel
+// of a laptop or tablet, detected by the name of the output (the prefixes are
+// tried in order). If no output is named like an internal one, the connected
+// output with the smallest physical size is used if it is as small as a
+// laptop panel.
+func (os Outputs) InternalOutput() (Output, bool) {
+	for _, prefix := range internalOutputPrefixes {
+		for _, o := range os {
+			if strings.HasPrefix(o.Name, prefix) {
+				return o, true
+			}
+		}
+	}
+
+	var internal Output
+	var found bool
+	for _, o := range os {
+		d := o.diagonal()
+		if !o.Connected || d < minInternalDiagonal || d > maxInternalDiagonal {
+			continue
+		}
+
+		if !found || d < internal.diagonal() {
+			internal, found = o, true
+		}
+	}
+
+	return internal, found
+}
+
+// internalOutputName returns the name of the internal output. If override is
+// not empty, it is used instead of the detected output.
+func (os Outputs) internalOutputName(override string) (string, bool) {
+	if override != "" {
+		return override, true
+	}
+
+	o, ok := os.InternalOutput()
+	return o.Name, ok
+}
+
+// SupportsMode returns true iff the list of outputs contains the named output,
+// it is connected and lists the mode.
+func (os Outputs) SupportsMode(name, mode string) bool {
+	for _, o := range os {
+		m, err := o.MatchName(name)
+		if err != nil {
+			return false
+		}
+
+		if !m || !o.Connected {
+			continue
+		}
+
+		for _, om := range o.Modes {
+			if normalizeMode(om.Name) == normalizeMode(mode) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasModes returns true iff the list of outputs contains the named output, it
+// is connected and lists at least n modes.
+func (os Outputs) HasModes(name string, n int) bool {
+	for _, o := range os {
+		m, err := o.MatchName(name)
+		if err != nil {
+			return false
+		}
+
+		if m && o.Connected && len(o.Modes) >= n {
+			return true
+		}
+	}
+	return false
+}
+
+// HasVendor returns true iff the list of outputs contains the named output, it
+// is connected and the manufacturer ID from its EDID is vendor.
+func (os Outputs) HasVendor(name, vendor string) bool {
+	for _, o := range os {
+		m, err := o.MatchName(name)
+		if err != nil {
+			return false
+		}
+
+		if m && o.Connected && o.Vendor != "" && strings.EqualFold(o.Vendor, vendor) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConnectedSerial returns true iff a connected output reports a monitor whose
+// serial number from the EDID matches the pattern.
+func (os Outputs) ConnectedSerial(pattern string) bool {
+	for _, o := range os {
+		if !o.Connected || o.Serial == "" {
+			continue
+		}
+
+		if m, err := path.Match(pattern, o.Serial); err == nil && m {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyConnected returns true iff at least one output is connected.
+func (os Outputs) AnyConnected() bool {
+	for _, o := range os {
+		if o.Connected {
+			return true
+		}
+	}
+	return false
+}
+
+// Equals checks whether the two Outputs are equal.
+func (os Outputs) Equals(other Outputs) bool {
+	if len(os) != len(other) {
+		return false
+	}
+
+	for i := range os {
+		out1 := os[i]
+		out2 := other[i]
+
+		if !out1.Equals(out2) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ConnectionEquals returns true iff both lists contain the same outputs with
+// the same connection state, monitor vendor and serial and active mode. Other changes,
+// e.g. of the list of modes, the position or the primary output, are ignored.
+func (os Outputs) ConnectionEquals(other Outputs) bool {
+	if len(os) != len(other) {
+		return false
+	}
+
+	activeMode := func(o Output) string {
+		mode, _ := o.ActiveMode()
+		return mode.Name
+	}
+
+	for _, o := range os {
+		p, ok := other.Get(o.Name)
+		if !ok || p.Connected != o.Connected || p.Vendor != o.Vendor || p.Serial != o.Serial || activeMode(p) != activeMode(o) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Mode is an output mode that may be active or default.
+type Mode struct {
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+	Active  bool   `json:"active"`
+
+	// Refresh lists the refresh rates available for the mode, in the order
+	// reported by xrandr.
+	Refresh []float64 `json:"refresh"`
+}
+
+// Equals checks whether the two modes are equal.
+func (m Mode) Equals(other Mode) bool {
+	if m.Name != other.Name || m.Default != other.Default || m.Active != other.Active {
+		return false
+	}
+
+	if len(m.Refresh) != len(other.Refresh) {
+		return false
+	}
+
+	for i := range m.Refresh {
+		if m.Refresh[i] != other.Refresh[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MaxRefresh returns the highest refresh rate of the mode, or zero if none
+// is known.
+func (m Mode) MaxRefresh() float64 {
+	var max float64
+	for _, r := range m.Refresh {
+		if r > max {
+			max = r
+		}
+	}
+	return max
+}
+
+func (m Mode) String() string {
+	var suffix string
+
+	if m.Active {
+		suffix += "*"
+	}
+
+	if m.Default {
+		suffix += "+"
+	}
+
+	return m.Name + suffix
+}
+
+// Width returns the horizontal resolution of the mode, or zero if the mode
+// name is not of the form WxH.
+func (m Mode) Width() int {
+	w, _ := m.size()
+	return w
+}
+
+// Height returns the vertical resolution of the mode, or zero if the mode name
+// is not of the form WxH.
+func (m Mode) Height() int {
+	_, h := m.size()
+	return h
+}
+
+// size parses the width and height from the mode name. Trailing characters
+// after the height (e.g. "i" for interlaced modes) are ignored.
+func (m Mode) size() (width, height int) {
+	data := strings.SplitN(m.Name, "x", 2)
+	if len(data) != 2 {
+		return 0, 0
+	}
+
+	w, err := strconv.Atoi(data[0])
+	if err != nil {
+		return 0, 0
+	}
+
+	hs := strings.TrimRightFunc(data[1], func(r rune) bool { return r < '0' || r > '9' })
+	h, err := strconv.Atoi(hs)
+	if err != nil {
+		return 0, 0
+	}
+
+	return w, h
+}
+
+// Modes is a list of Mode.
+type Modes []Mode
+
+func (m Modes) String() string {
+	var str []string
+	for _, mode := range m {
+		str = append(str, mode.String())
+	}
+	return strings.Join(str, " ")
+}
+
+// errNotModeLine is returned by parseModeLine when the line doesn't match
+// the format for a mode line.
+var errNotModeLine = errors.New("not a mode line")
+
+// parseOutputLine returns the output parsed from the string.
+func parseOutputLine(line string) (Output, error) {
+	output := Output{}
+	output.WidthMM, output.HeightMM = parsePhysicalSize(line)
+
+	ws := bufio.NewScanner(bytes.NewReader([]byte(line)))
+	ws.Split(bufio.ScanWords)
+
+	if !ws.Scan() {
+		return Output{}, fmt.Errorf("line too short, name not found: %s", line)
+	}
+	output.Name = ws.Text()
+
+	if !ws.Scan() {
+		return Output{}, fmt.Errorf("line too short, state not found: %s", line)
+	}
+
+	switch ws.Text() {
+	case "connected":
+		output.Connected = true
+	case "disconnected":
+		output.Connected = false
+	default:
+		return Output{}, fmt.Errorf("unknown state %q", ws.Text())
+	}
+
+	if !ws.Scan() {
+		return output, nil
+	}
+
+	if ws.Text() == "primary" {
+		output.Primary = true
+		if !ws.Scan() {
+			return output, nil
+		}
+	}
+
+	mode, offset, ok := parseGeometry(ws.Text())
+	if !ok {
+		return output, nil
+	}
+
+	output.Offset = offset
+
+	// the rotation is only listed if it is not normal
+	output.Rotation = "normal"
+	if ws.Scan() && validRotation(ws.Text()) {
+		output.Rotation = ws.Text()
+	}
+
+	// handle special case: output is disconnected, but still active
+	if !output.Connected {
+		output.Modes = append(output.Modes, Mode{Name: mode, Active: true})
+	}
+
+	return output, nil
+}
+
+// parsePhysicalSize returns the physical size from an output line, which xrandr
+// prints at the end like "309mm x 174mm". It returns zeroes if the line does
+// not contain the size.
+func parsePhysicalSize(line string) (width, height int) {
+	fields := strings.Fields(line)
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i+1] != "x" || !strings.HasSuffix(fields[i], "mm") || !strings.HasSuffix(fields[i+2], "mm") {
+			continue
+		}
+
+		w, err1 := strconv.Atoi(strings.TrimSuffix(fields[i], "mm"))
+		h, err2 := strconv.Atoi(strings.TrimSuffix(fields[i+2], "mm"))
+		if err1 == nil && err2 == nil {
+			return w, h
+		}
+	}
+
+	return 0, 0
+}
+
+// rotations lists the valid rotations of an output.
+var rotations = []string{"normal", "left", "right", "inverted"}
+
+// validRotation returns true iff s is a valid rotation.
+func validRotation(s string) bool {
+	for _, r := range rotations {
+		if s == r {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePosition parses a position as accepted by `xrandr --pos`, e.g. "1920x0".
+func parsePosition(s string) (Offset, error) {
+	var o Offset
+	data := strings.Split(s, "x")
+	if len(data) != 2 {
+		return Offset{}, fmt.Errorf("position %q is not of the form XxY", s)
+	}
+
+	var err error
+	if o.X, err = strconv.Atoi(data[0]); err != nil {
+		return Offset{}, fmt.Errorf("position %q is not of the form XxY", s)
+	}
+
+	if o.Y, err = strconv.Atoi(data[1]); err != nil {
+		return Offset{}, fmt.Errorf("position %q is not of the form XxY", s)
+	}
+
+	return o, nil
+}
+
+// String returns the offset in the format accepted by `xrandr --pos`.
+func (o Offset) String() string {
+	return fmt.Sprintf("%dx%d", o.X, o.Y)
+}
+
+// parseGeometry parses a string like "1600x1200+1680+0" into the mode name and
+// the offset. Some versions of xrandr print only the mode (e.g. "1600x1200"),
+// the offset is zero then.
+func parseGeometry(s string) (mode string, offset Offset, ok bool) {
+	arg := strings.Split(s, "+")
+	if len(arg) == 1 && isModeSize(s) {
+		return s, Offset{}, true
+	}
+
+	if len(arg) != 3 {
+		return "", Offset{}, false
+	}
+
+	x, err := strconv.Atoi(arg[1])
+	if err != nil {
+		return "", Offset{}, false
+	}
+
+	y, err := strconv.Atoi(arg[2])
+	if err != nil {
+		return "", Offset{}, false
+	}
+
+	return arg[0], Offset{X: x, Y: y}, true
+}
+
+// isModeSize returns true iff s is a size of the form "WxH".
+func isModeSize(s string) bool {
+	data := strings.Split(s, "x")
+	if len(data) != 2 {
+		return false
+	}
+
+	for _, str := range data {
+		if _, err := strconv.Atoi(str); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseModeLine returns the mode parsed from the string. Mode lines are
+// indented, by two spaces in most builds of xrandr, but any whitespace is
+// accepted. Output lines start in the first column.
+func parseModeLine(line string) (mode Mode, err error) {
+	if line == "" || strings.TrimLeft(line, " \t") == line {
+		return Mode{}, errNotModeLine
+	}
+
+	ws := bufio.NewScanner(bytes.NewReader([]byte(line)))
+	ws.Split(bufio.ScanWords)
+
+	if !ws.Scan() {
+		return Mode{}, fmt.Errorf("line too short, mode name not found: %s", line)
+	}
+	mode.Name = ws.Text()
+
+	if !ws.Scan() {
+		return Mode{}, fmt.Errorf("line too short, no refresh rate found: %s", line)
+	}
+
+	// each refresh rate may be followed by "*" (active) and "+" (default),
+	// the "+" may also be a separate word, which happens when a mode is
+	// default but not active
+	for {
+		rate := ws.Text()
+		if strings.HasSuffix(rate, "+") {
+			mode.Default = true
+			rate = rate[:len(rate)-1]
+		}
+
+		if strings.HasSuffix(rate, "*") {
+			mode.Active = true
+			rate = rate[:len(rate)-1]
+		}
+
+		// words which are not a number are ignored
+		if r, err := strconv.ParseFloat(rate, 64); err == nil {
+			mode.Refresh = append(mode.Refresh, r)
+		}
+
+		if !ws.Scan() {
+			break
+		}
+	}
+
+	return mode, nil
+}
+
+// isModeLine returns true iff line looks like a mode line, i.e. a name
+// followed by refresh rates, as opposed to the value of a property.
+func isModeLine(line string) bool {
+	if strings.Contains(line, ":") {
+		return false
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return false
+	}
+
+	_, err := strconv.ParseFloat(strings.TrimRight(fields[1], "*+"), 64)
+	return err == nil
+}
+
+// RandrParse returns the list of outputs parsed from the reader.
+func RandrParse(rd io.Reader) (outputs Outputs, err error) {
+	ls := bufio.NewScanner(rd)
+
+	const (
+		StateStart = iota
+		StateOutput
+		StateMode
+	)
+
+	var (
+		state  = StateStart
+		output Output
+
+		// screen is the number of the current screen section
+		screen int
+
+		// edid collects the hex encoded EDID of the current output, inEDID
+		// is true while its lines are read
+		edid   string
+		inEDID bool
+	)
+
+nextLine:
+	for ls.Scan() {
+		line := ls.Text()
+
+		for {
+			switch state {
+			case StateStart:
+				if strings.HasPrefix(line, "Screen ") {
+					state = StateOutput
+					continue nextLine
+				}
+				return nil, fmt.Errorf(`first line should start with "Screen", found: %v`, line)
+
+			case StateOutput:
+				// the outputs of further X screens follow in a new section
+				if strings.HasPrefix(line, "Screen ") {
+					screen++
+					continue nextLine
+				}
+
+				output, err = parseOutputLine(line)
+				if err != nil {
+					return nil, err
+				}
+				output.Screen = screen
+				state = StateMode
+				continue nextLine
+
+			case StateMode:
+				// properties printed by xrandr --props are indented with
+				// tabs, their values with two tabs. Mode lines may be
+				// indented with tabs as well, so they are told apart by
+				// their content, and never contain a colon.
+				if strings.HasPrefix(line, "\t\t") && !isModeLine(line) {
+					if inEDID {
+						edid += strings.TrimSpace(line)
+					}
+					continue nextLine
+				}
+
+				if strings.HasPrefix(line, "\t") && strings.Contains(line, ":") {
+					inEDID = strings.HasPrefix(line, "\tEDID:")
+					continue nextLine
+				}
+
+				mode, err := parseModeLine(line)
+				if err == errNotModeLine {
+					output.Vendor, output.Serial = parseEDIDHex(edid)
+					outputs = append(outputs, output)
+					output = Output{}
+					edid, inEDID = "", false
+					state = StateOutput
+					continue
+				}
+
+				if err != nil {
+					return nil, err
+				}
+
+				output.Modes = append(output.Modes, mode)
+				continue nextLine
+			}
+		}
+	}
+
+	if err = ls.Err(); err != nil {
+		return nil, err
+	}
+
+	if output.Name != "" {
+		output.Vendor, output.Serial = parseEDIDHex(edid)
+		outputs = append(outputs, output)
+	}
+
+	qualifyNames(outputs)
+	return outputs, nil
+}
+
+// qualifyNames qualifies the names of outputs which are listed for more than
+// one X screen with the screen, e.g. "screen1:DP-1".
+func qualifyNames(outputs Outputs) {
+	count := make(map[string]int)
+	for _, o := range outputs {
+		count[o.Name]++
+	}
+
+	for i, o := range outputs {
+		if count[o.Name] > 1 {
+			Logf("output %v is listed for several screens\n", o.Name)
+			outputs[i].Name = o.qualifiedName()
+		}
+	}
+}
+
+// Options holds settings for building commands which are not part of a rule.
+type Options struct {
+	// AutoPrimary makes the first configured output the primary output for
+	// rules which do not name one.
+	AutoPrimary bool
+
+	// PrimaryLargest makes AutoPrimary select the output with the largest
+	// physical size instead of the first one.
+	PrimaryLargest bool
+
+	// InternalOutput is the name of the internal panel of a laptop, it is
+	// detected automatically if empty.
+	InternalOutput string
+
+	// ForceModes maps output name patterns to a mode which is used for
+	// matching outputs regardless of the mode requested by the rule.
+	ForceModes map[string]string
+
+	// DisableStale turns off outputs which are disconnected but still
+	// active, even if the rule configures them.
+	DisableStale bool
+
+	// ResetBefore runs "xrandr --auto" before the commands of every rule.
+	ResetBefore bool
+
+	// GammaPresets defines gamma presets in addition to the built-in ones,
+	// by name.
+	GammaPresets map[string]string
+
+	// XrandrExtraArgs are passed to every call of xrandr which configures
+	// the outputs, before the arguments built from the rule.
+	XrandrExtraArgs []string
+
+	// PinOutputs maps output names to a mode, or "keep" for the current
+	// configuration. Pinned outputs are configured whenever a rule is
+	// applied and never disabled.
+	PinOutputs map[string]string
+}
+
+// pinKeep is the value of Options.PinOutputs which keeps the current mode and
+// position of an output.
+const pinKeep = "keep"
+
+// Values for Rule.SplitMode, which selects how the outputs are distributed
+// over the calls to xrandr.
+const (
+	splitAtomic      = "atomic"
+	splitInterleaved = "interleaved"
+	splitGrouped     = "grouped"
+)
+
+// positionArgs are the arguments of xrandr which position an output, each is
+// followed by a value.
+var positionArgs = map[string]bool{
+	"--pos":      true,
+	"--same-as":  true,
+	"--right-of": true,
+	"--left-of":  true,
+	"--above":    true,
+	"--below":    true,
+}
+
+// extraArgsPositions returns the positions set for outputs in args, the extra
+// arguments for xrandr, e.g. "--same-as LVDS1" for "--output HDMI1 --same-as
+// LVDS1". It returns an error if an output is positioned twice.
+func extraArgsPositions(args []string) (map[string]string, error) {
+	positions := make(map[string]string)
+
+	var output string
+	for i := 0; i < len(args)-1; i++ {
+		arg := args[i]
+		switch {
+		case arg == "--output":
+			output = args[i+1]
+			i++
+		case positionArgs[arg] && output != "":
+			desc := arg + " " + args[i+1]
+			if prev, ok := positions[output]; ok {
+				return nil, fmt.Errorf("conflicting positions for output %v in xrandr_extra_args: %v and %v", output, prev, desc)
+			}
+			positions[output] = desc
+			i++
+		}
+	}
+
+	return positions, nil
+}
+
+// Logf is called for verbose log messages, it discards them by default.
+var Logf = func(format string, args ...interface{}) {}
+
+// BuildCommandOutputRow return a sequence of calls to `xrandr` to configure
+// all named outputs in a row, left to right, given the currently active
+// Outputs and a list of output names, optionally followed by "@" and the
+// desired mode, e.g. LVDS1@1377x768. The mode may be followed by "@" and a
+// refresh rate, either "max" for the highest rate available, a number, or a
+// number prefixed by ">=" or "<=" to select the closest rate available.
+func BuildCommandOutputRow(rule Rule, current Outputs, opts Options) ([]*exec.Cmd, error) {
+	targets, err := TargetLayout(rule, current, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	Logf("current: %v\n", current.Layout())
+	Logf("target: %v\n", FormatLayout(targets))
+
+	xrandr := func(args ...string) *exec.Cmd {
+		return xrandrCommand(opts, args...)
+	}
+
+	enableOutputArgs := [][]string{}
+
+	active := make(map[string]struct{})
+	positioned := make(map[string]string)
+	var lastOutput = ""
+
+	extraPositions, err := extraArgsPositions(opts.XrandrExtraArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the row is only laid out absolutely if every output has a position,
+	// otherwise an output with a position is also anchored right of the
+	// previous one
+	absoluteRow := true
+	for _, target := range targets {
+		if _, ok := rule.Positions[target.Name]; !ok {
+			absoluteRow = false
+		}
+	}
+
+	for i, target := range targets {
+		name := target.Name
+		active[name] = struct{}{}
+
+		if pos, ok := rule.Positions[name]; ok && i > 0 && !absoluteRow {
+			return nil, fmt.Errorf("conflicting positions for output %v: --pos %v and --right-of %v, set positions for all outputs of the row or none but the first",
+				name, pos, lastOutput)
+		}
+
+		var position []string
+		if target.Absolute {
+			position = []string{"--pos", target.Offset.String()}
+		} else if i > 0 {
+			position = []string{"--right-of", lastOutput}
+		}
+
+		// xrandr_extra_args are passed before the arguments of the rule, so
+		// the position set by the rule would silently win
+		if extra, ok := extraPositions[name]; ok && len(position) > 0 {
+			return nil, fmt.Errorf("conflicting positions for output %v: %v and %v from xrandr_extra_args",
+				name, strings.Join(position, " "), extra)
+		}
+
+		// an output listed more than once in the row would be positioned
+		// twice, xrandr silently uses the last directive
+		desc := strings.Join(position, " ")
+		if desc == "" {
+			desc = "start of row"
+		}
+		if prev, ok := positioned[name]; ok {
+			return nil, fmt.Errorf("conflicting positions for output %v: %v and %v", name, prev, desc)
+		}
+		positioned[name] = desc
+
+		// the current values of TearFree and underscan are not known, so
+		// they are always set
+		_, tearFree := rule.TearFree[name]
+		underscan, hasUnderscan := rule.Underscan[name]
+
+		// a conflicting rule is an error even for outputs which would be
+		// skipped as unchanged
+		if err := transformConflict(target); err != nil {
+			return nil, err
+		}
+
+		if rule.MinimalChanges && !target.Primary && !tearFree && !hasUnderscan && target.Unchanged(current) {
+			Logf("output %v is unchanged, skipping\n", name)
+			lastOutput = name
+			continue
+		}
+
+		args := []string{}
+		args = append(args, "--output", name)
+		if target.Mode == "" {
+			args = append(args, "--auto")
+		} else {
+			args = append(args, "--mode", target.Mode)
+		}
+
+		if target.Rate != "" {
+			args = append(args, "--rate", target.Rate)
+		}
+
+		if target.Scale != "" {
+			args = append(args, "--scale", target.Scale)
+		}
+
+		if target.Gamma != "" {
+			args = append(args, "--gamma", target.Gamma)
+		}
+
+		if crtc, ok := rule.CRTC[name]; ok {
+			if crtc < 0 {
+				return nil, fmt.Errorf("invalid crtc %d for output %v", crtc, name)
+			}
+			args = append(args, "--crtc", strconv.Itoa(crtc))
+		}
+
+		if target.Primary {
+			args = append(args, "--primary")
+		}
+
+		if target.Rotated {
+			args = append(args, "--rotate", target.Rotation)
+		}
+
+		if target.Transform != "" {
+			args = append(args, "--transform", target.Transform)
+		}
+
+		if tearFree {
+			value := "off"
+			if rule.TearFree[name] {
+				value = "on"
+			}
+			args = append(args, "--set", "TearFree", value)
+		}
+
+		if hasUnderscan {
+			set, err := underscanArgs(underscan, Mode{Name: target.ModeName})
+			if err != nil {
+				return nil, fmt.Errorf("output %v: %v", name, err)
+			}
+			args = append(args, set...)
+		}
+
+		args = append(args, position...)
+
+		lastOutput = name
+		enableOutputArgs = append(enableOutputArgs, args)
+	}
+
+	// pinned outputs not configured by the rule are configured as well, and
+	// none of them is disabled
+	enableOutputArgs = append(enableOutputArgs, pinnedArgs(opts.PinOutputs, current, active)...)
+	for name := range opts.PinOutputs {
+		active[name] = struct{}{}
+	}
+
+	disableOutputArgs := disableArgs(rule, current, active)
+
+	cmds := []*exec.Cmd{}
+
+	// set the size of the framebuffer before any output is configured
+	if rule.Framebuffer != "" {
+		Logf("setting the framebuffer size to %v\n", rule.Framebuffer)
+		cmds = append(cmds, xrandr("--fb", rule.Framebuffer))
+	}
+
+	// reset all outputs to their default mode first, this makes some
+	// drivers more reliable at the cost of additional flicker
+	if rule.ResetBefore || opts.ResetBefore {
+		Logf("resetting outputs with xrandr --auto\n")
+		cmds = append(cmds, xrandr("--auto"))
+	}
+
+	// create the modes the outputs do not list yet before they are used
+	newModes, err := newModeArgs(rule, current)
+	if err != nil {
+		return nil, err
+	}
+	for _, args := range newModes {
+		cmds = append(cmds, xrandr(args...))
+	}
+
+	mode := rule.SplitMode
+	if rule.Atomic {
+		mode = splitAtomic
+	}
+
+	// enable/disable all monitors in one call to xrandr
+	if mode == splitAtomic {
+		Logf("using one atomic call to xrandr\n")
+		args := []string{}
+		for _, disableArgs := range disableOutputArgs {
+			args = append(args, disableArgs...)
+		}
+		for _, enableArgs := range enableOutputArgs {
+			args = append(args, enableArgs...)
+		}
+		cmd := xrandr(args...)
+		return append(cmds, cmd), nil
+	}
+
+	// by default outputs are disabled before others are enabled, with
+	// EnableFirst the order is reversed
+	first, second := disableOutputArgs, enableOutputArgs
+	if rule.EnableFirst {
+		first, second = enableOutputArgs, disableOutputArgs
+	}
+
+	// disable all outputs in one call, then enable all outputs in another
+	if mode == splitGrouped {
+		Logf("using one call to xrandr for disabling and one for enabling outputs\n")
+		for _, group := range [][][]string{first, second} {
+			if len(group) == 0 {
+				continue
+			}
+
+			args := []string{}
+			for _, outputArgs := range group {
+				args = append(args, outputArgs...)
+			}
+			cmds = append(cmds, xrandr(args...))
+		}
+		return cmds, nil
+	}
+
+	Logf("splitting the configuration into several calls to xrandr\n")
+
+	// otherwise return several calls to xrandr
+
+	// disable (or enable) an output
+	if len(first) > 0 {
+		cmds = append(cmds, xrandr(first[0]...))
+		first = first[1:]
+	}
+
+	// now for each newly enabled output, also disable another output
+	for len(first) > 0 || len(second) > 0 {
+		args := []string{}
+		if len(first) > 0 {
+			args = append(args, first[0]...)
+			first = first[1:]
+		}
+		if len(second) > 0 {
+			args = append(args, second[0]...)
+			second = second[1:]
+		}
+
+		cmds = append(cmds, xrandr(args...))
+	}
+
+	return cmds, nil
+}
+
+// xrandrCommand returns the command to run xrandr with args, preceded by the
+// extra arguments configured by the user.
+func xrandrCommand(opts Options, args ...string) *exec.Cmd {
+	return exec.Command("xrandr", append(append([]string{}, opts.XrandrExtraArgs...), args...)...)
+}
+
+// disableArgs returns the arguments for xrandr which turn off the outputs in
+// current that are not contained in active, in the order requested by the
+// rule.
+func disableArgs(rule Rule, current Outputs, active map[string]struct{}) [][]string {
+	disableOutputs := make(map[string]struct{})
+	for _, output := range current {
+		if !output.Connected && len(output.Modes) == 0 {
+			continue
+		}
+
+		// outputs which are already off need not be disabled again
+		if rule.MinimalChanges && !output.Active() {
+			continue
+		}
+
+		// outputs not managed by the rule are left alone
+		if !rule.Managed(output) {
+			Logf("not disabling output %v, it is not managed by the rule\n", output.Name)
+			continue
+		}
+
+		// xrandr cannot address outputs whose name is used by several
+		// screens
+		if strings.Contains(output.Name, ":") {
+			Logf("not disabling output %v, its name is used by several screens\n", output.Name)
+			continue
+		}
+
+		// disable unneeded outputs that are still active
+		if _, ok := active[output.Name]; !ok {
+			disableOutputs[output.Name] = struct{}{}
+		}
+	}
+
+	disableOutputArgs := [][]string{}
+
+	// honour disable_order if present
+	for _, name := range rule.DisableOrder {
+		if _, ok := disableOutputs[name]; ok {
+			args := []string{"--output", name, "--off"}
+			disableOutputArgs = append(disableOutputArgs, args)
+
+			delete(disableOutputs, name)
+		}
+	}
+
+	// disable the remaining outputs from right to left if requested, so
+	// that the outputs next to the ones which stay enabled go last
+	if rule.DisableReverse {
+		var remaining Outputs
+		for _, output := range current {
+			if _, ok := disableOutputs[output.Name]; ok {
+				remaining = append(remaining, output)
+			}
+		}
+		sort.Stable(rightToLeft(remaining))
+
+		for _, output := range remaining {
+			args := []string{"--output", output.Name, "--off"}
+			disableOutputArgs = append(disableOutputArgs, args)
+
+			delete(disableOutputs, output.Name)
+		}
+	}
+
+	// collect remaining outputs to be disabled
+	for name := range disableOutputs {
+		args := []string{"--output", name, "--off"}
+		disableOutputArgs = append(disableOutputArgs, args)
+	}
+
+	return disableOutputArgs
+}
+
+// pinnedArgs returns the arguments to xrandr which configure the connected
+// outputs in pinned which are not in active. The mode is used for outputs
+// which are not active yet, "keep" enables them with their default mode.
+// Active outputs keep their position and rotation.
+func pinnedArgs(pinned map[string]string, current Outputs, active map[string]struct{}) [][]string {
+	var res [][]string
+	for _, name := range sortedKeys(pinned) {
+		if _, ok := active[name]; ok {
+			Logf("pinned output %v is configured by the rule\n", name)
+			continue
+		}
+
+		o, ok := current.Get(name)
+		if !ok || !o.Connected {
+			Logf("pinned output %v is not connected\n", name)
+			continue
+		}
+
+		args := []string{"--output", name}
+		mode := pinned[name]
+		cur, isActive := o.ActiveMode()
+		switch {
+		case mode == pinKeep && isActive:
+			args = append(args, "--mode", cur.Name)
+		case mode == pinKeep:
+			args = append(args, "--auto")
+		default:
+			args = append(args, "--mode", o.canonicalMode(mode))
+		}
+
+		if isActive {
+			args = append(args, "--pos", o.Offset.String())
+			if o.Rotation != "" {
+				args = append(args, "--rotate", o.Rotation)
+			}
+		}
+
+		res = append(res, args)
+	}
+
+	return res
+}
+
+// BuildCommandOff returns the commands which turn off all connected outputs
+// whose name matches pattern, one output per call to xrandr. It refuses to
+// turn off all active outputs.
+func BuildCommandOff(pattern string, current Outputs, opts Options) ([]*exec.Cmd, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("pattern %q malformed: %v", pattern, err)
+	}
+
+	rule := Rule{Manages: []string{pattern}, DisableReverse: true}
+
+	// keep all outputs which are not connected or do not match
+	keep := make(map[string]struct{})
+	var remaining int
+	for _, output := range current {
+		if !output.Connected || !rule.Managed(output) {
+			keep[output.Name] = struct{}{}
+			if output.Active() {
+				remaining++
+			}
+		}
+	}
+
+	if len(keep) == len(current) {
+		return nil, fmt.Errorf("no connected output matches %v", pattern)
+	}
+
+	if remaining == 0 {
+		return nil, fmt.Errorf("refusing to turn off all active outputs")
+	}
+
+	var cmds []*exec.Cmd
+	for _, args := range disableArgs(rule, current, keep) {
+		cmds = append(cmds, xrandrCommand(opts, args...))
+	}
+
+	return cmds, nil
+}
@@ -0,0 +1,1754 @@
+package randr
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var randrTestOutputs = []struct {
+	str     string
+	outputs []Output
+}{
+	{
+		`Screen 0: minimum 320 x 200, current 3280 x 1200, maximum 8192 x 8192
+LVDS1 connected (normal left inverted right x axis y axis)
+   1366x768      60.10 +
+   1024x768      60.00
+   800x600       60.32    56.25
+   640x480       59.94
+VGA1 disconnected (normal left inverted right x axis y axis)
+HDMI1 disconnected (normal left inverted right x axis y axis)
+DP1 disconnected (normal left inverted right x axis y axis)
+HDMI2 connected 1600x1200+0+0 (normal left inverted right x axis y axis) 408mm x 306mm
+   1600x1200     60.00*+
+   1280x1024     75.02    60.02
+   1280x960      60.00
+   1152x864      75.00
+   1024x768      75.08    70.07    60.00
+   832x624       74.55
+   800x600       72.19    75.00    60.32    56.25
+   640x480       75.00    72.81    66.67    60.00
+   720x400       70.08
+DP2 disconnected (normal left inverted right x axis y axis)
+DP3 disconnected (normal left inverted right x axis y axis)`,
+		[]Output{
+			Output{
+				Name: "LVDS1",
+				Modes: []Mode{
+					{Name: "1366x768", Default: true, Refresh: []float64{60.10}},
+					{Name: "1024x768", Refresh: []float64{60.00}},
+					{Name: "800x600", Refresh: []float64{60.32, 56.25}},
+					{Name: "640x480", Refresh: []float64{59.94}},
+				},
+				Connected: true,
+			},
+			Output{Name: "VGA1"},
+			Output{Name: "HDMI1"},
+			Output{Name: "DP1"},
+			Output{
+				Name: "HDMI2",
+				Modes: []Mode{
+					{Name: "1600x1200", Default: true, Active: true, Refresh: []float64{60.00}},
+					{Name: "1280x1024", Refresh: []float64{75.02, 60.02}},
+					{Name: "1280x960", Refresh: []float64{60.00}},
+					{Name: "1152x864", Refresh: []float64{75.00}},
+					{Name: "1024x768", Refresh: []float64{75.08, 70.07, 60.00}},
+					{Name: "832x624", Refresh: []float64{74.55}},
+					{Name: "800x600", Refresh: []float64{72.19, 75.00, 60.32, 56.25}},
+					{Name: "640x480", Refresh: []float64{75.00, 72.81, 66.67, 60.00}},
+					{Name: "720x400", Refresh: []float64{70.08}},
+				},
+				Connected: true,
+			},
+			Output{Name: "DP2"},
+			Output{Name: "DP3"},
+		},
+	},
+	{
+		`Screen 0: minimum 320 x 200, current 3280 x 1200, maximum 8192 x 8192
+LVDS1 connected (normal left inverted right x axis y axis)
+   1366x768      60.10 +
+   1024x768      60.00
+   800x600       60.32    56.25
+   640x480       59.94`,
+		[]Output{
+			Output{
+				Name: "LVDS1",
+				Modes: []Mode{
+					{Name: "1366x768", Default: true, Refresh: []float64{60.10}},
+					{Name: "1024x768", Refresh: []float64{60.00}},
+					{Name: "800x600", Refresh: []float64{60.32, 56.25}},
+					{Name: "640x480", Refresh: []float64{59.94}},
+				},
+				Connected: true,
+			},
+		},
+	},
+	{
+		`Screen 0: minimum 8 x 8, current 4480 x 1440, maximum 32767 x 32767
+eDP1 connected 1920x1080+2560+0 (normal left inverted right x axis y axis) 276mm x 156mm
+   1920x1080     60.04*+
+   1400x1050     59.98
+   1600x900      60.00
+   1280x1024     60.02
+   1280x960      60.00
+   1368x768      60.00
+   1280x720      60.00
+   1024x768      60.00
+   1024x576      60.00
+   960x540       60.00
+   800x600       60.32    56.25
+   864x486       60.00
+   640x480       59.94
+   720x405       60.00
+   640x360       60.00
+DP1 disconnected (normal left inverted right x axis y axis)
+DP2 disconnected (normal left inverted right x axis y axis)
+DP2-1 disconnected (normal left inverted right x axis y axis)
+DP2-2 connected primary 2560x1440+0+0 (normal left inverted right x axis y axis) 597mm x 336mm
+   2560x1440     59.95*+
+   2048x1152     60.00
+   1920x1200     59.88
+   1920x1080     60.00    50.00    59.94    30.00    25.00    24.00    29.97    23.98
+   1600x1200     60.00
+   1680x1050     59.95
+   1280x1024     75.02    60.02
+   1200x960      59.99
+   1152x864      75.00
+   1280x720      60.00    50.00    59.94
+   1024x768      75.08    60.00
+   800x600       75.00    60.32
+   720x576       50.00
+   720x480       60.00    59.94
+   640x480       75.00    60.00    59.94
+   720x400       70.08
+DP2-3 disconnected (normal left inverted right x axis y axis)
+HDMI1 disconnected (normal left inverted right x axis y axis)
+HDMI2 disconnected (normal left inverted right x axis y axis)
+VIRTUAL1 disconnected (normal left inverted right x axis y axis)`,
+		[]Output{
+			Output{
+				Name: "eDP1",
+				Modes: []Mode{
+					{Name: "1920x1080", Default: true, Active: true, Refresh: []float64{60.04}},
+					{Name: "1400x1050", Refresh: []float64{59.98}},
+					{Name: "1600x900", Refresh: []float64{60.00}},
+					{Name: "1280x1024", Refresh: []float64{60.02}},
+					{Name: "1280x960", Refresh: []float64{60.00}},
+					{Name: "1368x768", Refresh: []float64{60.00}},
+					{Name: "1280x720", Refresh: []float64{60.00}},
+					{Name: "1024x768", Refresh: []float64{60.00}},
+					{Name: "1024x576", Refresh: []float64{60.00}},
+					{Name: "960x540", Refresh: []float64{60.00}},
+					{Name: "800x600", Refresh: []float64{60.32, 56.25}},
+					{Name: "864x486", Refresh: []float64{60.00}},
+					{Name: "640x480", Refresh: []float64{59.94}},
+					{Name: "720x405", Refresh: []float64{60.00}},
+					{Name: "640x360", Refresh: []float64{60.00}},
+				},
+				Connected: true,
+			},
+			Output{Name: "DP1"},
+			Output{Name: "DP2"},
+			Output{Name: "DP2-1"},
+			Output{
+				Name: "DP2-2",
+				Modes: []Mode{
+					{Name: "2560x1440", Default: true, Active: true, Refresh: []float64{59.95}},
+					{Name: "2048x1152", Refresh: []float64{60.00}},
+					{Name: "1920x1200", Refresh: []float64{59.88}},
+					{Name: "1920x1080", Refresh: []float64{60.00, 50.00, 59.94, 30.00, 25.00, 24.00, 29.97, 23.98}},
+					{Name: "1600x1200", Refresh: []float64{60.00}},
+					{Name: "1680x1050", Refresh: []float64{59.95}},
+					{Name: "1280x1024", Refresh: []float64{75.02, 60.02}},
+					{Name: "1200x960", Refresh: []float64{59.99}},
+					{Name: "1152x864", Refresh: []float64{75.00}},
+					{Name: "1280x720", Refresh: []float64{60.00, 50.00, 59.94}},
+					{Name: "1024x768", Refresh: []float64{75.08, 60.00}},
+					{Name: "800x600", Refresh: []float64{75.00, 60.32}},
+					{Name: "720x576", Refresh: []float64{50.00}},
+					{Name: "720x480", Refresh: []float64{60.00, 59.94}},
+					{Name: "640x480", Refresh: []float64{75.00, 60.00, 59.94}},
+					{Name: "720x400", Refresh: []float64{70.08}},
+				},
+				Connected: true,
+			},
+			Output{Name: "DP2-3"},
+			Output{Name: "HDMI1"},
+			Output{Name: "HDMI2"},
+			Output{Name: "VIRTUAL1"},
+		},
+	},
+	{
+		`Screen 0: minimum 320 x 200, current 3280 x 1200, maximum 8192 x 8192
+LVDS1 connected (normal left inverted right x axis y axis)
+   1366x768      60.10 +
+   1024x768      60.00  
+   800x600       60.32    56.25  
+   640x480       59.94  
+HDMI2 disconnected 1600x1200+0+0 (normal left inverted right x axis y axis) 0mm x 0mm
+HDMI3 disconnected 1680x1050+1600+0 (normal left inverted right x axis y axis) 0mm x 0mm`,
+		[]Output{
+			Output{
+				Name: "LVDS1",
+				Modes: []Mode{
+					{Name: "1366x768", Default: true, Refresh: []float64{60.10}},
+					{Name: "1024x768", Refresh: []float64{60.00}},
+					{Name: "800x600", Refresh: []float64{60.32, 56.25}},
+					{Name: "640x480", Refresh: []float64{59.94}},
+				},
+				Connected: true,
+			},
+			Output{
+				Name: "HDMI2",
+				Modes: []Mode{
+					{Name: "1600x1200", Active: true},
+				},
+			},
+			Output{
+				Name: "HDMI3",
+				Modes: []Mode{
+					{Name: "1680x1050", Active: true},
+				},
+			},
+		},
+	},
+}
+
+func TestRandrParse(t *testing.T) {
+	for _, test := range randrTestOutputs {
+		out, err := RandrParse(bytes.NewReader([]byte(test.str)))
+		if err != nil {
+			t.Errorf("unable to parse test string: %v", err)
+			continue
+		}
+
+		if len(test.outputs) != len(out) {
+			t.Errorf("number of connected outputs does not mach: expected %d, got %d",
+				len(test.outputs), len(out))
+			continue
+		}
+
+		for i := range test.outputs {
+			out1 := test.outputs[i]
+			out2 := out[i]
+
+			if out1.Name != out2.Name {
+				t.Errorf("output %d: name not equal: want %q, got %q", i,
+					out1.Name, out2.Name)
+			}
+
+			if out1.Connected != out2.Connected {
+				t.Errorf("output %d: connected not equal: want %v, got %v", i,
+					out1.Connected, out2.Connected)
+			}
+
+			if !reflect.DeepEqual(out1.Modes, out2.Modes) {
+				t.Errorf("output %d: list of modes not equal: want %v, got %v", i,
+					out1.Modes, out2.Modes)
+			}
+		}
+	}
+}
+
+var TestOutputLines = []struct {
+	line   string
+	output Output
+}{
+	{
+		"LVDS1 connected (normal left inverted right x axis y axis)",
+		Output{
+			Name:      "LVDS1",
+			Connected: true,
+		},
+	},
+	{
+		"VGA1 disconnected (normal left inverted right x axis y axis)",
+		Output{
+			Name:      "VGA1",
+			Connected: false,
+		},
+	},
+	{
+		"HDMI3 disconnected 1680x1050+1600+0 (normal left inverted right x axis y axis) 0mm x 0mm`",
+		Output{
+			Name:     "HDMI3",
+			Modes:    []Mode{{Name: "1680x1050", Active: true}},
+			Offset:   Offset{X: 1600},
+			Rotation: "normal",
+		},
+	},
+	{
+		"HDMI3 disconnected 1680x1050 (normal left inverted right x axis y axis) 0mm x 0mm",
+		Output{
+			Name:     "HDMI3",
+			Modes:    []Mode{{Name: "1680x1050", Active: true}},
+			Rotation: "normal",
+		},
+	},
+	{
+		"DP1 connected 1920x1080 left (normal left inverted right x axis y axis) 527mm x 296mm",
+		Output{
+			Name:      "DP1",
+			Connected: true,
+			WidthMM:   527,
+			HeightMM:  296,
+			Rotation:  "left",
+		},
+	},
+	{
+		"DP2-2 connected primary 2560x1440+1920+0 (normal left inverted right x axis y axis) 597mm x 336mm",
+		Output{
+			Name:      "DP2-2",
+			Connected: true,
+			WidthMM:   597,
+			HeightMM:  336,
+			Primary:   true,
+			Offset:    Offset{X: 1920},
+			Rotation:  "normal",
+		},
+	},
+	{
+		"HDMI2 connected 1200x1920+0+0 left (normal left inverted right x axis y axis) 518mm x 324mm",
+		Output{
+			Name:      "HDMI2",
+			Connected: true,
+			WidthMM:   518,
+			HeightMM:  324,
+			Rotation:  "left",
+		},
+	},
+}
+
+func TestParseOutputLine(t *testing.T) {
+	for i, test := range TestOutputLines {
+		out, err := parseOutputLine(test.line)
+		if err != nil {
+			t.Errorf("test %d returned error: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(out, test.output) {
+			t.Errorf("test %d failed: expected Output not found", i)
+			continue
+		}
+	}
+}
+
+var TestModeLines = []struct {
+	line string
+	mode Mode
+}{
+	{
+		"  1152x864      75.00",
+		Mode{
+			Name:    "1152x864",
+			Refresh: []float64{75.00},
+		},
+	},
+	{
+		"  1024x768      75.08    70.07    60.00",
+		Mode{
+			Name:    "1024x768",
+			Refresh: []float64{75.08, 70.07, 60.00},
+		},
+	},
+	{
+		"  1600x1200     60.00*+",
+		Mode{
+			Name:    "1600x1200",
+			Active:  true,
+			Default: true,
+			Refresh: []float64{60.00},
+		},
+	},
+	{
+		"  1366x768      60.10 +",
+		Mode{
+			Name:    "1366x768",
+			Default: true,
+			Refresh: []float64{60.10},
+		},
+	},
+	{
+		"  832x624       74.55",
+		Mode{
+			Name:    "832x624",
+			Refresh: []float64{74.55},
+		},
+	},
+	{
+		"  1920x1080     60.00*",
+		Mode{
+			Name:    "1920x1080",
+			Active:  true,
+			Refresh: []float64{60.00},
+		},
+	},
+	{
+		"  1920x1080     60.00 +  50.00*   59.94",
+		Mode{
+			Name:    "1920x1080",
+			Active:  true,
+			Default: true,
+			Refresh: []float64{60.00, 50.00, 59.94},
+		},
+	},
+	{
+		"  1920x1080     6",
+		Mode{
+			Name:    "1920x1080",
+			Refresh: []float64{6},
+		},
+	},
+	{
+		"\t1920x1080\t60.00*+\t50.00",
+		Mode{
+			Name:    "1920x1080",
+			Active:  true,
+			Default: true,
+			Refresh: []float64{60.00, 50.00},
+		},
+	},
+	{
+		"   1280x1024     75.02    60.02",
+		Mode{
+			Name:    "1280x1024",
+			Refresh: []float64{75.02, 60.02},
+		},
+	},
+}
+
+func FuzzRandrParse(f *testing.F) {
+	for _, test := range randrTestOutputs {
+		f.Add(test.str)
+	}
+	for _, test := range TestOutputLines {
+		f.Add("Screen 0:\n" + test.line)
+	}
+	for _, test := range TestModeLines {
+		f.Add("Screen 0:\nLVDS1 connected\n" + test.line)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		outputs, err := RandrParse(strings.NewReader(s))
+		if err != nil {
+			return
+		}
+
+		for _, o := range outputs {
+			if o.Name == "" {
+				t.Errorf("output without name parsed from %q", s)
+			}
+		}
+	})
+}
+
+func TestParseModeLine(t *testing.T) {
+	for i, test := range TestModeLines {
+		mode, err := parseModeLine(test.line)
+		if err != nil {
+			t.Errorf("test %d returned error: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(mode, test.mode) {
+			t.Errorf("test %d failed: expected Mode not found", i)
+			continue
+		}
+	}
+}
+
+func TestParseModeLineOutputLine(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"HDMI1 connected 1920x1080+0+0 (normal left inverted right x axis y axis) 527mm x 296mm",
+		"VGA1 disconnected (normal left inverted right x axis y axis)",
+	} {
+		if _, err := parseModeLine(line); err != errNotModeLine {
+			t.Errorf("line %q: want errNotModeLine, got %v", line, err)
+		}
+	}
+}
+
+func TestRandrParseIndentation(t *testing.T) {
+	str := "Screen 0: minimum 320 x 200, current 1920 x 1080, maximum 8192 x 8192\n" +
+		"HDMI1 connected 1920x1080+0+0 (normal left inverted right x axis y axis) 527mm x 296mm\n" +
+		"\tEDID: \n" +
+		"\t\t00ffffffffffff00\n" +
+		"\tBroadcast RGB: Automatic \n" +
+		"\t\tsupported: Automatic, Full, Limited 16:235\n" +
+		"\t1920x1080\t60.00*+\n" +
+		"\t1280x1024\t60.02\n" +
+		"VGA1 connected (normal left inverted right x axis y axis)\n" +
+		"   1024x768      60.00 +\n" +
+		"   800x600       60.32\n" +
+		"DP2 connected (normal left inverted right x axis y axis)\n" +
+		"\tEDID: \n" +
+		"\t\t00ffffffffffff0010ac\n" +
+		"\t\t2560x1440\t59.95 +\n" +
+		"\t\t1920x1080\t60.00    50.00\n" +
+		"\tnon-desktop: 0 \n" +
+		"\t\trange: (0, 1)\n" +
+		"DP1 disconnected (normal left inverted right x axis y axis)\n"
+
+	outputs, err := RandrParse(strings.NewReader(str))
+	if err != nil {
+		t.Fatalf("RandrParse returned error: %v", err)
+	}
+
+	want := []struct {
+		name  string
+		modes string
+	}{
+		{"HDMI1", "1920x1080*+ 1280x1024"},
+		{"VGA1", "1024x768+ 800x600"},
+		{"DP2", "2560x1440+ 1920x1080"},
+		{"DP1", ""},
+	}
+
+	if len(outputs) != len(want) {
+		t.Fatalf("want %d outputs, got %d: %v", len(want), len(outputs), outputs)
+	}
+
+	for i, w := range want {
+		if outputs[i].Name != w.name || outputs[i].Modes.String() != w.modes {
+			t.Errorf("output %d: want %v with modes %q, got %v with modes %q",
+				i, w.name, w.modes, outputs[i].Name, outputs[i].Modes.String())
+		}
+	}
+}
+
+func TestBuildCommandOutputRowCRTC(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"LVDS1", "HDMI1"},
+		CRTC:         map[string]int{"HDMI1": 1},
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "LVDS1", "--auto"},
+		{"xrandr", "--output", "HDMI1", "--auto", "--crtc", "1", "--right-of", "LVDS1"},
+	}
+
+	if len(cmds) != len(want) {
+		t.Fatalf("wrong number of commands: want %d, got %d", len(want), len(cmds))
+	}
+
+	for i, cmd := range cmds {
+		if !reflect.DeepEqual(cmd.Args, want[i]) {
+			t.Errorf("command %d: want %v, got %v", i, want[i], cmd.Args)
+		}
+	}
+
+	rule.CRTC["HDMI1"] = -1
+	if _, err = BuildCommandOutputRow(rule, current, Options{}); err == nil {
+		t.Errorf("negative crtc did not return an error")
+	}
+}
+
+func TestBuildCommandOutputRowTearFree(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureRow:   []string{"LVDS1", "HDMI1"},
+		TearFree:       map[string]bool{"LVDS1": false, "HDMI1": true},
+		MinimalChanges: true,
+		Atomic:         true,
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr",
+			"--output", "LVDS1", "--auto", "--set", "TearFree", "off",
+			"--output", "HDMI1", "--auto", "--set", "TearFree", "on", "--right-of", "LVDS1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestBuildCommandOutputRowNormalizeOrigin(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+		{Name: "VGA1", Connected: true, Modes: []Mode{{Name: "1024x768", Default: true}}},
+	}
+
+	var tests = []struct {
+		rule Rule
+		want [][]string
+	}{
+		{
+			Rule{
+				ConfigureRow: []string{"HDMI1", "LVDS1"},
+				Positions:    map[string]string{"HDMI1": "-1920x-312"},
+			},
+			[][]string{
+				{"xrandr", "--output", "HDMI1", "--auto", "--pos", "0x0",
+					"--output", "LVDS1", "--auto", "--pos", "1920x0"},
+			},
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "VGA1"},
+				Positions:    map[string]string{"LVDS1": "100x50", "VGA1": "1466x0"},
+			},
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--auto", "--pos", "0x50",
+					"--output", "VGA1", "--auto", "--pos", "1366x0"},
+			},
+		},
+		{
+			Rule{ConfigureRow: []string{"LVDS1", "HDMI1"}},
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--auto",
+					"--output", "HDMI1", "--auto", "--right-of", "LVDS1"},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		test.rule.NormalizeOrigin = true
+		test.rule.Atomic = true
+		test.rule.Manages = test.rule.ConfigureRow
+		cmds, err := BuildCommandOutputRow(test.rule, current, Options{})
+		if err != nil {
+			t.Fatalf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowConflictingPositions(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}, {Name: "1024x768"}}},
+	}
+
+	sameAs := Options{XrandrExtraArgs: []string{"--output", "HDMI1", "--same-as", "LVDS1"}}
+
+	var tests = []struct {
+		rule Rule
+		opts Options
+		err  string
+	}{
+		// an output listed twice in the row
+		{
+			Rule{ConfigureRow: []string{"LVDS1", "HDMI1", "HDMI1@1024x768"}},
+			Options{},
+			"conflicting positions for output HDMI1: --right-of LVDS1 and --right-of HDMI1",
+		},
+		{
+			Rule{ConfigureRow: []string{"HDMI1", "LVDS1", "HDMI1"}},
+			Options{},
+			"conflicting positions for output HDMI1: start of row and --right-of LVDS1",
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "HDMI1", "HDMI1"},
+				Positions:    map[string]string{"LVDS1": "0x0", "HDMI1": "0x768"},
+			},
+			Options{},
+			"conflicting positions for output HDMI1: --pos 0x768 and --pos 0x768",
+		},
+		{
+			Rule{
+				ConfigureRow:   []string{"LVDS1", "LVDS1"},
+				MinimalChanges: true,
+			},
+			Options{},
+			"conflicting positions for output LVDS1: start of row and --right-of LVDS1",
+		},
+		// an absolute position and the relative anchor of the row
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "HDMI1"},
+				Positions:    map[string]string{"HDMI1": "0x768"},
+			},
+			Options{},
+			"conflicting positions for output HDMI1: --pos 0x768 and --right-of LVDS1, set positions for all outputs of the row or none but the first",
+		},
+		{
+			Rule{
+				ConfigureRow:    []string{"LVDS1", "HDMI1"},
+				Positions:       map[string]string{"HDMI1": "0x768"},
+				NormalizeOrigin: true,
+			},
+			Options{},
+			"conflicting positions for output HDMI1: --pos 0x768 and --right-of LVDS1, set positions for all outputs of the row or none but the first",
+		},
+		// --same-as and the relative anchor or the position of the rule
+		{
+			Rule{ConfigureRow: []string{"LVDS1", "HDMI1"}},
+			sameAs,
+			"conflicting positions for output HDMI1: --right-of LVDS1 and --same-as LVDS1 from xrandr_extra_args",
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"HDMI1"},
+				Positions:    map[string]string{"HDMI1": "0x768"},
+			},
+			sameAs,
+			"conflicting positions for output HDMI1: --pos 0x768 and --same-as LVDS1 from xrandr_extra_args",
+		},
+		{
+			Rule{ConfigureSingle: "LVDS1"},
+			Options{XrandrExtraArgs: []string{"--output", "HDMI1", "--same-as", "LVDS1", "--right-of", "LVDS1"}},
+			"conflicting positions for output HDMI1 in xrandr_extra_args: --same-as LVDS1 and --right-of LVDS1",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := BuildCommandOutputRow(test.rule, current, test.opts)
+		if err == nil {
+			t.Errorf("test %d: expected error, got nil", i)
+			continue
+		}
+
+		if err.Error() != test.err {
+			t.Errorf("test %d: wrong error: want %q, got %q", i, test.err, err)
+		}
+	}
+
+	var valid = []struct {
+		rule Rule
+		opts Options
+	}{
+		// a position for every output or only for the first one
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "HDMI1"},
+				Positions:    map[string]string{"LVDS1": "0x0", "HDMI1": "0x768"},
+			},
+			Options{},
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "HDMI1"},
+				Positions:    map[string]string{"LVDS1": "100x0"},
+			},
+			Options{},
+		},
+		// mirroring an output which the rule does not position
+		{
+			Rule{ConfigureSingle: "HDMI1"},
+			sameAs,
+		},
+	}
+
+	for i, test := range valid {
+		if _, err := BuildCommandOutputRow(test.rule, current, test.opts); err != nil {
+			t.Errorf("valid test %d: returned error: %v", i, err)
+		}
+	}
+}
+
+func testCommandArgs(cmds []*exec.Cmd) [][]string {
+	var args [][]string
+	for _, cmd := range cmds {
+		args = append(args, cmd.Args)
+	}
+	return args
+}
+
+func TestBuildCommandOutputRowMinimalChanges(t *testing.T) {
+	current := Outputs{
+		{
+			Name:      "LVDS1",
+			Connected: true,
+			Modes: []Mode{
+				{Name: "1366x768", Default: true, Active: true},
+				{Name: "1024x768"},
+			},
+			Rotation: "normal",
+		},
+		{
+			Name:      "HDMI1",
+			Connected: true,
+			Modes: []Mode{
+				{Name: "1920x1080", Default: true, Active: true},
+				{Name: "1280x1024"},
+			},
+			Offset:   Offset{X: 1366},
+			Rotation: "normal",
+		},
+		{
+			Name:      "VGA1",
+			Connected: true,
+			Modes:     []Mode{{Name: "1024x768", Default: true}},
+		},
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"LVDS1", "HDMI1@1280x1024"},
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "VGA1", "--off"},
+		{"xrandr", "--output", "LVDS1", "--auto"},
+		{"xrandr", "--output", "HDMI1", "--mode", "1280x1024", "--right-of", "LVDS1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("full commands wrong:\n  want %v\n  got  %v", want, got)
+	}
+
+	rule.MinimalChanges = true
+	cmds, err = BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want = [][]string{
+		{"xrandr", "--output", "HDMI1", "--mode", "1280x1024", "--right-of", "LVDS1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("minimal commands wrong:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestBuildCommandOutputRowMinimalChangesRotation(t *testing.T) {
+	current := Outputs{
+		{
+			Name:      "LVDS1",
+			Connected: true,
+			Modes:     []Mode{{Name: "1366x768", Default: true, Active: true}},
+			Rotation:  "left",
+		},
+	}
+
+	var tests = []struct {
+		rule Rule
+		want [][]string
+	}{
+		{
+			Rule{ConfigureRow: []string{"LVDS1"}, MinimalChanges: true},
+			nil,
+		},
+		{
+			Rule{ConfigureRow: []string{"LVDS1"}, Rotate: map[string]string{"LVDS1": "left"}, MinimalChanges: true},
+			nil,
+		},
+		{
+			Rule{ConfigureRow: []string{"LVDS1/normal"}, MinimalChanges: true},
+			[][]string{{"xrandr", "--output", "LVDS1", "--auto", "--rotate", "normal"}},
+		},
+	}
+
+	for i, test := range tests {
+		cmds, err := BuildCommandOutputRow(test.rule, current, Options{})
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowMinimalChangesConflict(t *testing.T) {
+	current := Outputs{
+		{
+			Name:      "LVDS1",
+			Connected: true,
+			Modes:     []Mode{{Name: "1366x768", Default: true, Active: true}},
+			Rotation:  "left",
+		},
+	}
+
+	rule := Rule{
+		ConfigureRow:   []string{"LVDS1/left"},
+		Transform:      map[string]string{"LVDS1": "2,0,0,0,2,0,0,0,1"},
+		MinimalChanges: true,
+	}
+
+	if _, err := BuildCommandOutputRow(rule, current, Options{}); err == nil {
+		t.Errorf("expected error for transform combined with rotation, got nil")
+	}
+}
+
+func TestBuildCommandOutputRowPrimary(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	var tests = []struct {
+		autoPrimary bool
+		primary     string
+		want        [][]string
+	}{
+		{
+			false, "",
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--auto"},
+				{"xrandr", "--output", "HDMI1", "--auto", "--right-of", "LVDS1"},
+			},
+		},
+		{
+			true, "",
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--auto", "--primary"},
+				{"xrandr", "--output", "HDMI1", "--auto", "--right-of", "LVDS1"},
+			},
+		},
+		{
+			true, "HDMI1",
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--auto"},
+				{"xrandr", "--output", "HDMI1", "--auto", "--primary", "--right-of", "LVDS1"},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		rule := Rule{
+			ConfigureRow: []string{"LVDS1", "HDMI1"},
+			Primary:      test.primary,
+		}
+
+		cmds, err := BuildCommandOutputRow(rule, current, Options{AutoPrimary: test.autoPrimary})
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowPrimaryLargest(t *testing.T) {
+	sized := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}, WidthMM: 344, HeightMM: 194},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}, WidthMM: 527, HeightMM: 296},
+	}
+
+	unsized := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	var tests = []struct {
+		current Outputs
+		want    [][]string
+	}{
+		{
+			sized,
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--auto"},
+				{"xrandr", "--output", "HDMI1", "--auto", "--primary", "--right-of", "LVDS1"},
+			},
+		},
+		// the sizes are unknown, the first output is used
+		{
+			unsized,
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--auto", "--primary"},
+				{"xrandr", "--output", "HDMI1", "--auto", "--right-of", "LVDS1"},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		rule := Rule{
+			ConfigureRow: []string{"LVDS1", "HDMI1"},
+		}
+
+		cmds, err := BuildCommandOutputRow(rule, test.current, Options{AutoPrimary: true, PrimaryLargest: true})
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+}
+
+func TestInternalOutput(t *testing.T) {
+	var tests = []struct {
+		outputs  Outputs
+		override string
+		name     string
+		found    bool
+	}{
+		{Outputs{{Name: "DP1"}, {Name: "eDP1"}}, "", "eDP1", true},
+		{Outputs{{Name: "LVDS-1"}, {Name: "VGA-1"}}, "", "LVDS-1", true},
+		{Outputs{{Name: "DP1"}, {Name: "HDMI1"}}, "", "", false},
+		{Outputs{{Name: "DSI-1"}, {Name: "eDP1"}}, "DSI-1", "DSI-1", true},
+		{Outputs{{Name: "DSI-1"}, {Name: "eDP1"}}, "", "eDP1", true},
+		{Outputs{{Name: "DP1"}, {Name: "DSI-1"}}, "", "DSI-1", true},
+		// detected by the physical size
+		{Outputs{
+			{Name: "DP-1", Connected: true, WidthMM: 597, HeightMM: 336},
+			{Name: "Virtual-2", Connected: true, WidthMM: 294, HeightMM: 165},
+			{Name: "HDMI-1", Connected: true, WidthMM: 344, HeightMM: 193},
+		}, "", "Virtual-2", true},
+		// too large, aspect ratios only or disconnected
+		{Outputs{
+			{Name: "DP-1", Connected: true, WidthMM: 597, HeightMM: 336},
+			{Name: "HDMI-1", Connected: true, WidthMM: 16, HeightMM: 9},
+			{Name: "DP-2", WidthMM: 294, HeightMM: 165},
+		}, "", "", false},
+		{Outputs{{Name: "HDMI-1", Connected: true, WidthMM: 294, HeightMM: 165}}, "DP-1", "DP-1", true},
+	}
+
+	for i, test := range tests {
+		name, ok := test.outputs.internalOutputName(test.override)
+		if name != test.name || ok != test.found {
+			t.Errorf("test %d: want (%q, %v), got (%q, %v)", i, test.name, test.found, name, ok)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowDisableInternal(t *testing.T) {
+	current := Outputs{
+		{Name: "DSI-1", Connected: true, Modes: []Mode{{Name: "1200x1920", Default: true, Active: true}}},
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureRow:    []string{"eDP1", "DP1", "DSI-1"},
+		DisableInternal: true,
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "eDP1", "--off"},
+		{"xrandr", "--output", "DP1", "--auto"},
+		{"xrandr", "--output", "DSI-1", "--auto", "--right-of", "DP1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("autodetected internal output: wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+
+	cmds, err = BuildCommandOutputRow(rule, current, Options{InternalOutput: "DSI-1"})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want = [][]string{
+		{"xrandr", "--output", "DSI-1", "--off"},
+		{"xrandr", "--output", "eDP1", "--auto"},
+		{"xrandr", "--output", "DP1", "--auto", "--right-of", "eDP1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("configured internal output: wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestBuildCommandOutputRowSingleExternal(t *testing.T) {
+	rule := Rule{SingleExternal: true}
+
+	var tests = []struct {
+		current Outputs
+		want    [][]string
+	}{
+		{
+			current: NewOutputs([]string{"eDP1"}, []string{"DP1", "HDMI1"}),
+		},
+		{
+			current: Outputs{
+				{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+				{Name: "DP1"},
+				{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true}}},
+			},
+			want: [][]string{
+				{"xrandr", "--output", "eDP1", "--off"},
+				{"xrandr", "--output", "HDMI1", "--auto", "--primary"},
+			},
+		},
+		{
+			current: NewOutputs([]string{"eDP1", "DP1", "HDMI1"}, nil),
+		},
+	}
+
+	for i, test := range tests {
+		cmds, err := BuildCommandOutputRow(rule, test.current, Options{})
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("test %d: expected error, got commands %v", i, testCommandArgs(cmds))
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+
+	rule.ConfigureSingle = "HDMI1"
+	if err := rule.Valid(); err == nil {
+		t.Errorf("rule with single_external and configure_single is valid")
+	}
+}
+
+func TestBuildCommandOutputRowScaleTo(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "3840x2160", Default: true}, {Name: "1920x1080"}}},
+		{Name: "DP2"},
+	}
+
+	var tests = []struct {
+		rule Rule
+		want [][]string
+	}{
+		{
+			Rule{
+				ConfigureRow: []string{"DP1@3840x2160", "eDP1"},
+				ScaleTo:      map[string]string{"DP1": "1920x1080"},
+			},
+			[][]string{
+				{"xrandr", "--output", "DP1", "--mode", "3840x2160", "--scale", "0.5x0.5"},
+				{"xrandr", "--output", "eDP1", "--auto", "--right-of", "DP1"},
+			},
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"eDP1", "DP1"},
+				ScaleTo:      map[string]string{"eDP1": "3840x2160", "DP1": "2880x1620"},
+				Positions:    map[string]string{"eDP1": "0x0", "DP1": "3840x0"},
+			},
+			[][]string{
+				{"xrandr", "--output", "eDP1", "--auto", "--scale", "1.5x1.5", "--pos", "0x0"},
+				{"xrandr", "--output", "DP1", "--auto", "--scale", "0.75x0.75", "--pos", "3840x0"},
+			},
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"DP2", "eDP1"},
+				ScaleTo:      map[string]string{"DP2": "1920x1080"},
+			},
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		cmds, err := BuildCommandOutputRow(test.rule, current, Options{})
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("test %d: expected error, got commands %v", i, testCommandArgs(cmds))
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+
+	// the next output is placed right of the logical resolution
+	targets, err := TargetLayout(tests[0].rule, current, Options{})
+	if err != nil {
+		t.Fatalf("TargetLayout returned error: %v", err)
+	}
+
+	if targets[1].Offset != (Offset{X: 1920}) {
+		t.Errorf("wrong offset of eDP1: want 1920x0, got %v", targets[1].Offset)
+	}
+
+	if err := (Rule{ScaleTo: map[string]string{"DP1": "half"}}).Valid(); err == nil {
+		t.Errorf("rule with invalid scale_to is valid")
+	}
+}
+
+func TestBuildCommandOutputRowLogsLayout(t *testing.T) {
+	defer func(old func(string, ...interface{})) { Logf = old }(Logf)
+
+	var lines []string
+	Logf = func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}, {Name: "1280x1024"}}},
+		{Name: "DP1", Connected: true, Offset: Offset{X: 1366}, Modes: []Mode{{Name: "2560x1440", Default: true, Active: true}}},
+	}
+
+	rule := Rule{ConfigureRow: []string{"HDMI1@1280x1024", "LVDS1", "DP1"}}
+	if _, err := BuildCommandOutputRow(rule, current, Options{}); err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := []string{
+		"current: LVDS1@1366x768+0+0 DP1@2560x1440+1366+0\n",
+		"target: HDMI1@1280x1024+0+0 LVDS1@1366x768+1280+0 DP1@2560x1440+2646+0\n",
+	}
+
+	for _, line := range want {
+		var found bool
+		for _, l := range lines {
+			if l == line {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("line %q not logged, lines: %q", line, lines)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowInlineOptions(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true}, {Name: "1920x1080"}}},
+	}
+
+	var tests = []struct {
+		rule Rule
+		want [][]string
+	}{
+		{
+			Rule{ConfigureSingle: "DP1@1920x1080/left"},
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--off"},
+				{"xrandr", "--output", "DP1", "--mode", "1920x1080", "--rotate", "left"},
+			},
+		},
+		{
+			Rule{ConfigureSingle: "DP1/primary/inverted"},
+			[][]string{
+				{"xrandr", "--output", "LVDS1", "--off"},
+				{"xrandr", "--output", "DP1", "--auto", "--primary", "--rotate", "inverted"},
+			},
+		},
+		{
+			Rule{ConfigureRow: []string{"DP1@1920x1080/right", "LVDS1/primary"}},
+			[][]string{
+				{"xrandr", "--output", "DP1", "--mode", "1920x1080", "--rotate", "right"},
+				{"xrandr", "--output", "LVDS1", "--auto", "--primary", "--right-of", "DP1"},
+			},
+		},
+		{
+			Rule{ConfigureSingle: "DP1@1920x1080/sideways"},
+			nil,
+		},
+		{
+			Rule{ConfigureRow: []string{"DP1/primary", "LVDS1"}, Primary: "LVDS1"},
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		cmds, err := BuildCommandOutputRow(test.rule, current, Options{})
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("test %d: expected error, got commands %v", i, testCommandArgs(cmds))
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+
+	// the rotated output is 1080 pixels wide
+	targets, err := TargetLayout(tests[2].rule, current, Options{})
+	if err != nil {
+		t.Fatalf("TargetLayout returned error: %v", err)
+	}
+
+	if targets[1].Offset != (Offset{X: 1080}) {
+		t.Errorf("wrong offset of LVDS1: want 1080x0, got %v", targets[1].Offset)
+	}
+
+	if err := (Rule{ConfigureSingle: "DP1/sideways"}).Valid(); err == nil {
+		t.Errorf("rule with invalid inline option is valid")
+	}
+}
+
+const testRandrScreens = `Screen 0: minimum 320 x 200, current 1920 x 1080, maximum 16384 x 16384
+DP-1 connected primary 1920x1080+0+0 (normal left inverted right x axis y axis) 527mm x 296mm
+   1920x1080     60.00*+
+HDMI-1 disconnected (normal left inverted right x axis y axis)
+Screen 1: minimum 320 x 200, current 2560 x 1440, maximum 16384 x 16384
+DP-1 connected 2560x1440+0+0 (normal left inverted right x axis y axis) 597mm x 336mm
+   2560x1440     59.95*+
+DP-2 connected (normal left inverted right x axis y axis)
+   1920x1200     59.95 +
+`
+
+func TestRandrParseScreens(t *testing.T) {
+	outputs, err := RandrParse(bytes.NewReader([]byte(testRandrScreens)))
+	if err != nil {
+		t.Fatalf("RandrParse returned error: %v", err)
+	}
+
+	var names []string
+	for _, o := range outputs {
+		names = append(names, fmt.Sprintf("%v/%d", o.Name, o.Screen))
+	}
+
+	want := []string{"screen0:DP-1/0", "HDMI-1/0", "screen1:DP-1/1", "DP-2/1"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("wrong outputs: want %v, got %v", want, names)
+	}
+
+	var tests = []struct {
+		name      string
+		connected bool
+	}{
+		{"screen1:DP-1", true},
+		{"screen0:DP-1", true},
+		{"screen1:DP-*", true},
+		{"DP-2", true},
+		{"screen1:DP-2", true},
+		{"screen0:DP-2", false},
+		// ambiguous
+		{"DP-1", false},
+	}
+
+	for _, test := range tests {
+		if c := outputs.Connected(test.name); c != test.connected {
+			t.Errorf("%v: wrong result for Connected: want %v, got %v", test.name, test.connected, c)
+		}
+	}
+
+	cmds, err := BuildCommandOutputRow(Rule{ConfigureSingle: "screen1:DP-2"}, outputs, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	wantCmds := [][]string{{"xrandr", "--output", "DP-2", "--auto"}}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, wantCmds) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", wantCmds, testCommandArgs(cmds))
+	}
+
+	if _, err = BuildCommandOutputRow(Rule{ConfigureSingle: "screen1:DP-1"}, outputs, Options{}); err == nil {
+		t.Errorf("no error for output with a name used by several screens")
+	}
+}
+
+func TestRandrParseNoGeometry(t *testing.T) {
+	const buf = `Screen 0: minimum 320 x 200, current 1920 x 1080, maximum 8192 x 8192
+eDP1 connected primary 1920x1080 (normal left inverted right x axis y axis) 309mm x 174mm
+   1920x1080     60.02*+
+   1280x720      60.00
+HDMI1 disconnected 1280x1024 (normal left inverted right x axis y axis) 0mm x 0mm
+`
+
+	outputs, err := RandrParse(strings.NewReader(buf))
+	if err != nil {
+		t.Fatalf("RandrParse returned error: %v", err)
+	}
+
+	for _, test := range []struct {
+		name, mode string
+	}{
+		{"eDP1", "1920x1080"},
+		{"HDMI1", "1280x1024"},
+	} {
+		o, ok := outputs.Get(test.name)
+		if !ok {
+			t.Errorf("output %v not found", test.name)
+			continue
+		}
+
+		m, ok := o.ActiveMode()
+		if !ok || m.Name != test.mode {
+			t.Errorf("output %v: wrong active mode, want %v, got %v", test.name, test.mode, m.Name)
+		}
+
+		if o.Rotation != "normal" {
+			t.Errorf("output %v: wrong rotation %q", test.name, o.Rotation)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowManages(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1280x1024", Default: true, Active: true}}},
+	}
+
+	rule := Rule{
+		ConfigureSingle: "DP1",
+		Manages:         []string{"DP1", "eDP*"},
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "eDP1", "--off"},
+		{"xrandr", "--output", "DP1", "--auto"},
+	}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestOutputsMirrored(t *testing.T) {
+	outputs := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Active: true}}, Offset: Offset{X: 1920}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Active: true}}},
+		{Name: "DP2", Connected: true, Modes: []Mode{{Name: "1920x1080", Active: true}}, Offset: Offset{X: 1920}},
+		{Name: "VGA1", Connected: true, Modes: []Mode{{Name: "1024x768"}}},
+	}
+
+	want := [][]string{{"eDP1", "HDMI1"}, {"DP1", "DP2"}}
+	if got := outputs.Mirrored(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong mirrored outputs: want %v, got %v", want, got)
+	}
+
+	if got := outputs[:2].Mirrored(); got != nil {
+		t.Errorf("expected no mirrored outputs, got %v", got)
+	}
+}
+
+func TestBuildCommandOutputRowXrandrExtraArgs(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureSingle: "DP1",
+		ResetBefore:     true,
+	}
+	opts := Options{XrandrExtraArgs: []string{"--nograb"}}
+
+	cmds, err := BuildCommandOutputRow(rule, current, opts)
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--nograb", "--auto"},
+		{"xrandr", "--nograb", "--output", "eDP1", "--off"},
+		{"xrandr", "--nograb", "--output", "DP1", "--auto"},
+	}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+
+	rule.Atomic = true
+	cmds, err = BuildCommandOutputRow(rule, current, opts)
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want = [][]string{
+		{"xrandr", "--nograb", "--auto"},
+		{"xrandr", "--nograb", "--output", "eDP1", "--off", "--output", "DP1", "--auto"},
+	}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong atomic commands:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestBuildCommandOutputRowDisableReverse(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: Offset{X: 1920}},
+		{Name: "DP2", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: Offset{X: 3840}},
+		{Name: "DP3", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: Offset{X: 5760}},
+	}
+
+	rule := Rule{
+		ConfigureSingle: "eDP1",
+		DisableReverse:  true,
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "DP3", "--off"},
+		{"xrandr", "--output", "DP2", "--off", "--output", "eDP1", "--auto"},
+		{"xrandr", "--output", "DP1", "--off"},
+	}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+
+	// outputs in disable_order go first
+	rule.DisableOrder = []string{"DP1"}
+	cmds, err = BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want = [][]string{
+		{"xrandr", "--output", "DP1", "--off"},
+		{"xrandr", "--output", "DP3", "--off", "--output", "eDP1", "--auto"},
+		{"xrandr", "--output", "DP2", "--off"},
+	}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands with disable_order:\n  want %v\n  got  %v", want, got)
+	}
+}
+
+func TestBuildCommandOff(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: Offset{X: 1920}},
+		{Name: "DP2", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}, Offset: Offset{X: 3840}},
+		{Name: "DP3"},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}}},
+	}
+
+	var tests = []struct {
+		pattern string
+		want    [][]string
+	}{
+		{
+			"DP*",
+			[][]string{
+				{"xrandr", "--output", "DP2", "--off"},
+				{"xrandr", "--output", "DP1", "--off"},
+			},
+		},
+		{
+			"HDMI1",
+			[][]string{
+				{"xrandr", "--output", "HDMI1", "--off"},
+			},
+		},
+		// nothing connected matches
+		{"VGA*", nil},
+		// all active outputs would be turned off
+		{"*", nil},
+		{"[", nil},
+	}
+
+	for i, test := range tests {
+		cmds, err := BuildCommandOff(test.pattern, current, Options{})
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("test %d: expected error, got commands %v", i, testCommandArgs(cmds))
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOff returned error: %v", i, err)
+			continue
+		}
+
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowNormalizeMode(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{
+			{Name: "2560x1440", Default: true, Refresh: []float64{59.95}},
+			{Name: "1920x1080", Refresh: []float64{60, 50}},
+		}},
+	}
+
+	var tests = []struct {
+		entry string
+		want  []string
+	}{
+		{"DP1@ 1920x1080 ", []string{"xrandr", "--output", "DP1", "--mode", "1920x1080"}},
+		{"DP1@1920X1080", []string{"xrandr", "--output", "DP1", "--mode", "1920x1080"}},
+		{"DP1@1920 X 1080@ 50", []string{"xrandr", "--output", "DP1", "--mode", "1920x1080", "--rate", "50.00"}},
+		{"DP1@2560X", []string{"xrandr", "--output", "DP1", "--mode", "2560x1440"}},
+	}
+
+	for i, test := range tests {
+		rule := Rule{ConfigureSingle: test.entry}
+		cmds, err := BuildCommandOutputRow(rule, current, Options{})
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		got := testCommandArgs(cmds)
+		if !reflect.DeepEqual(got[len(got)-1], test.want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, test.want, got)
+		}
+	}
+
+	if !current.SupportsMode("DP1", "2560X1440 ") {
+		t.Errorf("SupportsMode does not normalize the mode")
+	}
+}
+
+func TestBuildCommandOutputRowFramebuffer(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "3840x2160", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"eDP1", "DP1"},
+		Framebuffer:  "5760x2160",
+		ResetBefore:  true,
+	}
+
+	if err := rule.Valid(); err != nil {
+		t.Fatalf("rule is invalid: %v", err)
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--fb", "5760x2160"},
+		{"xrandr", "--auto"},
+		{"xrandr", "--output", "eDP1", "--auto"},
+		{"xrandr", "--output", "DP1", "--auto", "--right-of", "eDP1"},
+	}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+
+	for _, fb := range []string{"5760", "5760x", "0x2160", "5760*2160", "-1x2160"} {
+		if err := (Rule{Framebuffer: fb}).Valid(); err == nil {
+			t.Errorf("invalid framebuffer %q accepted", fb)
+		}
+	}
+}
+
+func TestBuildCommandOutputRowPrimaryFallback(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "DP1", Connected: true, Modes: []Mode{{Name: "2560x1440", Default: true}}},
+		{Name: "HDMI1"},
+	}
+
+	var tests = []struct {
+		primary string
+		row     []string
+		want    string
+	}{
+		// first choice
+		{"DP1, eDP1", []string{"eDP1", "DP1"}, "DP1"},
+		// HDMI1 is not connected
+		{"HDMI1,DP*,eDP1", []string{"eDP1", "DP1", "HDMI1"}, "DP1"},
+		// DP1 is not part of the row
+		{"DP1,eDP1", []string{"eDP1"}, "eDP1"},
+		// none of the candidates is available
+		{"HDMI1,DP2", []string{"eDP1", "DP1", "HDMI1"}, ""},
+	}
+
+	for i, test := range tests {
+		rule := Rule{ConfigureRow: test.row, Primary: test.primary}
+		if err := rule.Valid(); err != nil {
+			t.Errorf("test %d: rule is invalid: %v", i, err)
+			continue
+		}
+
+		targets, err := TargetLayout(rule, current, Options{})
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("test %d: expected error, got %v", i, FormatLayout(targets))
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: TargetLayout returned error: %v", i, err)
+			continue
+		}
+
+		var primary []string
+		for _, target := range targets {
+			if target.Primary {
+				primary = append(primary, target.Name)
+			}
+		}
+
+		if !reflect.DeepEqual(primary, []string{test.want}) {
+			t.Errorf("test %d: wrong primary output: want %v, got %v", i, test.want, primary)
+		}
+	}
+}
+
+func TestVerifyLayoutIgnored(t *testing.T) {
+	outputs := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "VGA1", Connected: true, Modes: []Mode{{Name: "1024x768", Default: true, Active: true}}, Rotation: "normal"},
+		{Name: "screen1:DP-1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}, Rotation: "normal"},
+	}
+
+	targets := []OutputTarget{{Name: "LVDS1", Rotation: "normal"}}
+
+	if diffs := VerifyLayout(Rule{}, targets, outputs, Options{}); len(diffs) != 1 {
+		t.Errorf("want one difference for VGA1, got %v", diffs)
+	}
+
+	if diffs := VerifyLayout(Rule{}, targets, outputs, Options{PinOutputs: map[string]string{"VGA1": pinKeep}}); len(diffs) != 0 {
+		t.Errorf("pinned output reported: %v", diffs)
+	}
+
+	if diffs := VerifyLayout(Rule{Manages: []string{"LVDS1"}}, targets, outputs, Options{}); len(diffs) != 0 {
+		t.Errorf("unmanaged output reported: %v", diffs)
+	}
+}
@@ -0,0 +1,134 @@
+package randr
+
+import (
+	"math"
+	"strings"
+)
+
+// renderBox is an output drawn by RenderLayout, the coordinates are the
+// characters of the corners.
+type renderBox struct {
+	name, mode     string
+	x0, y0, x1, y1 int
+}
+
+// RenderLayout draws the active outputs as boxes of ASCII characters at their
+// positions, scaled so that the drawing is at most cols characters wide. The
+// height is scaled by half, because characters are about twice as high as
+// they are wide. Each box shows the name and the mode of the output if there
+// is enough room. Outputs whose mode has no size in its name are left out. It
+// returns nil if no output can be drawn.
+func RenderLayout(outputs Outputs, cols int) []string {
+	type rect struct {
+		name, mode string
+		x, y, w, h int
+	}
+
+	var (
+		rects      []rect
+		maxX, maxY int
+	)
+	for _, o := range outputs {
+		mode, ok := o.ActiveMode()
+		if !ok {
+			continue
+		}
+
+		w, h := mode.Width(), mode.Height()
+		if w == 0 || h == 0 {
+			continue
+		}
+
+		if o.Rotation == "left" || o.Rotation == "right" {
+			w, h = h, w
+		}
+
+		rects = append(rects, rect{o.Name, mode.Name, o.Offset.X, o.Offset.Y, w, h})
+		if o.Offset.X+w > maxX {
+			maxX = o.Offset.X + w
+		}
+		if o.Offset.Y+h > maxY {
+			maxY = o.Offset.Y + h
+		}
+	}
+
+	if len(rects) == 0 || cols < 3 {
+		return nil
+	}
+
+	scale := float64(cols-1) / float64(maxX)
+	round := func(v int, s float64) int {
+		return int(math.Floor(float64(v)*s + 0.5))
+	}
+
+	var boxes []renderBox
+	var width, height int
+	for _, r := range rects {
+		b := renderBox{
+			name: r.name,
+			mode: r.mode,
+			x0:   round(r.x, scale),
+			y0:   round(r.y, scale/2),
+			x1:   round(r.x+r.w, scale),
+			y1:   round(r.y+r.h, scale/2),
+		}
+
+		// very small outputs are drawn at least as an empty box
+		if b.x1 < b.x0+2 {
+			b.x1 = b.x0 + 2
+		}
+		if b.y1 < b.y0+2 {
+			b.y1 = b.y0 + 2
+		}
+
+		if b.x1+1 > width {
+			width = b.x1 + 1
+		}
+		if b.y1+1 > height {
+			height = b.y1 + 1
+		}
+		boxes = append(boxes, b)
+	}
+
+	grid := make([][]byte, height)
+	for y := range grid {
+		grid[y] = []byte(strings.Repeat(" ", width))
+	}
+
+	for _, b := range boxes {
+		for y := b.y0; y <= b.y1; y++ {
+			for x := b.x0; x <= b.x1; x++ {
+				c := byte(' ')
+				switch {
+				case (y == b.y0 || y == b.y1) && (x == b.x0 || x == b.x1):
+					c = '+'
+				case y == b.y0 || y == b.y1:
+					c = '-'
+				case x == b.x0 || x == b.x1:
+					c = '|'
+				}
+				grid[y][x] = c
+			}
+		}
+
+		// the labels are cut off at the right border of the box
+		room := b.x1 - b.x0 - 1
+		for i, label := range []string{b.name, b.mode} {
+			y := b.y0 + 1 + i
+			if y >= b.y1 {
+				break
+			}
+			if len(label) > room {
+				label = label[:room]
+			}
+			copy(grid[y][b.x0+1:], label)
+		}
+	}
+
+	lines := make([]string, 0, len(grid))
+	for _, row := range grid {
+		lines = append(lines, strings.TrimRight(string(row), " "))
+	}
+
+	return lines
+}
@@ -0,0 +1,31 @@
+package randr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderLayout(t *testing.T) {
+	outputs := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Active: true}}, Rotation: "normal"},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Active: true}}, Offset: Offset{X: 1366}, Rotation: "normal"},
+		{Name: "VGA1", Connected: true, Modes: []Mode{{Name: "1024x768"}}},
+	}
+
+	got := strings.Join(RenderLayout(outputs, 33), "\n")
+	want := strings.Join([]string{
+		"+------------+------------------+",
+		"|LVDS1       |HDMI1             |",
+		"|1366x768    |1920x1080         |",
+		"|            |                  |",
+		"+------------|                  |",
+		"             +------------------+",
+	}, "\n")
+	if got != want {
+		t.Errorf("wrong layout, want:\n%s\ngot:\n%s", want, got)
+	}
+
+	if lines := RenderLayout(Outputs{{Name: "VGA1", Connected: true}}, 33); lines != nil {
+		t.Errorf("layout drawn without active outputs: %q", lines)
+	}
+}
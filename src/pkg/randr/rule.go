@@ -0,0 +1,729 @@
+package randr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rule is a rule to configure outputs.
+type Rule struct {
+	Name string
+
+	OutputsConnected    []string `yaml:"outputs_connected"`
+	OutputsDisconnected []string `yaml:"outputs_disconnected"`
+	OutputsPresent      []string `yaml:"outputs_present"`
+	OutputsAbsent       []string `yaml:"outputs_absent"`
+
+	// OutputsPresentDisconnected matches outputs which are listed by xrandr
+	// but have nothing connected, unlike OutputsDisconnected it does not
+	// match absent outputs.
+	OutputsPresentDisconnected []string `yaml:"outputs_present_disconnected"`
+
+	// SupportsMode requires connected outputs to support a mode, e.g.
+	// {DP-1: 3840x2160}, regardless of the currently active mode.
+	SupportsMode map[string]string `yaml:"supports_mode"`
+
+	// AnySupportsMode requires at least one connected output to support the
+	// mode, e.g. 3840x2160, whichever output it is.
+	AnySupportsMode string `yaml:"any_supports_mode"`
+
+	// MinModes requires connected outputs to list at least the given number
+	// of modes, e.g. {DP-1: 3}, which tells misdetected monitors apart.
+	MinModes map[string]int `yaml:"min_modes"`
+
+	// Vendor requires connected outputs to report a monitor by a
+	// manufacturer in their EDID, e.g. {DP-1: DEL} for Dell.
+	Vendor map[string]string `yaml:"vendor"`
+
+	// ConnectedSerial requires a connected output to report a monitor
+	// whose serial number from the EDID matches the pattern, e.g. "CN0*".
+	ConnectedSerial string `yaml:"connected_serial"`
+
+	// ConnectedType requires an output with the connector type to be
+	// connected, one of "DisplayPort", "HDMI", "Internal", "VGA" or "DVI".
+	// The type is derived from the name of the output.
+	ConnectedType string `yaml:"connected_type"`
+
+	// Mirrored requires active outputs to be mirrored (true), i.e. to share
+	// a position, or no outputs to be mirrored (false).
+	Mirrored *bool `yaml:"mirrored"`
+
+	// ActiveBetween restricts the rule to a daily time window in local time,
+	// e.g. "22:00-06:00".
+	ActiveBetween string `yaml:"active_between"`
+
+	// Priority decides which rule is applied if several rules match, the
+	// rule with the highest priority wins. Rules with the same priority are
+	// tried in the order of the config.
+	Priority int `yaml:"priority"`
+
+	// Power restricts the rule to a power state, "ac" or "battery".
+	Power string `yaml:"power"`
+
+	// UnlessProcess restricts the rule to times when no process with the
+	// name is running, e.g. a game which would not survive a mode change.
+	UnlessProcess string `yaml:"unless_process"`
+
+	// Hostname restricts the rule to machines whose host name matches the
+	// name or pattern, e.g. "laptop-*", so that one config can be shared.
+	Hostname string `yaml:"hostname"`
+
+	ConfigureRow     []string `yaml:"configure_row"`
+	ConfigureSingle  string   `yaml:"configure_single"`
+	ConfigureCommand string   `yaml:"configure_command"`
+
+	// SingleExternal configures the only connected output which is not the
+	// internal panel of a laptop as the primary output and disables all
+	// others. Applying the rule fails if no or several externals are
+	// connected.
+	SingleExternal bool `yaml:"single_external"`
+
+	// Positions places outputs at absolute positions (e.g. "1920x0")
+	// instead of right of the previous output in the row. Either all outputs
+	// of the row or only the first one may have a position.
+	Positions map[string]string `yaml:"positions"`
+
+	// NormalizeOrigin shifts the positions of the outputs so that the
+	// leftmost and the topmost output are at 0,0, e.g. when Positions
+	// contains negative offsets.
+	NormalizeOrigin bool `yaml:"normalize_origin"`
+
+	// Rotate sets the rotation of outputs: normal, left, right or inverted.
+	Rotate map[string]string `yaml:"rotate"`
+
+	// Transform sets the transformation matrix of outputs as nine comma
+	// separated numbers (xrandr --transform), e.g. for keystone correction
+	// of a projector, or "none". It cannot be combined with ScaleTo or a
+	// rotation of the same output.
+	Transform map[string]string `yaml:"transform"`
+
+	// ScaleTo scales outputs so that their mode covers a logical resolution,
+	// e.g. {DP1: 1920x1080} for a monitor using 3840x2160. The mode of the
+	// output must be known to compute the scale factor.
+	ScaleTo map[string]string `yaml:"scale_to"`
+
+	// Primary is the name of the output to make the primary output, it must
+	// be part of ConfigureSingle or ConfigureRow. It may be a pattern, then
+	// the first connected output of the row matching it is used. Several
+	// candidates separated by commas are tried in order.
+	Primary string `yaml:"primary"`
+
+	DisableOrder []string `yaml:"disable_order"`
+
+	// DisableReverse turns off the outputs which are not part of the rule
+	// from right to left according to their current position, outputs in
+	// DisableOrder are turned off first.
+	DisableReverse bool `yaml:"disable_reverse"`
+
+	// Manages limits the outputs the rule turns off to the listed names or
+	// patterns, other outputs are left alone even if the rule does not
+	// configure them. All outputs are managed if the list is empty.
+	Manages []string `yaml:"manages"`
+
+	// DisableInternal turns off the internal panel of a laptop, even if it
+	// is part of ConfigureRow.
+	DisableInternal bool `yaml:"disable_internal"`
+
+	// CRTC assigns a CRTC to an output by number. Assigning a CRTC the
+	// output cannot use or one already in use causes xrandr to fail.
+	CRTC map[string]int `yaml:"crtc"`
+
+	// Gamma sets the gamma correction of outputs, either as "R:G:B" or as
+	// the name of a preset like "warm", "cool" or "night".
+	Gamma map[string]string `yaml:"gamma"`
+
+	// TearFree sets the TearFree property of outputs (supported by the
+	// amdgpu, radeon and intel drivers) on or off.
+	TearFree map[string]bool `yaml:"tear_free"`
+
+	// Underscan sets the underscan border of outputs for TVs which overscan,
+	// either as a percentage of the mode size ("5%"), as horizontal and
+	// vertical border in pixels ("16,16") or "off".
+	Underscan map[string]string `yaml:"underscan"`
+
+	// NewModes lists modelines (e.g. printed by cvt) by output name. The
+	// modes are created with "xrandr --newmode" and added to the output
+	// with "xrandr --addmode" before the outputs are configured, unless
+	// the output already lists them.
+	NewModes map[string][]string `yaml:"new_modes"`
+
+	Atomic bool `yaml:"atomic"`
+
+	// SplitMode selects how the outputs are configured with xrandr:
+	// "atomic" uses one call (like Atomic), "interleaved" (the default)
+	// disables one output and enables another per call and "grouped" uses
+	// one call to disable outputs and another one to enable outputs.
+	SplitMode string `yaml:"split_mode"`
+
+	// ResetBefore runs "xrandr --auto" before configuring the outputs.
+	ResetBefore bool `yaml:"reset_before"`
+
+	// Framebuffer sets the size of the framebuffer as WxH with "xrandr --fb"
+	// before the outputs are configured. xrandr usually computes the size
+	// itself, it is only needed for some setups with scaled outputs.
+	Framebuffer string `yaml:"framebuffer"`
+
+	// EnableFirst enables outputs before disabling others when the outputs
+	// are configured with several calls to xrandr.
+	EnableFirst bool `yaml:"enable_first"`
+
+	// MinimalChanges skips outputs which are already configured as
+	// requested and outputs to be disabled which are already off.
+	MinimalChanges bool `yaml:"minimal_changes"`
+
+	ExecuteAfter []string `yaml:"execute_after"`
+
+	// Async runs the ExecuteAfter hooks (and the global ones) in the
+	// background when the rule is applied instead of waiting for them, their
+	// failures are only printed.
+	Async bool `yaml:"async"`
+
+	// ExecuteOnConnect lists commands to run in watch mode when an output
+	// matching the pattern (the key) changed from disconnected to connected.
+	ExecuteOnConnect map[string][]string `yaml:"execute_on_connect"`
+
+	// Cooldown is the minimal duration between two applications of the rule
+	// in watch mode, it prevents flapping outputs from switching rules over
+	// and over again.
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// ExpandEnv replaces ${var} or $var according to the values of the current
+// environment variables in the output names of the rule (the match lists,
+// ConfigureRow including modes and options, ConfigureSingle, Primary,
+// DisableOrder, Manages and the keys of the per-output settings) and in the
+// values of the per-output settings which are strings, e.g. Positions or
+// Gamma. Hooks and ConfigureCommand are left alone, they are run by a shell
+// which expands the variables when they run.
+func (r *Rule) ExpandEnv() {
+	for _, list := range [][]string{
+		r.OutputsConnected,
+		r.OutputsDisconnected,
+		r.OutputsPresent,
+		r.OutputsAbsent,
+		r.OutputsPresentDisconnected,
+		r.ConfigureRow,
+		r.DisableOrder,
+		r.Manages,
+	} {
+		for i := range list {
+			list[i] = os.ExpandEnv(list[i])
+		}
+	}
+
+	r.ConfigureSingle = os.ExpandEnv(r.ConfigureSingle)
+	r.Primary = os.ExpandEnv(r.Primary)
+
+	for _, m := range []map[string]string{
+		r.SupportsMode,
+		r.Vendor,
+		r.Positions,
+		r.Rotate,
+		r.Transform,
+		r.ScaleTo,
+		r.Gamma,
+		r.Underscan,
+	} {
+		for name, value := range m {
+			m[name] = os.ExpandEnv(value)
+		}
+	}
+
+	for _, m := range r.outputMaps() {
+		ExpandEnvKeys(m)
+	}
+}
+
+// outputMaps returns the settings of the rule which are maps from output
+// names to values.
+func (r *Rule) outputMaps() []interface{} {
+	return []interface{}{
+		r.SupportsMode,
+		r.Vendor,
+		r.MinModes,
+		r.Positions,
+		r.Rotate,
+		r.Transform,
+		r.ScaleTo,
+		r.CRTC,
+		r.Gamma,
+		r.TearFree,
+		r.Underscan,
+		r.NewModes,
+		r.ExecuteOnConnect,
+	}
+}
+
+// RenameOutputs replaces the output names of the rule which are keys of
+// aliases by the corresponding values, e.g. after the driver renamed "DP1" to
+// "DP-1". Only names which match a key exactly are replaced, a mode following
+// the name in ConfigureRow and ConfigureSingle is kept, as are inline options.
+func (r *Rule) RenameOutputs(aliases map[string]string) {
+	if len(aliases) == 0 {
+		return
+	}
+
+	rename := func(name string) string {
+		if alias, ok := aliases[name]; ok {
+			return alias
+		}
+		return name
+	}
+
+	renameEntry := func(entry string) string {
+		i := strings.IndexAny(entry, "@/")
+		if i < 0 {
+			return rename(entry)
+		}
+		return rename(entry[:i]) + entry[i:]
+	}
+
+	for _, list := range [][]string{
+		r.OutputsConnected,
+		r.OutputsDisconnected,
+		r.OutputsPresent,
+		r.OutputsAbsent,
+		r.OutputsPresentDisconnected,
+		r.DisableOrder,
+		r.Manages,
+	} {
+		for i := range list {
+			list[i] = rename(list[i])
+		}
+	}
+
+	for i := range r.ConfigureRow {
+		r.ConfigureRow[i] = renameEntry(r.ConfigureRow[i])
+	}
+
+	if r.ConfigureSingle != "" {
+		r.ConfigureSingle = renameEntry(r.ConfigureSingle)
+	}
+	candidates := r.PrimaryCandidates()
+	for i := range candidates {
+		candidates[i] = rename(candidates[i])
+	}
+	r.Primary = strings.Join(candidates, ",")
+
+	for _, m := range r.outputMaps() {
+		RenameKeys(m, aliases)
+	}
+}
+
+// RenameKeys replaces the keys of m, which must be a map with string keys,
+// which are keys of aliases by the corresponding values. Each key is renamed
+// once, so chained aliases like "DP1" to "DP-1" and "DP-1" to "DP-1-1" do not
+// move a value twice. If several keys end up with the same name, the one
+// which was not renamed wins, otherwise the first renamed key in sorted order.
+func RenameKeys(m interface{}, aliases map[string]string) {
+	if len(aliases) == 0 {
+		return
+	}
+
+	replaceKeys(m, func(name string) string {
+		if alias, ok := aliases[name]; ok {
+			return alias
+		}
+		return name
+	})
+}
+
+// ExpandEnvKeys replaces ${var} or $var in the keys of m, which must be a map
+// with string keys, according to the values of the current environment
+// variables. Keys which end up with the same name are resolved like in
+// RenameKeys.
+func ExpandEnvKeys(m interface{}) {
+	replaceKeys(m, os.ExpandEnv)
+}
+
+// replaceKeys replaces the keys of m, which must be a map with string keys,
+// by the result of rename, see RenameKeys.
+func replaceKeys(m interface{}, rename func(string) string) {
+	v := reflect.ValueOf(m)
+	if v.Len() == 0 {
+		return
+	}
+
+	var keys, renamed []string
+	values := make(map[string]reflect.Value, v.Len())
+	for _, key := range v.MapKeys() {
+		name := key.String()
+		values[name] = v.MapIndex(key)
+		if rename(name) == name {
+			keys = append(keys, name)
+		} else {
+			renamed = append(renamed, name)
+		}
+		v.SetMapIndex(key, reflect.Value{})
+	}
+	sort.Strings(keys)
+	sort.Strings(renamed)
+
+	set := make(map[string]bool, len(values))
+	for _, name := range append(keys, renamed...) {
+		newName := rename(name)
+		if set[newName] {
+			continue
+		}
+		set[newName] = true
+		v.SetMapIndex(reflect.ValueOf(newName), values[name])
+	}
+}
+
+// NeedsEDID returns true if the rule has conditions on the monitors which are
+// read from their EDID, which xrandr only prints with --props.
+func (r Rule) NeedsEDID() bool {
+	return len(r.Vendor) > 0 || r.ConnectedSerial != ""
+}
+
+// Dynamic returns true if the rule has conditions which do not depend on the
+// outputs, e.g. active_between, so that it may start or stop matching while
+// the outputs stay the same.
+func (r Rule) Dynamic() bool {
+	return r.ActiveBetween != "" || r.Power != "" || r.UnlessProcess != "" || r.Hostname != ""
+}
+
+// Managed returns true iff the rule may turn off the output o, i.e. Manages is
+// empty or o matches one of its patterns.
+func (r Rule) Managed(o Output) bool {
+	if len(r.Manages) == 0 {
+		return true
+	}
+
+	for _, pat := range r.Manages {
+		if m, _ := o.MatchName(pat); m {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrimaryCandidates returns the names or patterns of the outputs listed in
+// Primary, separated by commas.
+func (r Rule) PrimaryCandidates() []string {
+	var candidates []string
+	for _, name := range strings.Split(r.Primary, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates
+}
+
+// Valid returns an error if the rule is invalid, e.g. a pattern is malformed
+// or an output is rotated or positioned with an invalid value.
+func (r Rule) Valid() error {
+	for _, list := range [][]string{r.OutputsPresent, r.OutputsAbsent, r.OutputsConnected, r.OutputsDisconnected, r.OutputsPresentDisconnected, r.Manages} {
+		for _, pat := range list {
+			if _, err := path.Match(pat, ""); err != nil {
+				return fmt.Errorf("pattern %q malformed: %v", pat, err)
+			}
+		}
+	}
+
+	for pat := range r.SupportsMode {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("pattern %q malformed: %v", pat, err)
+		}
+	}
+
+	for pat := range r.Vendor {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("pattern %q malformed: %v", pat, err)
+		}
+	}
+
+	for pat, n := range r.MinModes {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("pattern %q malformed: %v", pat, err)
+		}
+
+		if n < 0 {
+			return fmt.Errorf("invalid number of modes %d for output %v", n, pat)
+		}
+	}
+
+	for pat := range r.ExecuteOnConnect {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("pattern %q malformed: %v", pat, err)
+		}
+	}
+
+	if r.SingleExternal && (r.ConfigureSingle != "" || len(r.ConfigureRow) > 0 || r.ConfigureCommand != "") {
+		return errors.New("single_external cannot be combined with configure_row, configure_single or configure_command")
+	}
+
+	if r.ActiveBetween != "" {
+		if _, err := parseTimeWindow(r.ActiveBetween); err != nil {
+			return err
+		}
+	}
+
+	for _, pat := range r.PrimaryCandidates() {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("pattern %q malformed: %v", pat, err)
+		}
+	}
+
+	if r.Framebuffer != "" && !validFramebuffer(r.Framebuffer) {
+		return fmt.Errorf("framebuffer %q is not of the form WxH", r.Framebuffer)
+	}
+
+	for name, lines := range r.NewModes {
+		for _, line := range lines {
+			if _, _, err := parseModeline(line); err != nil {
+				return fmt.Errorf("output %v: %v", name, err)
+			}
+		}
+	}
+
+	for name, spec := range r.Underscan {
+		if err := validUnderscan(spec); err != nil {
+			return fmt.Errorf("output %v: %v", name, err)
+		}
+	}
+
+	if _, err := path.Match(r.ConnectedSerial, ""); err != nil {
+		return fmt.Errorf("pattern %q malformed: %v", r.ConnectedSerial, err)
+	}
+
+	if _, err := path.Match(r.Hostname, ""); err != nil {
+		return fmt.Errorf("pattern %q malformed: %v", r.Hostname, err)
+	}
+
+	if r.ConnectedType != "" && !validConnectorType(r.ConnectedType) {
+		return fmt.Errorf("unknown connector type %q", r.ConnectedType)
+	}
+
+	switch r.SplitMode {
+	case "", splitAtomic, splitInterleaved, splitGrouped:
+	default:
+		return fmt.Errorf("invalid split mode %q, must be %q, %q or %q", r.SplitMode, splitAtomic, splitInterleaved, splitGrouped)
+	}
+
+	switch r.Power {
+	case "", powerAC, powerBattery:
+	default:
+		return fmt.Errorf("invalid power state %q, must be %q or %q", r.Power, powerAC, powerBattery)
+	}
+
+	for _, entry := range append([]string{r.ConfigureSingle}, r.ConfigureRow...) {
+		if err := validEntryOptions(entry); err != nil {
+			return err
+		}
+	}
+
+	for name, rot := range r.Rotate {
+		if !validRotation(rot) {
+			return fmt.Errorf("invalid rotation %q for output %v", rot, name)
+		}
+	}
+
+	for name, pos := range r.Positions {
+		if _, err := parsePosition(pos); err != nil {
+			return fmt.Errorf("output %v: %v", name, err)
+		}
+	}
+
+	for name, transform := range r.Transform {
+		if err := validTransform(transform); err != nil {
+			return fmt.Errorf("output %v: %v", name, err)
+		}
+	}
+
+	for name, res := range r.ScaleTo {
+		if _, _, err := parseResolution(res); err != nil {
+			return fmt.Errorf("output %v: %v", name, err)
+		}
+	}
+
+	for name, crtc := range r.CRTC {
+		if crtc < 0 {
+			return fmt.Errorf("invalid crtc %d for output %v", crtc, name)
+		}
+	}
+
+	return nil
+}
+
+// validFramebuffer returns true iff s is a framebuffer size of the form WxH
+// with positive width and height.
+func validFramebuffer(s string) bool {
+	m := Mode{Name: s}
+	return isModeSize(s) && m.Width() > 0 && m.Height() > 0
+}
+
+// timeNow returns the current time, it is replaced in tests.
+var timeNow = time.Now
+
+// hostname returns the host name of the machine, it is replaced in tests.
+var hostname = os.Hostname
+
+// matchHostname returns true iff the host name of the machine matches the
+// pattern. If the host name cannot be determined, no pattern matches.
+func matchHostname(pattern string) bool {
+	name, err := hostname()
+	if err != nil {
+		Logf("unable to get the host name: %v\n", err)
+		return false
+	}
+
+	m, _ := path.Match(pattern, name)
+	return m
+}
+
+// timeWindow is a daily time window, start and end are minutes since
+// midnight. If end is before start, the window wraps around midnight.
+type timeWindow struct {
+	start, end int
+}
+
+// parseTimeWindow parses a time window of the form "HH:MM-HH:MM".
+func parseTimeWindow(s string) (timeWindow, error) {
+	data := strings.Split(s, "-")
+	if len(data) != 2 {
+		return timeWindow{}, fmt.Errorf("time window %q is not of the form HH:MM-HH:MM", s)
+	}
+
+	var minutes [2]int
+	for i, str := range data {
+		t, err := time.Parse("15:04", strings.TrimSpace(str))
+		if err != nil {
+			return timeWindow{}, fmt.Errorf("time window %q: invalid time %q", s, str)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+
+	if minutes[0] == minutes[1] {
+		return timeWindow{}, fmt.Errorf("time window %q is empty", s)
+	}
+
+	return timeWindow{start: minutes[0], end: minutes[1]}, nil
+}
+
+// contains returns true iff the time of day of t is within the window.
+func (w timeWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+
+	return m >= w.start || m < w.end
+}
+
+// Match returns true iff the rule matches for the given list of outputs.
+func (r Rule) Match(outputs Outputs) bool {
+	for _, c := range r.Conditions(outputs) {
+		if !c.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Condition is a condition of a rule and whether it is satisfied.
+type Condition struct {
+	// Name describes the condition like in the config, e.g.
+	// "outputs_connected HDMI1".
+	Name   string
+	Passed bool
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Conditions evaluates all conditions of the rule against outputs, in the
+// order Match checks them. A rule without conditions matches all outputs.
+func (r Rule) Conditions(outputs Outputs) []Condition {
+	var conds []Condition
+	add := func(passed bool, format string, args ...interface{}) {
+		conds = append(conds, Condition{Name: fmt.Sprintf(format, args...), Passed: passed})
+	}
+
+	if r.ActiveBetween != "" {
+		w, err := parseTimeWindow(r.ActiveBetween)
+		add(err == nil && w.contains(timeNow()), "active_between %v", r.ActiveBetween)
+	}
+
+	if r.Power != "" {
+		add((r.Power == powerAC) == onAC(), "power %v", r.Power)
+	}
+
+	if r.Hostname != "" {
+		add(matchHostname(r.Hostname), "hostname %v", r.Hostname)
+	}
+
+	if r.UnlessProcess != "" {
+		add(!processRunning(r.UnlessProcess), "unless_process %v", r.UnlessProcess)
+	}
+
+	for _, name := range r.OutputsAbsent {
+		add(!outputs.Present(name), "outputs_absent %v", name)
+	}
+
+	for _, name := range r.OutputsDisconnected {
+		add(!outputs.Connected(name), "outputs_disconnected %v", name)
+	}
+
+	for _, name := range sortedKeys(r.SupportsMode) {
+		add(outputs.SupportsMode(name, r.SupportsMode[name]), "supports_mode %v: %v", name, r.SupportsMode[name])
+	}
+
+	if r.AnySupportsMode != "" {
+		add(outputs.SupportsMode("*", r.AnySupportsMode), "any_supports_mode %v", r.AnySupportsMode)
+	}
+
+	for _, name := range sortedKeys(r.Vendor) {
+		add(outputs.HasVendor(name, r.Vendor[name]), "vendor %v: %v", name, r.Vendor[name])
+	}
+
+	if r.ConnectedSerial != "" {
+		add(outputs.ConnectedSerial(r.ConnectedSerial), "connected_serial %v", r.ConnectedSerial)
+	}
+
+	if r.ConnectedType != "" {
+		add(outputs.ConnectedType(r.ConnectedType), "connected_type %v", r.ConnectedType)
+	}
+
+	if r.Mirrored != nil {
+		add(*r.Mirrored == (len(outputs.Mirrored()) > 0), "mirrored %v", *r.Mirrored)
+	}
+
+	var names []string
+	for name := range r.MinModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(outputs.HasModes(name, r.MinModes[name]), "min_modes %v: %d", name, r.MinModes[name])
+	}
+
+	for _, name := range r.OutputsPresentDisconnected {
+		add(outputs.Disconnected(name), "outputs_present_disconnected %v", name)
+	}
+
+	for _, name := range r.OutputsPresent {
+		add(outputs.Present(name), "outputs_present %v", name)
+	}
+
+	for _, name := range r.OutputsConnected {
+		add(outputs.Connected(name), "outputs_connected %v", name)
+	}
+
+	return conds
+}
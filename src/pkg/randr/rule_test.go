@@ -0,0 +1,298 @@
+package randr
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var testRules = []struct {
+	rule  Rule
+	match bool
+}{
+	{
+		Rule{
+			OutputsConnected: []string{"HDMI", "VGA"},
+			OutputsAbsent:    []string{"DP2-2"},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsConnected: []string{"LVDS1"},
+			OutputsAbsent:    []string{"HDMI"},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsConnected:    []string{"LVDS1"},
+			OutputsDisconnected: []string{"HDMI"},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsPresent: []string{"DP2-1"},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsPresent: []string{"DP2-1"},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsConnected:    []string{"HDMI*", "VGA"},
+			OutputsDisconnected: []string{"DP2-?"},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsConnected: []string{"HDMI*", "VGA"},
+			OutputsAbsent:    []string{"DP2-?"},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsPresentDisconnected: []string{"DP2-1"},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsPresentDisconnected: []string{"DP9"},
+		},
+		false,
+	},
+	{
+		Rule{
+			OutputsDisconnected: []string{"DP9"},
+		},
+		true,
+	},
+	{
+		Rule{
+			OutputsPresentDisconnected: []string{"HDMI"},
+		},
+		false,
+	},
+	{
+		Rule{
+			SupportsMode: map[string]string{"HDMI": "1920x1080"},
+		},
+		true,
+	},
+	{
+		Rule{
+			SupportsMode: map[string]string{"VGA": "1024x768"},
+		},
+		true,
+	},
+	{
+		Rule{
+			SupportsMode: map[string]string{"VGA": "1920x1080"},
+		},
+		false,
+	},
+	{
+		Rule{
+			SupportsMode: map[string]string{"DP2-1": "1024x768"},
+		},
+		false,
+	},
+	{
+		Rule{
+			AnySupportsMode: "1920x1080",
+		},
+		true,
+	},
+	{
+		Rule{
+			AnySupportsMode:  "1024x768",
+			OutputsConnected: []string{"LVDS"},
+		},
+		true,
+	},
+	{
+		Rule{
+			AnySupportsMode: "3840x2160",
+		},
+		false,
+	},
+	{
+		Rule{
+			MinModes: map[string]int{"HDMI": 2},
+		},
+		true,
+	},
+	{
+		Rule{
+			MinModes: map[string]int{"HDMI": 3},
+		},
+		false,
+	},
+	{
+		Rule{
+			MinModes: map[string]int{"DP2-1": 0},
+		},
+		false,
+	},
+}
+
+var testOutputs = []Output{
+	{
+		Name:      "LVDS",
+		Connected: true,
+		Modes: []Mode{
+			{Name: "1377x768", Default: true, Active: true},
+			{Name: "1024x768"},
+		},
+	},
+	{
+		Name:      "VGA",
+		Connected: true,
+		Modes: []Mode{
+			{Name: "1280x1024", Default: true},
+			{Name: "1024x768", Active: true},
+		},
+	},
+	{
+		Name:      "HDMI",
+		Connected: true,
+		Modes: []Mode{
+			{Name: "1920x1080", Default: true, Active: true},
+			{Name: "1024x768"},
+		},
+	},
+	{
+		Name: "DP2-1",
+	},
+}
+
+func TestRuleMatch(t *testing.T) {
+	for i, test := range testRules {
+		m := test.rule.Match(testOutputs)
+		if m != test.match {
+			t.Errorf("test rule %d wrong match: wanted %v, got %v", i, test.match, m)
+			continue
+		}
+	}
+}
+
+func TestRuleMatchActiveBetween(t *testing.T) {
+	defer func(old func() time.Time) { timeNow = old }(timeNow)
+
+	var tests = []struct {
+		window string
+		now    string
+		match  bool
+	}{
+		{"22:00-06:00", "23:30", true},
+		{"22:00-06:00", "03:00", true},
+		{"22:00-06:00", "06:00", false},
+		{"22:00-06:00", "12:00", false},
+		{"08:00-17:30", "08:00", true},
+		{"08:00-17:30", "17:29", true},
+		{"08:00-17:30", "21:00", false},
+	}
+
+	for i, test := range tests {
+		now, err := time.Parse("15:04", test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeNow = func() time.Time { return now }
+
+		rule := Rule{
+			OutputsConnected: []string{"LVDS"},
+			ActiveBetween:    test.window,
+		}
+
+		if m := rule.Match(testOutputs); m != test.match {
+			t.Errorf("test %d: window %v at %v: wanted match %v, got %v",
+				i, test.window, test.now, test.match, m)
+		}
+	}
+}
+
+func TestParseTimeWindowInvalid(t *testing.T) {
+	for _, s := range []string{"22:00", "22:00-25:00", "foo-bar", "10:00-10:00"} {
+		if _, err := parseTimeWindow(s); err == nil {
+			t.Errorf("invalid window %q did not return an error", s)
+		}
+	}
+}
+
+func TestRuleMatchMirrored(t *testing.T) {
+	mirrored := Outputs{
+		{Name: "LVDS", Connected: true, Modes: []Mode{{Name: "1024x768", Active: true}}},
+		{Name: "VGA", Connected: true, Modes: []Mode{{Name: "1024x768", Active: true}}},
+	}
+
+	extended := Outputs{
+		{Name: "LVDS", Connected: true, Modes: []Mode{{Name: "1024x768", Active: true}}},
+		{Name: "VGA", Connected: true, Modes: []Mode{{Name: "1024x768", Active: true}}, Offset: Offset{X: 1024}},
+		{Name: "HDMI", Connected: true, Modes: []Mode{{Name: "1920x1080"}}},
+	}
+
+	yes, no := true, false
+	var tests = []struct {
+		outputs  Outputs
+		mirrored *bool
+		match    bool
+	}{
+		{mirrored, &yes, true},
+		{mirrored, &no, false},
+		{mirrored, nil, true},
+		{extended, &yes, false},
+		{extended, &no, true},
+	}
+
+	for i, test := range tests {
+		rule := Rule{Mirrored: test.mirrored}
+		if m := rule.Match(test.outputs); m != test.match {
+			t.Errorf("test %d: wanted match %v, got %v", i, test.match, m)
+		}
+	}
+}
+
+func TestRuleMatchHostname(t *testing.T) {
+	defer func(old func() (string, error)) { hostname = old }(hostname)
+
+	var tests = []struct {
+		host    string
+		pattern string
+		match   bool
+	}{
+		{"workstation", "workstation", true},
+		{"workstation", "laptop", false},
+		{"laptop-x1", "laptop-*", true},
+		{"workstation", "laptop-*", false},
+		{"workstation", "", true},
+	}
+
+	for i, test := range tests {
+		host := test.host
+		hostname = func() (string, error) { return host, nil }
+
+		rule := Rule{
+			OutputsConnected: []string{"LVDS"},
+			Hostname:         test.pattern,
+		}
+
+		if m := rule.Match(testOutputs); m != test.match {
+			t.Errorf("test %d: pattern %q on host %v: wanted match %v, got %v",
+				i, test.pattern, test.host, test.match, m)
+		}
+	}
+
+	hostname = func() (string, error) { return "", errors.New("no host name") }
+	if (Rule{Hostname: "*"}).Match(testOutputs) {
+		t.Errorf("rule matched without a host name")
+	}
+}
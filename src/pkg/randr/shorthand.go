@@ -0,0 +1,80 @@
+package randr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shorthandArrow separates the conditions from the outputs in the shorthand
+// form of a rule.
+const shorthandArrow = "->"
+
+// parseShorthand parses the shorthand form of a rule, a list of conditions
+// separated by commas, "->" and the outputs to enable, e.g.
+// "HDMI1 connected, DP1 disconnected -> LVDS1 HDMI1@1920x1080". A condition is
+// a list of output names or patterns followed by "connected", "disconnected",
+// "present" or "absent". A single output is configured with ConfigureSingle,
+// several outputs with ConfigureRow. The mode "auto" is the default mode.
+func parseShorthand(s string) (Rule, error) {
+	data := strings.SplitN(s, shorthandArrow, 2)
+	if len(data) != 2 {
+		return Rule{}, fmt.Errorf("rule %q: missing %q between the conditions and the outputs", s, shorthandArrow)
+	}
+
+	var r Rule
+	for _, clause := range strings.Split(data[0], ",") {
+		fields := strings.Fields(clause)
+		if len(fields) < 2 {
+			return Rule{}, fmt.Errorf("rule %q: condition %q is not of the form \"OUTPUT... STATE\"", s, strings.TrimSpace(clause))
+		}
+
+		names, state := fields[:len(fields)-1], fields[len(fields)-1]
+		switch state {
+		case "connected":
+			r.OutputsConnected = append(r.OutputsConnected, names...)
+		case "disconnected":
+			r.OutputsDisconnected = append(r.OutputsDisconnected, names...)
+		case "present":
+			r.OutputsPresent = append(r.OutputsPresent, names...)
+		case "absent":
+			r.OutputsAbsent = append(r.OutputsAbsent, names...)
+		default:
+			return Rule{}, fmt.Errorf("rule %q: unknown state %q, must be connected, disconnected, present or absent", s, state)
+		}
+	}
+
+	var outputs []string
+	for _, entry := range strings.Fields(data[1]) {
+		outputs = append(outputs, strings.TrimSuffix(entry, "@auto"))
+	}
+
+	switch len(outputs) {
+	case 0:
+		return Rule{}, fmt.Errorf("rule %q: no outputs to enable", s)
+	case 1:
+		r.ConfigureSingle = outputs[0]
+	default:
+		r.ConfigureRow = outputs
+	}
+
+	return r, nil
+}
+
+// UnmarshalYAML decodes a rule either from a mapping of the settings or from
+// the shorthand form accepted by parseShorthand.
+func (r *Rule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		rule, err := parseShorthand(s)
+		if err != nil {
+			return err
+		}
+		*r = rule
+		return nil
+	}
+
+	// ruleFields has the fields of Rule, but not the method, so that the
+	// mapping is decoded as usual
+	type ruleFields Rule
+	return unmarshal((*ruleFields)(r))
+}
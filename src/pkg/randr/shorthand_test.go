@@ -0,0 +1,73 @@
+package randr
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testShorthandRules = `
+- "eDP-1 connected -> eDP-1@auto"
+- name: Docked
+  outputs_connected: [HDMI1]
+  outputs_disconnected: [DP1]
+  configure_row: [LVDS1, "HDMI1@1920x1080"]
+- "HDMI1 connected, DP1 disconnected -> LVDS1 HDMI1@1920x1080"
+- "DP2-1 DP2-2 present, VGA1 absent -> DP2-1/primary"
+`
+
+func TestRuleUnmarshalShorthand(t *testing.T) {
+	var rules []Rule
+	if err := yaml.Unmarshal([]byte(testShorthandRules), &rules); err != nil {
+		t.Fatalf("unmarshal returned error: %v", err)
+	}
+
+	want := []Rule{
+		{
+			OutputsConnected: []string{"eDP-1"},
+			ConfigureSingle:  "eDP-1",
+		},
+		{
+			Name:                "Docked",
+			OutputsConnected:    []string{"HDMI1"},
+			OutputsDisconnected: []string{"DP1"},
+			ConfigureRow:        []string{"LVDS1", "HDMI1@1920x1080"},
+		},
+		{
+			OutputsConnected:    []string{"HDMI1"},
+			OutputsDisconnected: []string{"DP1"},
+			ConfigureRow:        []string{"LVDS1", "HDMI1@1920x1080"},
+		},
+		{
+			OutputsPresent:  []string{"DP2-1", "DP2-2"},
+			OutputsAbsent:   []string{"VGA1"},
+			ConfigureSingle: "DP2-1/primary",
+		},
+	}
+
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("wrong rules:\n  want %#v\n  got  %#v", want, rules)
+	}
+
+	// the shorthand and the mapping describe the same rule
+	rules[1].Name = ""
+	if !reflect.DeepEqual(rules[1], rules[2]) {
+		t.Errorf("shorthand differs from mapping:\n  %#v\n  %#v", rules[1], rules[2])
+	}
+}
+
+func TestParseShorthandInvalid(t *testing.T) {
+	for _, s := range []string{
+		"eDP-1 connected",
+		"connected -> eDP-1",
+		"eDP-1 attached -> eDP-1",
+		"eDP-1 connected ->",
+		", eDP-1 connected -> eDP-1",
+	} {
+		var rules []Rule
+		if err := yaml.Unmarshal([]byte("- "+s), &rules); err == nil {
+			t.Errorf("%q: invalid shorthand was accepted: %#v", s, rules)
+		}
+	}
+}
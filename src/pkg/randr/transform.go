@@ -0,0 +1,51 @@
+package randr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// transformNone is the transform which resets the transformation matrix.
+const transformNone = "none"
+
+// validTransform returns an error if s is neither "none" nor a 3x3 matrix of
+// nine comma separated numbers as accepted by `xrandr --transform`.
+func validTransform(s string) error {
+	if s == transformNone {
+		return nil
+	}
+
+	data := strings.Split(s, ",")
+	if len(data) != 9 {
+		return fmt.Errorf("transform %q is not a matrix of nine comma separated numbers", s)
+	}
+
+	for _, v := range data {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("transform %q is not a matrix of nine comma separated numbers", s)
+		}
+	}
+
+	return nil
+}
+
+// transformConflict returns an error if the target combines a transform with
+// a scale or a rotation. xrandr computes --scale and --rotate as a
+// transformation too, so either the transform or the other setting would be
+// silently lost.
+func transformConflict(t OutputTarget) error {
+	if t.Transform == "" || t.Transform == transformNone {
+		return nil
+	}
+
+	if t.Scale != "" {
+		return fmt.Errorf("output %v: transform %v cannot be combined with scale %v, include the scaling in the matrix", t.Name, t.Transform, t.Scale)
+	}
+
+	if t.Rotated && t.Rotation != "normal" {
+		return fmt.Errorf("output %v: transform %v cannot be combined with rotation %v, include the rotation in the matrix", t.Name, t.Transform, t.Rotation)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package randr
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testKeystone = "1,0.2,0,0,1,0,0,0.0002,1"
+
+func TestValidTransform(t *testing.T) {
+	var tests = []struct {
+		transform string
+		valid     bool
+	}{
+		{"none", true},
+		{testKeystone, true},
+		{"1,0,0,0,1,0,0,0,1", true},
+		{"1,0,0,0,1,0,0,0", false},
+		{"1,0,0,0,1,0,0,0,1,0", false},
+		{"1,0,0,0,1,0,0,0,x", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		err := validTransform(test.transform)
+		if test.valid && err != nil {
+			t.Errorf("%q: returned error: %v", test.transform, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%q: invalid transform was accepted", test.transform)
+		}
+	}
+
+	if err := (Rule{Transform: map[string]string{"VGA1": "1,0"}}).Valid(); err == nil {
+		t.Errorf("invalid transform in rule was accepted")
+	}
+}
+
+func TestBuildCommandOutputRowTransform(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "VGA1", Connected: true, Modes: []Mode{{Name: "1024x768", Default: true}}},
+	}
+
+	rule := Rule{
+		ConfigureRow: []string{"LVDS1", "VGA1"},
+		Transform:    map[string]string{"VGA1": testKeystone},
+		Rotate:       map[string]string{"VGA1": "normal"},
+		Atomic:       true,
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := [][]string{
+		{"xrandr", "--output", "LVDS1", "--auto",
+			"--output", "VGA1", "--auto", "--rotate", "normal", "--transform", testKeystone, "--right-of", "LVDS1"},
+	}
+
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands:\n  want %v\n  got  %v", want, got)
+	}
+
+	// resetting the transform does not conflict with anything
+	rule.Transform["VGA1"] = "none"
+	rule.ScaleTo = map[string]string{"VGA1": "2048x1536"}
+	if _, err = BuildCommandOutputRow(rule, current, Options{}); err != nil {
+		t.Errorf("transform none returned error: %v", err)
+	}
+}
+
+func TestBuildCommandOutputRowTransformConflict(t *testing.T) {
+	current := Outputs{
+		{Name: "LVDS1", Connected: true, Modes: []Mode{{Name: "1366x768", Default: true, Active: true}}},
+		{Name: "VGA1", Connected: true, Modes: []Mode{{Name: "1024x768", Default: true}}},
+	}
+
+	transform := map[string]string{"VGA1": testKeystone}
+
+	var tests = []struct {
+		rule Rule
+		err  string
+	}{
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "VGA1"},
+				Transform:    transform,
+				ScaleTo:      map[string]string{"VGA1": "2048x1536"},
+			},
+			"output VGA1: transform " + testKeystone + " cannot be combined with scale 2x2, include the scaling in the matrix",
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "VGA1"},
+				Transform:    transform,
+				Rotate:       map[string]string{"VGA1": "left"},
+			},
+			"output VGA1: transform " + testKeystone + " cannot be combined with rotation left, include the rotation in the matrix",
+		},
+		{
+			Rule{
+				ConfigureRow: []string{"LVDS1", "VGA1/inverted"},
+				Transform:    transform,
+			},
+			"output VGA1: transform " + testKeystone + " cannot be combined with rotation inverted, include the rotation in the matrix",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := BuildCommandOutputRow(test.rule, current, Options{})
+		if err == nil {
+			t.Errorf("test %d: expected error, got nil", i)
+			continue
+		}
+
+		if err.Error() != test.err {
+			t.Errorf("test %d: wrong error:\n  want %q\n  got  %q", i, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package randr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// underscanOff is the underscan spec which turns underscan off.
+const underscanOff = "off"
+
+// parseUnderscan parses an underscan spec, either a percentage of the mode
+// size like "5%" or the horizontal and vertical borders in pixels like
+// "16,16". For a percentage, pct is set and the borders are zero.
+func parseUnderscan(spec string) (pct float64, hborder, vborder int, err error) {
+	if strings.HasSuffix(spec, "%") {
+		pct, err = strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || pct <= 0 || pct >= 50 {
+			return 0, 0, 0, fmt.Errorf("underscan %q is not a percentage between 0%% and 50%%", spec)
+		}
+		return pct, 0, 0, nil
+	}
+
+	data := strings.Split(spec, ",")
+	if len(data) != 2 {
+		return 0, 0, 0, fmt.Errorf("underscan %q is neither a percentage nor of the form H,V", spec)
+	}
+
+	hborder, err = strconv.Atoi(data[0])
+	if err == nil {
+		vborder, err = strconv.Atoi(data[1])
+	}
+	if err != nil || hborder < 0 || vborder < 0 {
+		return 0, 0, 0, fmt.Errorf("underscan %q is neither a percentage nor of the form H,V", spec)
+	}
+
+	return 0, hborder, vborder, nil
+}
+
+// validUnderscan returns an error if spec is not a valid underscan spec.
+func validUnderscan(spec string) error {
+	if spec == underscanOff {
+		return nil
+	}
+
+	_, _, _, err := parseUnderscan(spec)
+	return err
+}
+
+// underscanArgs returns the xrandr arguments which set the underscan
+// properties of an output using mode according to spec. Percentages are
+// relative to the size of the mode, which must be known.
+func underscanArgs(spec string, mode Mode) ([]string, error) {
+	if spec == underscanOff {
+		return []string{"--set", "underscan", "off"}, nil
+	}
+
+	pct, hborder, vborder, err := parseUnderscan(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if pct > 0 {
+		if mode.Width() == 0 || mode.Height() == 0 {
+			return nil, fmt.Errorf("underscan %v needs a known mode", spec)
+		}
+
+		hborder = int(float64(mode.Width())*pct/100 + 0.5)
+		vborder = int(float64(mode.Height())*pct/100 + 0.5)
+	}
+
+	return []string{
+		"--set", "underscan", "on",
+		"--set", "underscan hborder", strconv.Itoa(hborder),
+		"--set", "underscan vborder", strconv.Itoa(vborder),
+	}, nil
+}
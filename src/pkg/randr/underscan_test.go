@@ -0,0 +1,72 @@
+package randr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildCommandOutputRowUnderscan(t *testing.T) {
+	current := Outputs{
+		{Name: "eDP1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true, Active: true}}},
+		{Name: "HDMI1", Connected: true, Modes: []Mode{{Name: "1920x1080", Default: true}, {Name: "1280x720"}}},
+	}
+
+	var tests = []struct {
+		spec string
+		want []string
+	}{
+		{"5%", []string{"--set", "underscan", "on", "--set", "underscan hborder", "96", "--set", "underscan vborder", "54"}},
+		{"2.5%", []string{"--set", "underscan", "on", "--set", "underscan hborder", "48", "--set", "underscan vborder", "27"}},
+		{"16,16", []string{"--set", "underscan", "on", "--set", "underscan hborder", "16", "--set", "underscan vborder", "16"}},
+		{"off", []string{"--set", "underscan", "off"}},
+	}
+
+	for i, test := range tests {
+		rule := Rule{
+			ConfigureRow: []string{"eDP1", "HDMI1"},
+			Underscan:    map[string]string{"HDMI1": test.spec},
+		}
+
+		if err := rule.Valid(); err != nil {
+			t.Errorf("test %d: rule is invalid: %v", i, err)
+			continue
+		}
+
+		cmds, err := BuildCommandOutputRow(rule, current, Options{})
+		if err != nil {
+			t.Errorf("test %d: BuildCommandOutputRow returned error: %v", i, err)
+			continue
+		}
+
+		want := [][]string{
+			{"xrandr", "--output", "eDP1", "--auto"},
+			append(append([]string{"xrandr", "--output", "HDMI1", "--auto"}, test.want...), "--right-of", "eDP1"),
+		}
+		if got := testCommandArgs(cmds); !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d: wrong commands:\n  want %v\n  got  %v", i, want, got)
+		}
+	}
+
+	// percentages are relative to the mode requested
+	rule := Rule{
+		ConfigureSingle: "HDMI1@1280x720",
+		Underscan:       map[string]string{"HDMI1": "5%"},
+	}
+
+	cmds, err := BuildCommandOutputRow(rule, current, Options{})
+	if err != nil {
+		t.Fatalf("BuildCommandOutputRow returned error: %v", err)
+	}
+
+	want := []string{"xrandr", "--output", "HDMI1", "--mode", "1280x720",
+		"--set", "underscan", "on", "--set", "underscan hborder", "64", "--set", "underscan vborder", "36"}
+	if got := testCommandArgs(cmds); !reflect.DeepEqual(got[len(got)-1], want) {
+		t.Errorf("wrong command:\n  want %v\n  got  %v", want, got)
+	}
+
+	for _, spec := range []string{"5", "60%", "-1,16", "16x16", "on"} {
+		if err := (Rule{Underscan: map[string]string{"HDMI1": spec}}).Valid(); err == nil {
+			t.Errorf("invalid spec %q accepted", spec)
+		}
+	}
+}